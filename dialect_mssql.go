@@ -340,10 +340,12 @@ func (db *mssql) GetColumns(tableName string) ([]string, map[string]*core.Column
 	s := `select a.name as name, b.name as ctype,a.max_length,a.precision,a.scale,a.is_nullable as nullable,
 		  "default_is_null" = (CASE WHEN c.text is null THEN 1 ELSE 0 END),
 	      replace(replace(isnull(c.text,''),'(',''),')','') as vdefault,
-		  ISNULL(i.is_primary_key, 0), a.is_identity as is_identity
+		  ISNULL(i.is_primary_key, 0), a.is_identity as is_identity,
+		  ISNULL(CAST(ep.value AS NVARCHAR(4000)), '') as comment
           from sys.columns a 
 		  left join sys.types b on a.user_type_id=b.user_type_id
           left join sys.syscomments c on a.default_object_id=c.id
+          left join sys.extended_properties ep on ep.major_id=a.object_id and ep.minor_id=a.column_id and ep.name='MS_Description'
 		  LEFT OUTER JOIN 
     sys.index_columns ic ON ic.object_id = a.object_id AND ic.column_id = a.column_id
 		  LEFT OUTER JOIN 
@@ -360,10 +362,10 @@ func (db *mssql) GetColumns(tableName string) ([]string, map[string]*core.Column
 	cols := make(map[string]*core.Column)
 	colSeq := make([]string, 0)
 	for rows.Next() {
-		var name, ctype, vdefault string
+		var name, ctype, vdefault, comment string
 		var maxLen, precision, scale int
 		var nullable, isPK, defaultIsNull, isIncrement bool
-		err = rows.Scan(&name, &ctype, &maxLen, &precision, &scale, &nullable, &defaultIsNull, &vdefault, &isPK, &isIncrement)
+		err = rows.Scan(&name, &ctype, &maxLen, &precision, &scale, &nullable, &defaultIsNull, &vdefault, &isPK, &isIncrement, &comment)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -378,10 +380,14 @@ func (db *mssql) GetColumns(tableName string) ([]string, map[string]*core.Column
 		}
 		col.IsPrimaryKey = isPK
 		col.IsAutoIncrement = isIncrement
+		col.Comment = comment
 		ct := strings.ToUpper(ctype)
 		if ct == "DECIMAL" {
 			col.Length = precision
 			col.Length2 = scale
+		} else if (ct == "NVARCHAR" || ct == "NCHAR") && maxLen > 0 {
+			// max_length of the unicode types is in bytes
+			col.Length = maxLen / 2
 		} else {
 			col.Length = maxLen
 		}
@@ -495,6 +501,10 @@ WHERE IXS.TYPE_DESC='NONCLUSTERED' and OBJECT_NAME(IXS.OBJECT_ID) =?
 }
 
 func (db *mssql) CreateTableSql(table *core.Table, tableName, storeEngine, charset string) string {
+	return db.CreateTableWithSchemaSql(table, tableName, storeEngine, charset, nil)
+}
+
+func (db *mssql) CreateTableWithSchemaSql(table *core.Table, tableName, storeEngine, charset string, schema *TableSchema) string {
 	var sql string
 	if tableName == "" {
 		tableName = table.Name
@@ -508,11 +518,7 @@ func (db *mssql) CreateTableSql(table *core.Table, tableName, storeEngine, chars
 
 	for _, colName := range table.ColumnsSeq() {
 		col := table.GetColumn(colName)
-		if col.IsPrimaryKey && len(pkList) == 1 {
-			sql += col.String(db)
-		} else {
-			sql += col.StringNoPk(db)
-		}
+		sql += db.columnDefinition(col, col.IsPrimaryKey && len(pkList) == 1, schema.Column(colName))
 		sql = strings.TrimSpace(sql)
 		sql += ", "
 	}
@@ -523,11 +529,164 @@ func (db *mssql) CreateTableSql(table *core.Table, tableName, storeEngine, chars
 		sql += " ), "
 	}
 
+	if schema != nil {
+		for _, fk := range schema.ForeignKeys {
+			sql += db.foreignKeyClause(fk) + ", "
+		}
+		for _, check := range schema.Checks {
+			sql += checkClause(db.Quote, check) + ", "
+		}
+	}
+
 	sql = sql[:len(sql)-2] + ")"
 	sql += ";"
 	return sql
 }
 
+// columnDefinition returns the computed column of SQL Server which has no type
+// instead of the generated column
+func (db *mssql) columnDefinition(col *core.Column, pk bool, attrs *ColumnSchema) string {
+	if attrs == nil || attrs.Generated == "" {
+		return columnDefinition(db, col, pk, attrs)
+	}
+	sql := db.Quote(col.Name) + " AS (" + attrs.Generated + ")"
+	if attrs.Stored {
+		sql += " PERSISTED"
+		if !col.Nullable {
+			sql += " NOT NULL"
+		}
+	}
+	return sql
+}
+
+func (db *mssql) AddColumnSql(tableName string, col *core.Column, attrs *ColumnSchema) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), strings.TrimSpace(db.columnDefinition(col, col.IsPrimaryKey, attrs)))
+}
+
+func (db *mssql) AddCheckSql(tableName string, check *Check) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), checkClause(db.Quote, check))
+}
+
+func (db *mssql) GetChecks(tableName string) ([]*Check, error) {
+	args := []interface{}{tableName}
+	s := "SELECT name, definition FROM sys.check_constraints WHERE parent_object_id = OBJECT_ID(?) ORDER BY name"
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []*Check
+	for rows.Next() {
+		var name, def string
+		if err := rows.Scan(&name, &def); err != nil {
+			return nil, err
+		}
+		checks = append(checks, &Check{Name: name, Expr: unwrapCheck(def)})
+	}
+	return checks, rows.Err()
+}
+
+func (db *mssql) GetColumnSchemas(tableName string) (map[string]*ColumnSchema, error) {
+	args := []interface{}{tableName}
+	s := "SELECT c.name, ISNULL(c.collation_name, ''), ISNULL(cc.definition, ''), ISNULL(cc.is_persisted, 0) " +
+		"FROM sys.columns c LEFT JOIN sys.computed_columns cc ON cc.object_id = c.object_id AND cc.column_id = c.column_id " +
+		"WHERE c.object_id = OBJECT_ID(?)"
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns = make(map[string]*ColumnSchema)
+	for rows.Next() {
+		var name, collation, generated string
+		var stored bool
+		if err := rows.Scan(&name, &collation, &generated, &stored); err != nil {
+			return nil, err
+		}
+		attrs := ColumnSchema{
+			Collation: collation,
+			Generated: unwrapCheck(generated),
+			Stored:    stored,
+		}
+		if attrs != (ColumnSchema{}) {
+			columns[name] = &attrs
+		}
+	}
+	return columns, rows.Err()
+}
+
+// foreignKeyClause replaces RESTRICT with NO ACTION which is the same on SQL Server
+func (db *mssql) foreignKeyClause(fk *ForeignKey) string {
+	var mssqlFK = *fk
+	if mssqlFK.OnDelete == "RESTRICT" {
+		mssqlFK.OnDelete = "NO ACTION"
+	}
+	if mssqlFK.OnUpdate == "RESTRICT" {
+		mssqlFK.OnUpdate = "NO ACTION"
+	}
+	return foreignKeyClause(db.Quote, &mssqlFK)
+}
+
+func (db *mssql) AddForeignKeySql(tableName string, fk *ForeignKey) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), db.foreignKeyClause(fk))
+}
+
+func (db *mssql) GetForeignKeys(tableName string) ([]*ForeignKey, error) {
+	args := []interface{}{tableName}
+	s := "SELECT fk.name, COL_NAME(fkc.parent_object_id, fkc.parent_column_id), " +
+		"OBJECT_NAME(fkc.referenced_object_id), COL_NAME(fkc.referenced_object_id, fkc.referenced_column_id), " +
+		"fk.delete_referential_action_desc, fk.update_referential_action_desc FROM sys.foreign_keys fk " +
+		"JOIN sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id " +
+		"WHERE fk.parent_object_id = OBJECT_ID(?) ORDER BY fk.name, fkc.constraint_column_id"
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks []*ForeignKey
+	for rows.Next() {
+		var name, col, refTable, refCol, onDelete, onUpdate string
+		if err := rows.Scan(&name, &col, &refTable, &refCol, &onDelete, &onUpdate); err != nil {
+			return nil, err
+		}
+		fks = appendForeignKeyColumn(fks, name, col, refTable, refCol, onDelete, onUpdate)
+	}
+	return fks, rows.Err()
+}
+
+func (db *mssql) UpsertSql(tableName string, colNames, conflictCols, updateCols []string, rows int) string {
+	var on = make([]string, 0, len(conflictCols))
+	for _, col := range conflictCols {
+		on = append(on, fmt.Sprintf("target.%s = source.%s", db.Quote(col), db.Quote(col)))
+	}
+
+	sql := fmt.Sprintf("MERGE INTO %s WITH (HOLDLOCK) AS target USING (VALUES %s) AS source (%s) ON %s",
+		tableName, valuesPlaceholders(len(colNames), rows), quoteJoin(colNames, db.Quote, ", "),
+		strings.Join(on, " AND "))
+
+	if len(updateCols) > 0 {
+		var sets = make([]string, 0, len(updateCols))
+		for _, col := range updateCols {
+			sets = append(sets, fmt.Sprintf("%s = source.%s", db.Quote(col), db.Quote(col)))
+		}
+		sql += " WHEN MATCHED THEN UPDATE SET " + strings.Join(sets, ", ")
+	}
+
+	sql += fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s);",
+		quoteJoin(colNames, db.Quote, ", "),
+		quoteJoin(colNames, func(col string) string { return "source." + db.Quote(col) }, ", "))
+	return sql
+}
+
 func (db *mssql) ForUpdateSql(query string) string {
 	return query
 }
@@ -6,6 +6,7 @@ package xorm
 
 import (
 	"crypto/tls"
+	"database/sql"
 	"errors"
 	"fmt"
 	"regexp"
@@ -506,6 +507,10 @@ func (db *mysql) GetIndexes(tableName string) (map[string]*core.Index, error) {
 }
 
 func (db *mysql) CreateTableSql(table *core.Table, tableName, storeEngine, charset string) string {
+	return db.CreateTableWithSchemaSql(table, tableName, storeEngine, charset, nil)
+}
+
+func (db *mysql) CreateTableWithSchemaSql(table *core.Table, tableName, storeEngine, charset string, schema *TableSchema) string {
 	var sql string
 	sql = "CREATE TABLE IF NOT EXISTS "
 	if tableName == "" {
@@ -520,11 +525,7 @@ func (db *mysql) CreateTableSql(table *core.Table, tableName, storeEngine, chars
 
 		for _, colName := range table.ColumnsSeq() {
 			col := table.GetColumn(colName)
-			if col.IsPrimaryKey && len(pkList) == 1 {
-				sql += col.String(db)
-			} else {
-				sql += col.StringNoPk(db)
-			}
+			sql += columnDefinition(db, col, col.IsPrimaryKey && len(pkList) == 1, schema.Column(colName))
 			sql = strings.TrimSpace(sql)
 			if len(col.Comment) > 0 {
 				sql += " COMMENT '" + col.Comment + "'"
@@ -538,6 +539,15 @@ func (db *mysql) CreateTableSql(table *core.Table, tableName, storeEngine, chars
 			sql += " ), "
 		}
 
+		if schema != nil {
+			for _, fk := range schema.ForeignKeys {
+				sql += foreignKeyClause(db.Quote, fk) + ", "
+			}
+			for _, check := range schema.Checks {
+				sql += checkClause(db.Quote, check) + ", "
+			}
+		}
+
 		sql = sql[:len(sql)-2]
 	}
 	sql += ")"
@@ -559,6 +569,122 @@ func (db *mysql) CreateTableSql(table *core.Table, tableName, storeEngine, chars
 	return sql
 }
 
+func (db *mysql) AddColumnSql(tableName string, col *core.Column, attrs *ColumnSchema) string {
+	sql := fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), strings.TrimSpace(columnDefinition(db, col, col.IsPrimaryKey, attrs)))
+	if len(col.Comment) > 0 {
+		sql += " COMMENT '" + col.Comment + "'"
+	}
+	return sql
+}
+
+func (db *mysql) AddForeignKeySql(tableName string, fk *ForeignKey) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), foreignKeyClause(db.Quote, fk))
+}
+
+func (db *mysql) AddCheckSql(tableName string, check *Check) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), checkClause(db.Quote, check))
+}
+
+// GetChecks requires MySQL 8.0.16 or later
+func (db *mysql) GetChecks(tableName string) ([]*Check, error) {
+	args := []interface{}{db.DbName, tableName}
+	s := "SELECT tc.`CONSTRAINT_NAME`, cc.`CHECK_CLAUSE` FROM `INFORMATION_SCHEMA`.`TABLE_CONSTRAINTS` tc " +
+		"JOIN `INFORMATION_SCHEMA`.`CHECK_CONSTRAINTS` cc " +
+		"ON cc.`CONSTRAINT_SCHEMA` = tc.`CONSTRAINT_SCHEMA` AND cc.`CONSTRAINT_NAME` = tc.`CONSTRAINT_NAME` " +
+		"WHERE tc.`TABLE_SCHEMA` = ? AND tc.`TABLE_NAME` = ? AND tc.`CONSTRAINT_TYPE` = 'CHECK' " +
+		"ORDER BY tc.`CONSTRAINT_NAME`"
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []*Check
+	for rows.Next() {
+		var check Check
+		if err := rows.Scan(&check.Name, &check.Expr); err != nil {
+			return nil, err
+		}
+		checks = append(checks, &check)
+	}
+	return checks, rows.Err()
+}
+
+func (db *mysql) GetColumnSchemas(tableName string) (map[string]*ColumnSchema, error) {
+	args := []interface{}{db.DbName, tableName}
+	s := "SELECT `COLUMN_NAME`, `COLLATION_NAME`, `GENERATION_EXPRESSION`, `EXTRA` FROM `INFORMATION_SCHEMA`.`COLUMNS` " +
+		"WHERE `TABLE_SCHEMA` = ? AND `TABLE_NAME` = ?"
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns = make(map[string]*ColumnSchema)
+	for rows.Next() {
+		var name, extra string
+		var collation, generated sql.NullString
+		if err := rows.Scan(&name, &collation, &generated, &extra); err != nil {
+			return nil, err
+		}
+		attrs := ColumnSchema{
+			Collation: collation.String,
+			Generated: generated.String,
+			Stored:    strings.Contains(strings.ToUpper(extra), "STORED GENERATED"),
+		}
+		if attrs != (ColumnSchema{}) {
+			columns[name] = &attrs
+		}
+	}
+	return columns, rows.Err()
+}
+
+func (db *mysql) GetForeignKeys(tableName string) ([]*ForeignKey, error) {
+	args := []interface{}{db.DbName, tableName}
+	s := "SELECT k.`CONSTRAINT_NAME`, k.`COLUMN_NAME`, k.`REFERENCED_TABLE_NAME`, k.`REFERENCED_COLUMN_NAME`, " +
+		"r.`DELETE_RULE`, r.`UPDATE_RULE` FROM `INFORMATION_SCHEMA`.`KEY_COLUMN_USAGE` k " +
+		"JOIN `INFORMATION_SCHEMA`.`REFERENTIAL_CONSTRAINTS` r " +
+		"ON r.`CONSTRAINT_SCHEMA` = k.`CONSTRAINT_SCHEMA` AND r.`CONSTRAINT_NAME` = k.`CONSTRAINT_NAME` " +
+		"WHERE k.`TABLE_SCHEMA` = ? AND k.`TABLE_NAME` = ? AND k.`REFERENCED_TABLE_NAME` IS NOT NULL " +
+		"ORDER BY k.`CONSTRAINT_NAME`, k.`ORDINAL_POSITION`"
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks []*ForeignKey
+	for rows.Next() {
+		var name, col, refTable, refCol, onDelete, onUpdate string
+		if err := rows.Scan(&name, &col, &refTable, &refCol, &onDelete, &onUpdate); err != nil {
+			return nil, err
+		}
+		fks = appendForeignKeyColumn(fks, name, col, refTable, refCol, onDelete, onUpdate)
+	}
+	return fks, rows.Err()
+}
+
+func (db *mysql) UpsertSql(tableName string, colNames, conflictCols, updateCols []string, rows int) string {
+	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s ON DUPLICATE KEY UPDATE ",
+		tableName, quoteJoin(colNames, db.Quote, ", "), valuesPlaceholders(len(colNames), rows))
+	if len(updateCols) == 0 {
+		// a no-op update makes the duplicated rows be ignored
+		return sql + db.Quote(colNames[0]) + " = " + db.Quote(colNames[0])
+	}
+
+	sets := make([]string, 0, len(updateCols))
+	for _, col := range updateCols {
+		sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", db.Quote(col), db.Quote(col)))
+	}
+	return sql + strings.Join(sets, ", ")
+}
+
 func (db *mysql) Filters() []core.Filter {
 	return []core.Filter{&core.IdFilter{}}
 }
@@ -5,6 +5,7 @@
 package xorm
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"regexp"
@@ -576,6 +577,10 @@ func (db *oracle) DropTableSql(tableName string) string {
 }
 
 func (db *oracle) CreateTableSql(table *core.Table, tableName, storeEngine, charset string) string {
+	return db.CreateTableWithSchemaSql(table, tableName, storeEngine, charset, nil)
+}
+
+func (db *oracle) CreateTableWithSchemaSql(table *core.Table, tableName, storeEngine, charset string, schema *TableSchema) string {
 	var sql string
 	sql = "CREATE TABLE "
 	if tableName == "" {
@@ -591,7 +596,7 @@ func (db *oracle) CreateTableSql(table *core.Table, tableName, storeEngine, char
 		/*if col.IsPrimaryKey && len(pkList) == 1 {
 			sql += col.String(b.dialect)
 		} else {*/
-		sql += col.StringNoPk(db)
+		sql += columnDefinition(db, col, false, schema.Column(colName))
 		// }
 		sql = strings.TrimSpace(sql)
 		sql += ", "
@@ -603,6 +608,15 @@ func (db *oracle) CreateTableSql(table *core.Table, tableName, storeEngine, char
 		sql += " ), "
 	}
 
+	if schema != nil {
+		for _, fk := range schema.ForeignKeys {
+			sql += db.foreignKeyClause(fk) + ", "
+		}
+		for _, check := range schema.Checks {
+			sql += checkClause(db.Quote, check) + ", "
+		}
+	}
+
 	sql = sql[:len(sql)-2] + ")"
 	if db.SupportEngine() && storeEngine != "" {
 		sql += " ENGINE=" + storeEngine
@@ -618,6 +632,140 @@ func (db *oracle) CreateTableSql(table *core.Table, tableName, storeEngine, char
 	return sql
 }
 
+func (db *oracle) UpsertSql(tableName string, colNames, conflictCols, updateCols []string, rows int) string {
+	var selects = make([]string, 0, len(colNames))
+	for _, col := range colNames {
+		selects = append(selects, "? "+db.Quote(col))
+	}
+	var source = strings.TrimSuffix(strings.Repeat("SELECT "+strings.Join(selects, ", ")+" FROM DUAL UNION ALL ", rows), " UNION ALL ")
+
+	var on = make([]string, 0, len(conflictCols))
+	for _, col := range conflictCols {
+		on = append(on, fmt.Sprintf("target.%s = source.%s", db.Quote(col), db.Quote(col)))
+	}
+
+	sql := fmt.Sprintf("MERGE INTO %s target USING (%s) source ON (%s)",
+		tableName, source, strings.Join(on, " AND "))
+
+	if len(updateCols) > 0 {
+		var sets = make([]string, 0, len(updateCols))
+		for _, col := range updateCols {
+			sets = append(sets, fmt.Sprintf("target.%s = source.%s", db.Quote(col), db.Quote(col)))
+		}
+		sql += " WHEN MATCHED THEN UPDATE SET " + strings.Join(sets, ", ")
+	}
+
+	sql += fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)",
+		quoteJoin(colNames, db.Quote, ", "),
+		quoteJoin(colNames, func(col string) string { return "source." + db.Quote(col) }, ", "))
+	return sql
+}
+
+// foreignKeyClause keeps the actions which Oracle supports, it has no ON UPDATE and
+// its ON DELETE is CASCADE or SET NULL, the others are the same as the default
+func (db *oracle) foreignKeyClause(fk *ForeignKey) string {
+	var oracleFK = *fk
+	if oracleFK.OnDelete != "CASCADE" && oracleFK.OnDelete != "SET NULL" {
+		oracleFK.OnDelete = ""
+	}
+	oracleFK.OnUpdate = ""
+	return foreignKeyClause(db.Quote, &oracleFK)
+}
+
+func (db *oracle) AddColumnSql(tableName string, col *core.Column, attrs *ColumnSchema) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), strings.TrimSpace(columnDefinition(db, col, false, attrs)))
+}
+
+func (db *oracle) AddForeignKeySql(tableName string, fk *ForeignKey) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), db.foreignKeyClause(fk))
+}
+
+func (db *oracle) AddCheckSql(tableName string, check *Check) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), checkClause(db.Quote, check))
+}
+
+// GetChecks requires Oracle 12.2 or later, the NOT NULL constraints which are checks
+// with generated names are not returned
+func (db *oracle) GetChecks(tableName string) ([]*Check, error) {
+	args := []interface{}{tableName}
+	s := "SELECT CONSTRAINT_NAME, SEARCH_CONDITION_VC FROM USER_CONSTRAINTS " +
+		"WHERE CONSTRAINT_TYPE = 'C' AND GENERATED = 'USER NAME' AND TABLE_NAME = :1 ORDER BY CONSTRAINT_NAME"
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []*Check
+	for rows.Next() {
+		var name, cond string
+		if err := rows.Scan(&name, &cond); err != nil {
+			return nil, err
+		}
+		checks = append(checks, &Check{Name: name, Expr: unwrapCheck(cond)})
+	}
+	return checks, rows.Err()
+}
+
+// GetColumnSchemas requires Oracle 12.2 or later for the collations, the generated
+// columns of Oracle are always virtual
+func (db *oracle) GetColumnSchemas(tableName string) (map[string]*ColumnSchema, error) {
+	args := []interface{}{tableName}
+	s := "SELECT COLUMN_NAME, COLLATION, VIRTUAL_COLUMN, DATA_DEFAULT FROM USER_TAB_COLS " +
+		"WHERE TABLE_NAME = :1 AND HIDDEN_COLUMN = 'NO'"
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns = make(map[string]*ColumnSchema)
+	for rows.Next() {
+		var name, virtual string
+		var collation, expr sql.NullString
+		if err := rows.Scan(&name, &collation, &virtual, &expr); err != nil {
+			return nil, err
+		}
+		attrs := ColumnSchema{Collation: collation.String}
+		if virtual == "YES" {
+			attrs.Generated = strings.TrimSpace(expr.String)
+		}
+		if attrs != (ColumnSchema{}) {
+			columns[name] = &attrs
+		}
+	}
+	return columns, rows.Err()
+}
+
+func (db *oracle) GetForeignKeys(tableName string) ([]*ForeignKey, error) {
+	args := []interface{}{tableName}
+	s := "SELECT c.CONSTRAINT_NAME, cc.COLUMN_NAME, rc.TABLE_NAME, rc.COLUMN_NAME, c.DELETE_RULE FROM USER_CONSTRAINTS c " +
+		"JOIN USER_CONS_COLUMNS cc ON cc.CONSTRAINT_NAME = c.CONSTRAINT_NAME " +
+		"JOIN USER_CONS_COLUMNS rc ON rc.CONSTRAINT_NAME = c.R_CONSTRAINT_NAME AND rc.POSITION = cc.POSITION " +
+		"WHERE c.CONSTRAINT_TYPE = 'R' AND c.TABLE_NAME = :1 ORDER BY c.CONSTRAINT_NAME, cc.POSITION"
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks []*ForeignKey
+	for rows.Next() {
+		var name, col, refTable, refCol, onDelete string
+		if err := rows.Scan(&name, &col, &refTable, &refCol, &onDelete); err != nil {
+			return nil, err
+		}
+		fks = appendForeignKeyColumn(fks, name, col, refTable, refCol, onDelete, "")
+	}
+	return fks, rows.Err()
+}
+
 func (db *oracle) IndexCheckSql(tableName, idxName string) (string, []interface{}) {
 	args := []interface{}{tableName, idxName}
 	return `SELECT INDEX_NAME FROM USER_INDEXES ` +
@@ -676,8 +824,9 @@ func (db *oracle) IsColumnExist(tableName, colName string) (bool, error) {
 
 func (db *oracle) GetColumns(tableName string) ([]string, map[string]*core.Column, error) {
 	args := []interface{}{tableName}
-	s := "SELECT column_name,data_default,data_type,data_length,data_precision,data_scale," +
-		"nullable FROM USER_TAB_COLUMNS WHERE table_name = :1"
+	s := "SELECT c.column_name,c.data_default,c.data_type,c.data_length,c.data_precision,c.data_scale," +
+		"c.nullable,cc.comments FROM USER_TAB_COLUMNS c LEFT JOIN USER_COL_COMMENTS cc " +
+		"ON cc.table_name = c.table_name AND cc.column_name = c.column_name WHERE c.table_name = :1"
 	db.LogSQL(s, args)
 
 	rows, err := db.DB().Query(s, args...)
@@ -692,16 +841,19 @@ func (db *oracle) GetColumns(tableName string) ([]string, map[string]*core.Colum
 		col := new(core.Column)
 		col.Indexes = make(map[string]int)
 
-		var colName, colDefault, nullable, dataType, dataPrecision, dataScale *string
+		var colName, colDefault, nullable, dataType, dataPrecision, dataScale, comment *string
 		var dataLen int
 
 		err = rows.Scan(&colName, &colDefault, &dataType, &dataLen, &dataPrecision,
-			&dataScale, &nullable)
+			&dataScale, &nullable, &comment)
 		if err != nil {
 			return nil, nil, err
 		}
 
 		col.Name = strings.Trim(*colName, `" `)
+		if comment != nil {
+			col.Comment = *comment
+		}
 		if colDefault != nil {
 			col.Default = *colDefault
 			col.DefaultIsEmpty = false
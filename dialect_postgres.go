@@ -5,6 +5,7 @@
 package xorm
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/url"
@@ -930,6 +931,159 @@ func (db *postgres) DropIndexSql(tableName string, index *core.Index) string {
 	return fmt.Sprintf("DROP INDEX %v", quote(idxName))
 }
 
+func (db *postgres) CreateTableSql(table *core.Table, tableName, storeEngine, charset string) string {
+	return db.CreateTableWithSchemaSql(table, tableName, storeEngine, charset, nil)
+}
+
+func (db *postgres) CreateTableWithSchemaSql(table *core.Table, tableName, storeEngine, charset string, schema *TableSchema) string {
+	return createTableSql(db, table, tableName, schema)
+}
+
+func (db *postgres) AddColumnSql(tableName string, col *core.Column, attrs *ColumnSchema) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), strings.TrimSpace(columnDefinition(db, col, col.IsPrimaryKey, attrs)))
+}
+
+func (db *postgres) AddForeignKeySql(tableName string, fk *ForeignKey) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), foreignKeyClause(db.Quote, fk))
+}
+
+func (db *postgres) AddCheckSql(tableName string, check *Check) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), checkClause(db.Quote, check))
+}
+
+func (db *postgres) GetChecks(tableName string) ([]*Check, error) {
+	args := []interface{}{tableName}
+	s := "SELECT c.conname, pg_get_constraintdef(c.oid) FROM pg_constraint c " +
+		"JOIN pg_class t ON t.oid = c.conrelid JOIN pg_namespace n ON n.oid = t.relnamespace " +
+		"WHERE c.contype = 'c' AND t.relname = $1"
+	if len(db.Schema) != 0 {
+		args = append(args, db.Schema)
+		s = s + " AND n.nspname = $2"
+	}
+	s = s + " ORDER BY c.conname"
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []*Check
+	for rows.Next() {
+		var name, def string
+		if err := rows.Scan(&name, &def); err != nil {
+			return nil, err
+		}
+		checks = append(checks, &Check{Name: name, Expr: unwrapCheck(def)})
+	}
+	return checks, rows.Err()
+}
+
+// GetColumnSchemas requires Postgres 12 or later, the collations are returned only
+// if they are not the default of the types
+func (db *postgres) GetColumnSchemas(tableName string) (map[string]*ColumnSchema, error) {
+	args := []interface{}{tableName}
+	s := "SELECT a.attname, CASE WHEN a.attcollation <> ty.typcollation THEN co.collname ELSE '' END, " +
+		"CASE WHEN a.attgenerated <> '' THEN pg_get_expr(d.adbin, d.adrelid) ELSE '' END, a.attgenerated " +
+		"FROM pg_attribute a JOIN pg_class t ON t.oid = a.attrelid JOIN pg_namespace n ON n.oid = t.relnamespace " +
+		"JOIN pg_type ty ON ty.oid = a.atttypid LEFT JOIN pg_collation co ON co.oid = a.attcollation " +
+		"LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum " +
+		"WHERE a.attnum > 0 AND NOT a.attisdropped AND t.relname = $1"
+	if len(db.Schema) != 0 {
+		args = append(args, db.Schema)
+		s = s + " AND n.nspname = $2"
+	}
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns = make(map[string]*ColumnSchema)
+	for rows.Next() {
+		var name, generatedType string
+		var collation, generated sql.NullString
+		if err := rows.Scan(&name, &collation, &generated, &generatedType); err != nil {
+			return nil, err
+		}
+		attrs := ColumnSchema{
+			Collation: collation.String,
+			Generated: generated.String,
+			Stored:    generatedType == "s",
+		}
+		if attrs != (ColumnSchema{}) {
+			columns[name] = &attrs
+		}
+	}
+	return columns, rows.Err()
+}
+
+// postgresReferentialActions maps the confdeltype and confupdtype of pg_constraint
+var postgresReferentialActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+func (db *postgres) GetForeignKeys(tableName string) ([]*ForeignKey, error) {
+	args := []interface{}{tableName}
+	s := "SELECT c.conname, a.attname, fc.relname, fa.attname, c.confdeltype, c.confupdtype FROM pg_constraint c " +
+		"JOIN pg_class t ON t.oid = c.conrelid JOIN pg_namespace n ON n.oid = t.relnamespace " +
+		"JOIN pg_class fc ON fc.oid = c.confrelid " +
+		"CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(attnum, fattnum, ord) " +
+		"JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum " +
+		"JOIN pg_attribute fa ON fa.attrelid = c.confrelid AND fa.attnum = k.fattnum " +
+		"WHERE c.contype = 'f' AND t.relname = $1"
+	if len(db.Schema) != 0 {
+		args = append(args, db.Schema)
+		s = s + " AND n.nspname = $2"
+	}
+	s = s + " ORDER BY c.conname, k.ord"
+	db.LogSQL(s, args)
+
+	rows, err := db.DB().Query(s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks []*ForeignKey
+	for rows.Next() {
+		var name, col, refTable, refCol, onDelete, onUpdate string
+		if err := rows.Scan(&name, &col, &refTable, &refCol, &onDelete, &onUpdate); err != nil {
+			return nil, err
+		}
+		fks = appendForeignKeyColumn(fks, name, col, refTable, refCol,
+			postgresReferentialActions[onDelete], postgresReferentialActions[onUpdate])
+	}
+	return fks, rows.Err()
+}
+
+func (db *postgres) UpsertSql(tableName string, colNames, conflictCols, updateCols []string, rows int) string {
+	return onConflictUpsertSql(db.Quote, tableName, colNames, conflictCols, updateCols, rows)
+}
+
+// onConflictUpsertSql generates INSERT ... ON CONFLICT ... DO UPDATE which is shared by postgres and sqlite3
+func onConflictUpsertSql(quote func(string) string, tableName string, colNames, conflictCols, updateCols []string, rows int) string {
+	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s ON CONFLICT (%s) ",
+		tableName, quoteJoin(colNames, quote, ", "), valuesPlaceholders(len(colNames), rows),
+		quoteJoin(conflictCols, quote, ", "))
+	if len(updateCols) == 0 {
+		return sql + "DO NOTHING"
+	}
+
+	sets := make([]string, 0, len(updateCols))
+	for _, col := range updateCols {
+		sets = append(sets, fmt.Sprintf("%s = excluded.%s", quote(col), quote(col)))
+	}
+	return sql + "DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
 func (db *postgres) IsColumnExist(tableName, colName string) (bool, error) {
 	args := []interface{}{db.Schema, tableName, colName}
 	query := "SELECT column_name FROM INFORMATION_SCHEMA.COLUMNS WHERE table_schema = $1 AND table_name = $2" +
@@ -954,7 +1108,8 @@ func (db *postgres) GetColumns(tableName string) ([]string, map[string]*core.Col
 	args := []interface{}{tableName}
 	s := `SELECT column_name, column_default, is_nullable, data_type, character_maximum_length,
     CASE WHEN p.contype = 'p' THEN true ELSE false END AS primarykey,
-    CASE WHEN p.contype = 'u' THEN true ELSE false END AS uniquekey
+    CASE WHEN p.contype = 'u' THEN true ELSE false END AS uniquekey,
+    col_description(f.attrelid, f.attnum) AS comment
 FROM pg_attribute f
     JOIN pg_class c ON c.oid = f.attrelid JOIN pg_type t ON t.oid = f.atttypid
     LEFT JOIN pg_attrdef d ON d.adrelid = c.oid AND d.adnum = f.attnum
@@ -987,9 +1142,9 @@ WHERE c.relkind = 'r'::char AND c.relname = $1%s AND f.attnum > 0 ORDER BY f.att
 		col.Indexes = make(map[string]int)
 
 		var colName, isNullable, dataType string
-		var maxLenStr, colDefault *string
+		var maxLenStr, colDefault, comment *string
 		var isPK, isUnique bool
-		err = rows.Scan(&colName, &colDefault, &isNullable, &dataType, &maxLenStr, &isPK, &isUnique)
+		err = rows.Scan(&colName, &colDefault, &isNullable, &dataType, &maxLenStr, &isPK, &isUnique, &comment)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -1004,6 +1159,9 @@ WHERE c.relkind = 'r'::char AND c.relname = $1%s AND f.attnum > 0 ORDER BY f.att
 		}
 
 		col.Name = strings.Trim(colName, `" `)
+		if comment != nil {
+			col.Comment = *comment
+		}
 
 		if colDefault != nil {
 			col.Default = *colDefault
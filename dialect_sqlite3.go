@@ -9,7 +9,9 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 
 	"xorm.io/core"
 )
@@ -145,12 +147,64 @@ var (
 
 type sqlite3 struct {
 	core.Base
+
+	versionLock sync.Mutex
+	version     string
 }
 
 func (db *sqlite3) Init(d *core.DB, uri *core.Uri, drivername, dataSourceName string) error {
 	return db.Base.Init(d, db, uri, drivername, dataSourceName)
 }
 
+// sqliteVersion returns the version of SQLite which is queried only once
+func (db *sqlite3) sqliteVersion() (string, error) {
+	db.versionLock.Lock()
+	defer db.versionLock.Unlock()
+
+	if db.version == "" {
+		s := "SELECT sqlite_version()"
+		db.LogSQL(s, nil)
+		if err := db.DB().QueryRow(s).Scan(&db.version); err != nil {
+			return "", err
+		}
+	}
+	return db.version, nil
+}
+
+// requireVersion returns an ErrSQLiteVersion if the version of SQLite is older than
+// the required version of the feature
+func (db *sqlite3) requireVersion(feature, required string) error {
+	version, err := db.sqliteVersion()
+	if err != nil {
+		return err
+	}
+	if compareVersions(version, required) < 0 {
+		return ErrSQLiteVersion{Feature: feature, Required: required, Version: version}
+	}
+	return nil
+}
+
+// compareVersions compares the dotted versions by their numbers
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
 func (db *sqlite3) SqlType(c *core.Column) string {
 	switch t := c.SQLType.Name; t {
 	case core.Bool:
@@ -244,6 +298,139 @@ func (db *sqlite3) DropIndexSql(tableName string, index *core.Index) string {
 	return fmt.Sprintf("DROP INDEX %v", quote(idxName))
 }
 
+func (db *sqlite3) CreateTableSql(table *core.Table, tableName, storeEngine, charset string) string {
+	return db.CreateTableWithSchemaSql(table, tableName, storeEngine, charset, nil)
+}
+
+// CreateTableWithSchemaSql requires SQLite 3.31.0 or later for the generated columns
+func (db *sqlite3) CreateTableWithSchemaSql(table *core.Table, tableName, storeEngine, charset string, schema *TableSchema) string {
+	return createTableSql(db, table, tableName, schema)
+}
+
+// AddColumnSql returns "" for the stored generated columns since SQLite can only add
+// them by rebuilding the table
+func (db *sqlite3) AddColumnSql(tableName string, col *core.Column, attrs *ColumnSchema) string {
+	if attrs != nil && attrs.Generated != "" && attrs.Stored {
+		return ""
+	}
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", db.Quote(tableName), strings.TrimSpace(columnDefinition(db, col, col.IsPrimaryKey, attrs)))
+}
+
+// AddCheckSql returns "" since the CHECK constraints of SQLite can only be added by
+// rebuilding the table
+func (db *sqlite3) AddCheckSql(tableName string, check *Check) string {
+	return ""
+}
+
+// AddForeignKeySql returns "" since the foreign keys of SQLite can only be added by
+// rebuilding the table
+func (db *sqlite3) AddForeignKeySql(tableName string, fk *ForeignKey) string {
+	return ""
+}
+
+// GetForeignKeys returns the foreign keys without names since SQLite does not
+// report the names of the constraints
+func (db *sqlite3) GetForeignKeys(tableName string) ([]*ForeignKey, error) {
+	s := fmt.Sprintf("PRAGMA foreign_key_list(%s)", db.Quote(tableName))
+	db.LogSQL(s, nil)
+
+	rows, err := db.DB().Query(s)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks []*ForeignKey
+	for rows.Next() {
+		var id, seq int
+		var refTable, col, onUpdate, onDelete, match string
+		var refCol sql.NullString
+		if err := rows.Scan(&id, &seq, &refTable, &col, &refCol, &onUpdate, &onDelete, &match); err != nil {
+			return nil, err
+		}
+		fks = appendForeignKeyColumn(fks, strconv.Itoa(id), col, refTable, refCol.String, onDelete, onUpdate)
+	}
+	for _, fk := range fks {
+		fk.Name = ""
+	}
+	return fks, rows.Err()
+}
+
+// checkConstraintRegexp matches the CHECK constraints of the table in CREATE TABLE
+var checkConstraintRegexp = regexp.MustCompile(`(?is)^\s*(?:CONSTRAINT\s+(\S+)\s+)?CHECK\s*(\(.*\))\s*$`)
+
+// GetChecks returns the CHECK constraints of the table, the constraints of the
+// columns are not returned
+func (db *sqlite3) GetChecks(tableName string) ([]*Check, error) {
+	defs, err := db.tableDefinitions(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var checks []*Check
+	for _, def := range defs {
+		matches := checkConstraintRegexp.FindStringSubmatch(def)
+		if matches == nil {
+			continue
+		}
+		checks = append(checks, &Check{
+			Name: strings.Trim(matches[1], "`[]\""),
+			Expr: unwrapCheck(matches[2]),
+		})
+	}
+	return checks, nil
+}
+
+var (
+	collateRegexp   = regexp.MustCompile(`(?i)\sCOLLATE\s+("[^"]*"|\S+)`)
+	generatedRegexp = regexp.MustCompile(`(?i)\s(?:GENERATED\s+ALWAYS\s+)?AS\s*\(`)
+)
+
+// GetColumnSchemas parses the collations and the generated columns from the
+// definitions of the columns in CREATE TABLE
+func (db *sqlite3) GetColumnSchemas(tableName string) (map[string]*ColumnSchema, error) {
+	defs, err := db.tableDefinitions(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var columns = make(map[string]*ColumnSchema)
+	for _, def := range defs {
+		def = strings.TrimSpace(def)
+		if tableConstraintRegexp.MatchString(def) || strings.HasPrefix(def, "PRIMARY KEY") {
+			continue
+		}
+
+		var attrs ColumnSchema
+		if matches := collateRegexp.FindStringSubmatch(def); matches != nil {
+			attrs.Collation = strings.Trim(matches[1], `"`)
+		}
+		if loc := generatedRegexp.FindStringIndex(def); loc != nil {
+			var depth = 1
+			var end = loc[1]
+			for ; end < len(def) && depth > 0; end++ {
+				switch def[end] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+			}
+			attrs.Generated = strings.TrimSpace(def[loc[1] : end-1])
+			attrs.Stored = strings.HasPrefix(strings.ToUpper(strings.TrimSpace(def[end:])), "STORED")
+		}
+		if attrs != (ColumnSchema{}) {
+			columns[strings.Trim(splitColStr(def)[0], "`[]\"")] = &attrs
+		}
+	}
+	return columns, nil
+}
+
+// UpsertSql requires SQLite 3.24.0 or later
+func (db *sqlite3) UpsertSql(tableName string, colNames, conflictCols, updateCols []string, rows int) string {
+	return onConflictUpsertSql(db.Quote, tableName, colNames, conflictCols, updateCols, rows)
+}
+
 func (db *sqlite3) ForUpdateSql(query string) string {
 	return query
 }
@@ -298,6 +485,38 @@ func splitColStr(colStr string) []string {
 	return results
 }
 
+// splitDefinitions splits the definitions of CREATE TABLE by the commas which are
+// not in the parentheses, the quoted identifiers or the string literals
+func splitDefinitions(defs string) []string {
+	var results []string
+	var depth, lastIdx int
+	var quote rune
+	for i, c := range defs {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			results = append(results, defs[lastIdx:i])
+			lastIdx = i + 1
+		}
+	}
+	return append(results, defs[lastIdx:])
+}
+
+// tableConstraintRegexp matches the definitions of CREATE TABLE which are the
+// constraints of the table except the primary key
+var tableConstraintRegexp = regexp.MustCompile(`(?i)^\s*(CONSTRAINT|FOREIGN\s+KEY|UNIQUE|CHECK)\b`)
+
 func parseString(colStr string) (*core.Column, error) {
 	fields := splitColStr(colStr)
 	col := new(core.Column)
@@ -332,13 +551,15 @@ func parseString(colStr string) (*core.Column, error) {
 	return col, nil
 }
 
-func (db *sqlite3) GetColumns(tableName string) ([]string, map[string]*core.Column, error) {
+// tableDefinitions returns the definitions of the columns and the constraints in
+// CREATE TABLE of the table
+func (db *sqlite3) tableDefinitions(tableName string) ([]string, error) {
 	args := []interface{}{tableName}
 	s := "SELECT sql FROM sqlite_master WHERE type='table' and name = ?"
 	db.LogSQL(s, args)
 	rows, err := db.DB().Query(s, args...)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -346,25 +567,34 @@ func (db *sqlite3) GetColumns(tableName string) ([]string, map[string]*core.Colu
 	for rows.Next() {
 		err = rows.Scan(&name)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 		break
 	}
 
 	if name == "" {
-		return nil, nil, errors.New("no table named " + tableName)
+		return nil, errors.New("no table named " + tableName)
 	}
 
 	nStart := strings.Index(name, "(")
 	nEnd := strings.LastIndex(name, ")")
-	reg := regexp.MustCompile(`[^\(,\)]*(\([^\(]*\))?`)
-	colCreates := reg.FindAllString(name[nStart+1:nEnd], -1)
+	return splitDefinitions(name[nStart+1 : nEnd]), nil
+}
+
+func (db *sqlite3) GetColumns(tableName string) ([]string, map[string]*core.Column, error) {
+	colCreates, err := db.tableDefinitions(tableName)
+	if err != nil {
+		return nil, nil, err
+	}
 	cols := make(map[string]*core.Column)
 	colSeq := make([]string, 0)
 
 	for _, colStr := range colCreates {
-		reg = regexp.MustCompile(`,\s`)
+		reg := regexp.MustCompile(`,\s`)
 		colStr = reg.ReplaceAllString(colStr, ",")
+		if tableConstraintRegexp.MatchString(colStr) {
+			continue
+		}
 		if strings.HasPrefix(strings.TrimSpace(colStr), "PRIMARY KEY") {
 			parts := strings.Split(strings.TrimSpace(colStr), "(")
 			if len(parts) == 2 {
@@ -446,7 +676,7 @@ func (db *sqlite3) GetIndexes(tableName string) (map[string]*core.Index, error)
 			continue
 		}
 
-		indexName := strings.Trim(sql[nNStart+6:nNEnd], "` []")
+		indexName := strings.Trim(sql[nNStart+6:nNEnd], "`\" []")
 		var isRegular bool
 		if strings.HasPrefix(indexName, "IDX_"+tableName) || strings.HasPrefix(indexName, "UQE_"+tableName) {
 			index.Name = indexName[5+len(tableName):]
@@ -467,7 +697,7 @@ func (db *sqlite3) GetIndexes(tableName string) (map[string]*core.Index, error)
 
 		index.Cols = make([]string, 0)
 		for _, col := range colIndexes {
-			index.Cols = append(index.Cols, strings.Trim(col, "` []"))
+			index.Cols = append(index.Cols, strings.Trim(col, "`\" []"))
 		}
 		index.IsRegular = isRegular
 		indexes[index.Name] = index
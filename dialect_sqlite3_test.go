@@ -33,3 +33,16 @@ func TestSplitColStr(t *testing.T) {
 		assert.EqualValues(t, kase.fields, splitColStr(kase.colStr))
 	}
 }
+
+func TestSplitDefinitions(t *testing.T) {
+	defs := splitDefinitions("`id` INTEGER PRIMARY KEY, `price` DECIMAL(10,2) NULL, `name` TEXT DEFAULT 'a,b', " +
+		"CONSTRAINT `FK_t_id` FOREIGN KEY (`id`, `name`) REFERENCES `u` (`id`, `name`)")
+	assert.EqualValues(t, []string{
+		"`id` INTEGER PRIMARY KEY",
+		" `price` DECIMAL(10,2) NULL",
+		" `name` TEXT DEFAULT 'a,b'",
+		" CONSTRAINT `FK_t_id` FOREIGN KEY (`id`, `name`) REFERENCES `u` (`id`, `name`)",
+	}, defs)
+	assert.True(t, tableConstraintRegexp.MatchString(defs[3]))
+	assert.False(t, tableConstraintRegexp.MatchString("`check_in` DATETIME"))
+}
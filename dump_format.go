@@ -0,0 +1,239 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"xorm.io/core"
+)
+
+// RowWriter writes the rows of a table, the values are in the order of the table's
+// columns and are nil, string, bool, int64, float64 or []byte for blob columns.
+type RowWriter interface {
+	WriteRow(values []interface{}) error
+	Flush() error
+}
+
+// RowReader reads the rows written by RowWriter, the values are in the order of the
+// table's columns and it returns io.EOF when there are no more rows.
+type RowReader interface {
+	ReadRow() ([]interface{}, error)
+}
+
+// DumpFormat is a data format of ExportTables and LoadTables
+type DumpFormat interface {
+	// Name is the name of the format which is used as the extension of the files
+	Name() string
+	NewRowWriter(w io.Writer, table *core.Table) (RowWriter, error)
+	NewRowReader(r io.Reader, table *core.Table) (RowReader, error)
+}
+
+var (
+	dumpFormats     = make(map[string]DumpFormat)
+	dumpFormatsLock sync.RWMutex
+)
+
+// RegisterDumpFormat registers a format so that LoadTables could read the files of it
+func RegisterDumpFormat(format DumpFormat) {
+	dumpFormatsLock.Lock()
+	defer dumpFormatsLock.Unlock()
+	dumpFormats[format.Name()] = format
+}
+
+// QueryDumpFormat returns the registered format by name
+func QueryDumpFormat(name string) DumpFormat {
+	dumpFormatsLock.RLock()
+	defer dumpFormatsLock.RUnlock()
+	return dumpFormats[name]
+}
+
+func init() {
+	RegisterDumpFormat(CSVFormat{})
+	RegisterDumpFormat(JSONLinesFormat{})
+}
+
+// csvNull represents NULL in CSV files like MySQL and Postgres do
+const csvNull = `\N`
+
+// CSVFormat writes a header line with the column names and then one line per row,
+// NULL is written as \N, backslashes in the other values are doubled so that they
+// could not be taken as NULL, and blobs are base64 encoded.
+type CSVFormat struct{}
+
+// Name implements DumpFormat
+func (CSVFormat) Name() string {
+	return "csv"
+}
+
+// NewRowWriter implements DumpFormat
+func (CSVFormat) NewRowWriter(w io.Writer, table *core.Table) (RowWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(table.ColumnsSeq()); err != nil {
+		return nil, err
+	}
+	return &csvRowWriter{w: cw}, nil
+}
+
+// NewRowReader implements DumpFormat
+func (CSVFormat) NewRowReader(r io.Reader, table *core.Table) (RowReader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	// the columns of the file may be in a different order
+	cols := table.ColumnsSeq()
+	var idxes = make([]int, len(cols))
+	for i, col := range cols {
+		idxes[i] = -1
+		for j, name := range header {
+			if strings.EqualFold(col, name) {
+				idxes[i] = j
+				break
+			}
+		}
+		if idxes[i] < 0 {
+			return nil, fmt.Errorf("column %s not found in the header of table %s", col, table.Name)
+		}
+	}
+	return &csvRowReader{r: cr, idxes: idxes}, nil
+}
+
+type csvRowWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (w *csvRowWriter) WriteRow(values []interface{}) error {
+	w.record = w.record[:0]
+	for _, v := range values {
+		switch t := v.(type) {
+		case nil:
+			w.record = append(w.record, csvNull)
+		case []byte:
+			w.record = append(w.record, base64.StdEncoding.EncodeToString(t))
+		default:
+			w.record = append(w.record, strings.Replace(fmt.Sprint(t), `\`, `\\`, -1))
+		}
+	}
+	return w.w.Write(w.record)
+}
+
+func (w *csvRowWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type csvRowReader struct {
+	r     *csv.Reader
+	idxes []int
+}
+
+func (r *csvRowReader) ReadRow() ([]interface{}, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	var values = make([]interface{}, len(r.idxes))
+	for i, idx := range r.idxes {
+		if record[idx] != csvNull {
+			values[i] = csvUnescape(record[idx])
+		}
+	}
+	return values, nil
+}
+
+// csvUnescape restores the doubled backslashes, the other backslashes are kept
+func csvUnescape(s string) string {
+	if !strings.Contains(s, `\\`) {
+		return s
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && s[i+1] == '\\' {
+			i++
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// JSONLinesFormat writes one JSON object per line whose keys are the column names,
+// blobs are base64 encoded.
+type JSONLinesFormat struct{}
+
+// Name implements DumpFormat
+func (JSONLinesFormat) Name() string {
+	return "jsonl"
+}
+
+// NewRowWriter implements DumpFormat
+func (JSONLinesFormat) NewRowWriter(w io.Writer, table *core.Table) (RowWriter, error) {
+	bw := bufio.NewWriter(w)
+	return &jsonRowWriter{w: bw, encoder: json.NewEncoder(bw), cols: table.ColumnsSeq()}, nil
+}
+
+// NewRowReader implements DumpFormat
+func (JSONLinesFormat) NewRowReader(r io.Reader, table *core.Table) (RowReader, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	return &jsonRowReader{decoder: decoder, cols: table.ColumnsSeq()}, nil
+}
+
+type jsonRowWriter struct {
+	w       *bufio.Writer
+	encoder *json.Encoder
+	cols    []string
+}
+
+func (w *jsonRowWriter) WriteRow(values []interface{}) error {
+	var row = make(map[string]interface{}, len(values))
+	for i, v := range values {
+		row[w.cols[i]] = v
+	}
+	return w.encoder.Encode(row)
+}
+
+func (w *jsonRowWriter) Flush() error {
+	return w.w.Flush()
+}
+
+type jsonRowReader struct {
+	decoder *json.Decoder
+	cols    []string
+}
+
+func (r *jsonRowReader) ReadRow() ([]interface{}, error) {
+	var row map[string]interface{}
+	if err := r.decoder.Decode(&row); err != nil {
+		return nil, err
+	}
+	var values = make([]interface{}, len(r.cols))
+	for i, col := range r.cols {
+		v, ok := row[col]
+		if !ok {
+			for key, value := range row {
+				if strings.EqualFold(key, col) {
+					v = value
+					break
+				}
+			}
+		}
+		if n, ok := v.(json.Number); ok {
+			v = n.String()
+		}
+		values[i] = v
+	}
+	return values, nil
+}
@@ -5,8 +5,6 @@
 package xorm
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"database/sql"
 	"encoding/gob"
@@ -55,6 +53,9 @@ type Engine struct {
 	cacherLock sync.RWMutex
 
 	defaultContext context.Context
+
+	defaultScopes     map[string][]Scope
+	defaultScopesLock sync.RWMutex
 }
 
 func (engine *Engine) setCacher(tableName string, cacher core.Cacher) {
@@ -449,144 +450,11 @@ func (engine *Engine) DumpTablesToFile(tables []*core.Table, fp string, tp ...co
 
 // DumpTables dump specify tables to io.Writer
 func (engine *Engine) DumpTables(tables []*core.Table, w io.Writer, tp ...core.DbType) error {
-	return engine.dumpTables(tables, w, tp...)
-}
-
-// dumpTables dump database all table structs and data to w with specify db type
-func (engine *Engine) dumpTables(tables []*core.Table, w io.Writer, tp ...core.DbType) error {
-	var dialect core.Dialect
-	var distDBName string
-	if len(tp) == 0 {
-		dialect = engine.dialect
-		distDBName = string(engine.dialect.DBType())
-	} else {
-		dialect = core.QueryDialect(tp[0])
-		if dialect == nil {
-			return errors.New("Unsupported database type")
-		}
-		dialect.Init(nil, engine.dialect.URI(), "", "")
-		distDBName = string(tp[0])
-	}
-
-	_, err := io.WriteString(w, fmt.Sprintf("/*Generated by xorm v%s %s, from %s to %s*/\n\n",
-		Version, time.Now().In(engine.TZLocation).Format("2006-01-02 15:04:05"), engine.dialect.DBType(), strings.ToUpper(distDBName)))
-	if err != nil {
-		return err
+	var opts DumpOptions
+	if len(tp) > 0 {
+		opts.DBType = tp[0]
 	}
-
-	for i, table := range tables {
-		if i > 0 {
-			_, err = io.WriteString(w, "\n")
-			if err != nil {
-				return err
-			}
-		}
-		_, err = io.WriteString(w, dialect.CreateTableSql(table, "", table.StoreEngine, "")+";\n")
-		if err != nil {
-			return err
-		}
-		for _, index := range table.Indexes {
-			_, err = io.WriteString(w, dialect.CreateIndexSql(table.Name, index)+";\n")
-			if err != nil {
-				return err
-			}
-		}
-
-		cols := table.ColumnsSeq()
-		colNames := engine.dialect.Quote(strings.Join(cols, engine.dialect.Quote(", ")))
-		destColNames := dialect.Quote(strings.Join(cols, dialect.Quote(", ")))
-
-		rows, err := engine.DB().Query("SELECT " + colNames + " FROM " + engine.Quote(table.Name))
-		if err != nil {
-			return err
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			dest := make([]interface{}, len(cols))
-			err = rows.ScanSlice(&dest)
-			if err != nil {
-				return err
-			}
-
-			_, err = io.WriteString(w, "INSERT INTO "+dialect.Quote(table.Name)+" ("+destColNames+") VALUES (")
-			if err != nil {
-				return err
-			}
-
-			var temp string
-			for i, d := range dest {
-				col := table.GetColumn(cols[i])
-				if col == nil {
-					return errors.New("unknow column error")
-				}
-
-				if d == nil {
-					temp += ", NULL"
-				} else if col.SQLType.IsText() || col.SQLType.IsTime() {
-					var v = fmt.Sprintf("%s", d)
-					if strings.HasSuffix(v, " +0000 UTC") {
-						temp += fmt.Sprintf(", '%s'", v[0:len(v)-len(" +0000 UTC")])
-					} else {
-						temp += ", '" + strings.Replace(v, "'", "''", -1) + "'"
-					}
-				} else if col.SQLType.IsBlob() {
-					if reflect.TypeOf(d).Kind() == reflect.Slice {
-						temp += fmt.Sprintf(", %s", dialect.FormatBytes(d.([]byte)))
-					} else if reflect.TypeOf(d).Kind() == reflect.String {
-						temp += fmt.Sprintf(", '%s'", d.(string))
-					}
-				} else if col.SQLType.IsNumeric() {
-					switch reflect.TypeOf(d).Kind() {
-					case reflect.Slice:
-						if col.SQLType.Name == core.Bool {
-							temp += fmt.Sprintf(", %v", strconv.FormatBool(d.([]byte)[0] != byte('0')))
-						} else {
-							temp += fmt.Sprintf(", %s", string(d.([]byte)))
-						}
-					case reflect.Int16, reflect.Int8, reflect.Int32, reflect.Int64, reflect.Int:
-						if col.SQLType.Name == core.Bool {
-							temp += fmt.Sprintf(", %v", strconv.FormatBool(reflect.ValueOf(d).Int() > 0))
-						} else {
-							temp += fmt.Sprintf(", %v", d)
-						}
-					case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-						if col.SQLType.Name == core.Bool {
-							temp += fmt.Sprintf(", %v", strconv.FormatBool(reflect.ValueOf(d).Uint() > 0))
-						} else {
-							temp += fmt.Sprintf(", %v", d)
-						}
-					default:
-						temp += fmt.Sprintf(", %v", d)
-					}
-				} else {
-					s := fmt.Sprintf("%v", d)
-					if strings.Contains(s, ":") || strings.Contains(s, "-") {
-						if strings.HasSuffix(s, " +0000 UTC") {
-							temp += fmt.Sprintf(", '%s'", s[0:len(s)-len(" +0000 UTC")])
-						} else {
-							temp += fmt.Sprintf(", '%s'", s)
-						}
-					} else {
-						temp += fmt.Sprintf(", %s", s)
-					}
-				}
-			}
-			_, err = io.WriteString(w, temp[2:]+");\n")
-			if err != nil {
-				return err
-			}
-		}
-
-		// FIXME: Hack for postgres
-		if string(dialect.DBType()) == core.POSTGRES && table.AutoIncrColumn() != nil {
-			_, err = io.WriteString(w, "SELECT setval('"+table.Name+"_id_seq', COALESCE((SELECT MAX("+table.AutoIncrColumn().Name+") + 1 FROM "+dialect.Quote(table.Name)+"), 1), false);\n")
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
+	return engine.DumpTablesWithOptions(tables, w, &opts)
 }
 
 // Cascade use cascade or not
@@ -986,7 +854,7 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 						ctx.nextTag = ""
 					}
 
-					if h, ok := engine.tagHandlers[ctx.tagName]; ok {
+					if h, ok := engine.tagHandlers[ctx.tagName]; ok && !isColumnNameTag(&ctx, pStart > -1) {
 						if err := h(&ctx); err != nil {
 							return nil, err
 						}
@@ -1006,6 +874,10 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 					}
 				}
 
+				if ctx.isRelation {
+					continue
+				}
+
 				if col.SQLType.Name == "" {
 					col.SQLType = core.Type2SQLType(fieldType)
 				}
@@ -1331,7 +1203,30 @@ func (engine *Engine) Sync2(beans ...interface{}) error {
 	return s.Sync2(beans...)
 }
 
-// CreateTables create tabls according bean
+// SyncWithOptions is Sync2 with options
+func (engine *Engine) SyncWithOptions(opts SyncOptions, beans ...interface{}) error {
+	s := engine.NewSession()
+	defer s.Close()
+	return s.SyncWithOptions(opts, beans...)
+}
+
+// PlanSync returns the changes which Sync2 would apply without changing the database
+func (engine *Engine) PlanSync(beans ...interface{}) (*SyncPlan, error) {
+	s := engine.NewSession()
+	defer s.Close()
+	return s.PlanSync(beans...)
+}
+
+// PlanSyncWithOptions returns the changes which SyncWithOptions would apply without
+// changing the database
+func (engine *Engine) PlanSyncWithOptions(opts SyncOptions, beans ...interface{}) (*SyncPlan, error) {
+	s := engine.NewSession()
+	defer s.Close()
+	return s.PlanSyncWithOptions(opts, beans...)
+}
+
+// CreateTables create tabls according bean, the tables referenced by the foreign keys
+// are created at first
 func (engine *Engine) CreateTables(beans ...interface{}) error {
 	session := engine.NewSession()
 	defer session.Close()
@@ -1341,7 +1236,7 @@ func (engine *Engine) CreateTables(beans ...interface{}) error {
 		return err
 	}
 
-	for _, bean := range beans {
+	for _, bean := range engine.sortBeansByForeignKeys(beans) {
 		err = session.createTable(bean)
 		if err != nil {
 			session.Rollback()
@@ -1420,6 +1315,55 @@ func (engine *Engine) InsertOne(bean interface{}) (int64, error) {
 	return session.InsertOne(bean)
 }
 
+// InsertChunkSize set how many records one insert statement contains when inserting multiple records
+func (engine *Engine) InsertChunkSize(size int) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.InsertChunkSize(size)
+}
+
+// WithAssociations makes Insert, Update and Delete also save or remove the relations
+func (engine *Engine) WithAssociations() *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.WithAssociations()
+}
+
+// Paginate enables keyset pagination for Find, FindAndCount and Rows
+func (engine *Engine) Paginate(page *Page) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Paginate(page)
+}
+
+// Preload loads the relations of the records for Find or Get
+func (engine *Engine) Preload(relations ...string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Preload(relations...)
+}
+
+// Returning loads the columns of the affected records back into the beans
+func (engine *Engine) Returning(columns ...string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Returning(columns...)
+}
+
+// OnConflict specifies the columns which detect the conflicting records for Upsert
+func (engine *Engine) OnConflict(columns ...string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.OnConflict(columns...)
+}
+
+// Upsert inserts records or updates the records which conflict with them
+func (engine *Engine) Upsert(beans interface{}, updateCols ...string) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.Upsert(beans, updateCols...)
+}
+
 // Update records, bean's non-empty fields are updated contents,
 // condiBean' non-empty filds are conditions
 // CAUTION:
@@ -1532,40 +1476,7 @@ func (engine *Engine) ImportFile(ddlPath string) ([]sql.Result, error) {
 
 // Import SQL DDL from io.Reader
 func (engine *Engine) Import(r io.Reader) ([]sql.Result, error) {
-	var results []sql.Result
-	var lastError error
-	scanner := bufio.NewScanner(r)
-
-	semiColSpliter := func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-		if atEOF && len(data) == 0 {
-			return 0, nil, nil
-		}
-		if i := bytes.IndexByte(data, ';'); i >= 0 {
-			return i + 1, data[0:i], nil
-		}
-		// If we're at EOF, we have a final, non-terminated line. Return it.
-		if atEOF {
-			return len(data), data, nil
-		}
-		// Request more data.
-		return 0, nil, nil
-	}
-
-	scanner.Split(semiColSpliter)
-
-	for scanner.Scan() {
-		query := strings.Trim(scanner.Text(), " \t\n\r")
-		if len(query) > 0 {
-			engine.logSQL(query)
-			result, err := engine.DB().Exec(query)
-			results = append(results, result)
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
-
-	return results, lastError
+	return engine.ImportWithOptions(r, nil)
 }
 
 // nowTime return current time
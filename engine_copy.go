@@ -0,0 +1,494 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// copyCheckpointDone is the checkpoint of the tables which have been copied
+const copyCheckpointDone = "done"
+
+// CopyCheckpointer loads and saves the checkpoints of the tables when copying, a
+// checkpoint is empty if the table has not been started.
+type CopyCheckpointer interface {
+	Load(table string) (string, error)
+	Save(table string, checkpoint string) error
+}
+
+type fileCopyCheckpointer struct {
+	path        string
+	lock        sync.Mutex
+	checkpoints map[string]string
+}
+
+// NewFileCopyCheckpointer returns a CopyCheckpointer which stores the checkpoints in
+// a JSON file
+func NewFileCopyCheckpointer(path string) (CopyCheckpointer, error) {
+	var c = fileCopyCheckpointer{
+		path:        path,
+		checkpoints: make(map[string]string),
+	}
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &c, nil
+		}
+		return nil, err
+	}
+	if len(bs) > 0 {
+		if err := json.Unmarshal(bs, &c.checkpoints); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+func (c *fileCopyCheckpointer) Load(table string) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.checkpoints[table], nil
+}
+
+func (c *fileCopyCheckpointer) Save(table string, checkpoint string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.checkpoints[table] = checkpoint
+	bs, err := json.Marshal(c.checkpoints)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file at first so that the file will not be broken
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, bs, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// CopyOptions represents the options of CopyToWithOptions
+type CopyOptions struct {
+	// BatchSize is the max rows of one INSERT statement, default is 100
+	BatchSize int
+	// Checkpointer saves the progress of every table so that an interrupted copy could
+	// be resumed. Every batch of the tables with primary keys is committed with a
+	// checkpoint, the tables without primary keys are copied in one transaction. The copy
+	// of a table which was not empty when it started cannot be resumed.
+	Checkpointer CopyCheckpointer
+	// Progress is called after each batch is inserted with the table name and the count
+	// of the rows copied of the table in this run
+	Progress func(table string, rows int64)
+}
+
+// CopyTo copies the structs and data of the tables to dst, all the tables will be
+// copied if no table specified.
+func (engine *Engine) CopyTo(dst *Engine, tables ...*core.Table) error {
+	return engine.CopyToWithOptions(dst, tables, nil)
+}
+
+// CopyToWithOptions copies the structs and data of the tables to dst which could be a
+// different database. The tables are created if not exist, the rows are read in one
+// snapshot transaction and inserted into dst by batches, and the sequences of the
+// autoincrement columns are reset after copying.
+func (engine *Engine) CopyToWithOptions(dst *Engine, tables []*core.Table, opts *CopyOptions) error {
+	var options CopyOptions
+	if opts != nil {
+		options = *opts
+	}
+
+	if len(tables) == 0 {
+		var err error
+		if tables, err = engine.DBMetas(); err != nil {
+			return err
+		}
+	}
+
+	ctx := engine.defaultContext
+	tx, err := engine.beginSnapshot(ctx, nil, "")
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range tables {
+		if err := engine.copyTable(ctx, tx, dst, table, &options); err != nil {
+			return fmt.Errorf("copy table %s: %v", table.Name, err)
+		}
+	}
+	return nil
+}
+
+// copyTable copies the table from the source transaction to dst
+func (engine *Engine) copyTable(ctx context.Context, tx *core.Tx, dst *Engine, table *core.Table, opts *CopyOptions) error {
+	var checkpoint string
+	if opts.Checkpointer != nil {
+		var err error
+		if checkpoint, err = opts.Checkpointer.Load(table.Name); err != nil {
+			return err
+		}
+		if checkpoint == copyCheckpointDone {
+			return nil
+		}
+	}
+
+	if err := dst.createCopyTable(table); err != nil {
+		return err
+	}
+
+	cols := table.ColumnsSeq()
+	var columns = make([]*core.Column, 0, len(cols))
+	for _, colName := range cols {
+		col := table.GetColumn(colName)
+		if col == nil {
+			return fmt.Errorf("column %s not found", colName)
+		}
+		columns = append(columns, col)
+	}
+	var pkIdxes []int
+	for _, pk := range table.PrimaryKeys {
+		for i, col := range columns {
+			if strings.EqualFold(col.Name, pk) {
+				pkIdxes = append(pkIdxes, i)
+				break
+			}
+		}
+	}
+	var resumable = opts.Checkpointer != nil && len(pkIdxes) > 0 && len(pkIdxes) == len(table.PrimaryKeys)
+
+	// the rows copied after the checkpoint should be removed since the checkpoint
+	// is saved after the batch is committed, it's only safe if the destination table
+	// was empty when the copy started, otherwise the existing rows may be removed
+	var srcCond builder.Cond
+	var cp copyCheckpoint
+	if checkpoint != "" && resumable {
+		var err error
+		if cp, err = decodeCopyCheckpoint(checkpoint); err != nil {
+			return err
+		}
+		if !cp.Empty {
+			return errors.New("cannot resume the copy since the destination table was not empty when the copy started")
+		}
+		var deleteSQL = "DELETE FROM " + dst.Quote(table.Name)
+		var deleteArgs []interface{}
+		if len(cp.Last) > 0 {
+			srcCond = keysetCond(table.PrimaryKeys, engine.Quote, cp.Last)
+			condSQL, condArgs, err := builder.ToSQL(keysetCond(table.PrimaryKeys, dst.Quote, cp.Last))
+			if err != nil {
+				return err
+			}
+			deleteSQL += " WHERE " + condSQL
+			deleteArgs = condArgs
+		}
+		if _, err := dst.Exec(append([]interface{}{deleteSQL}, deleteArgs...)...); err != nil {
+			return err
+		}
+	} else if resumable {
+		var err error
+		if cp.Empty, err = dst.IsTableEmpty(table.Name); err != nil {
+			return err
+		}
+		if err := cp.save(opts.Checkpointer, table.Name); err != nil {
+			return err
+		}
+	}
+
+	var sqlStr = "SELECT " + quoteJoin(cols, engine.Quote, ", ") + " FROM " + engine.Quote(table.Name)
+	var args []interface{}
+	if srcCond != nil {
+		condSQL, condArgs, err := builder.ToSQL(srcCond)
+		if err != nil {
+			return err
+		}
+		sqlStr += " WHERE " + condSQL
+		args = condArgs
+	}
+	if len(table.PrimaryKeys) > 0 {
+		sqlStr += " ORDER BY " + quoteJoin(table.PrimaryKeys, engine.Quote, ", ")
+	}
+	for _, filter := range engine.dialect.Filters() {
+		sqlStr = filter.Do(sqlStr, engine.dialect, table)
+	}
+
+	engine.logSQL(sqlStr, args...)
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	session := dst.NewSession()
+	defer session.Close()
+
+	inserter := newCopyInserter(session, table, cols, opts.BatchSize)
+	if !resumable {
+		if err := inserter.begin(); err != nil {
+			return err
+		}
+	}
+
+	var count int64
+	var batch [][]interface{}
+	var flush = func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if resumable {
+			if err := inserter.begin(); err != nil {
+				return err
+			}
+		}
+		if err := inserter.insert(batch); err != nil {
+			return err
+		}
+		if resumable {
+			if err := inserter.commit(); err != nil {
+				return err
+			}
+			last := batch[len(batch)-1]
+			cp.Last = make([]interface{}, 0, len(pkIdxes))
+			for _, idx := range pkIdxes {
+				cp.Last = append(cp.Last, last[idx])
+			}
+			if err := cp.save(opts.Checkpointer, table.Name); err != nil {
+				return err
+			}
+		}
+
+		count += int64(len(batch))
+		batch = batch[:0]
+		if opts.Progress != nil {
+			opts.Progress(table.Name, count)
+		}
+		return nil
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		if err := rows.ScanSlice(&values); err != nil {
+			return err
+		}
+		for i, v := range values {
+			values[i] = convertCopyValue(columns[i], v)
+		}
+		batch = append(batch, values)
+		if len(batch) >= inserter.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if !resumable {
+		if err := inserter.commit(); err != nil {
+			return err
+		}
+	}
+
+	if err := dst.resetSequence(table); err != nil {
+		return err
+	}
+	if opts.Checkpointer != nil {
+		return opts.Checkpointer.Save(table.Name, copyCheckpointDone)
+	}
+	return nil
+}
+
+// createCopyTable creates the table and its indexes if the table does not exist
+func (engine *Engine) createCopyTable(table *core.Table) error {
+	exist, err := engine.IsTableExist(table.Name)
+	if err != nil || exist {
+		return err
+	}
+
+	if _, err := engine.Exec(engine.dialect.CreateTableSql(table, "", table.StoreEngine, "")); err != nil {
+		return err
+	}
+	for _, index := range table.Indexes {
+		if _, err := engine.Exec(engine.dialect.CreateIndexSql(table.Name, index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetSequence resets the sequence of the autoincrement column after the rows were
+// inserted with explicit values, only Postgres needs it
+func (engine *Engine) resetSequence(table *core.Table) error {
+	col := table.AutoIncrColumn()
+	if col == nil || engine.dialect.DBType() != core.POSTGRES {
+		return nil
+	}
+	_, err := engine.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+		engine.TableName(table.Name, true), col.Name, engine.Quote(col.Name), engine.Quote(engine.TableName(table.Name, true))))
+	return err
+}
+
+// copyCheckpoint is the progress of a table with primary keys being copied
+type copyCheckpoint struct {
+	// Empty is whether the destination table was empty when the copy started
+	Empty bool `json:"empty"`
+	// Last is the primary key values of the last row committed
+	Last []interface{} `json:"last"`
+}
+
+func (cp *copyCheckpoint) save(checkpointer CopyCheckpointer, table string) error {
+	bs, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return checkpointer.Save(table, string(bs))
+}
+
+// decodeCopyCheckpoint returns the progress saved in the checkpoint
+func decodeCopyCheckpoint(checkpoint string) (copyCheckpoint, error) {
+	decoder := json.NewDecoder(strings.NewReader(checkpoint))
+	decoder.UseNumber()
+	var cp copyCheckpoint
+	if err := decoder.Decode(&cp); err != nil {
+		return cp, fmt.Errorf("invalid checkpoint %s: %v", checkpoint, err)
+	}
+	for i, v := range cp.Last {
+		if n, ok := v.(json.Number); ok {
+			if i64, err := n.Int64(); err == nil {
+				cp.Last[i] = i64
+			} else {
+				cp.Last[i] = n.String()
+			}
+		}
+	}
+	return cp, nil
+}
+
+// copyInserter inserts the rows into the table of the session by batches
+type copyInserter struct {
+	session   *Session
+	table     *core.Table
+	cols      []string
+	batchSize int
+	identity  bool
+}
+
+func newCopyInserter(session *Session, table *core.Table, cols []string, batchSize int) *copyInserter {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	switch session.engine.dialect.DBType() {
+	case core.ORACLE:
+		// oracle does not support multiple rows in VALUES
+		batchSize = 1
+	case core.MSSQL:
+		if batchSize > 1000 {
+			batchSize = 1000
+		}
+	}
+	if maxRows := session.insertMultiMaxArgs() / len(cols); batchSize > maxRows {
+		batchSize = maxRows
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	return &copyInserter{
+		session:   session,
+		table:     table,
+		cols:      cols,
+		batchSize: batchSize,
+		// MSSQL refuses the explicit values of identity columns by default
+		identity: session.engine.dialect.DBType() == core.MSSQL && table.AutoIncrColumn() != nil,
+	}
+}
+
+func (inserter *copyInserter) begin() error {
+	if err := inserter.session.Begin(); err != nil {
+		return err
+	}
+	return inserter.setIdentityInsert("ON")
+}
+
+func (inserter *copyInserter) commit() error {
+	if err := inserter.setIdentityInsert("OFF"); err != nil {
+		return err
+	}
+	return inserter.session.Commit()
+}
+
+func (inserter *copyInserter) setIdentityInsert(onOrOff string) error {
+	if !inserter.identity {
+		return nil
+	}
+	_, err := inserter.session.Exec("SET IDENTITY_INSERT " + inserter.session.engine.Quote(inserter.table.Name) + " " + onOrOff)
+	return err
+}
+
+func (inserter *copyInserter) insert(rows [][]interface{}) error {
+	var args = make([]interface{}, 0, len(rows)*len(inserter.cols)+1)
+	args = append(args, fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		inserter.session.engine.Quote(inserter.table.Name),
+		quoteJoin(inserter.cols, inserter.session.engine.Quote, ", "),
+		valuesPlaceholders(len(inserter.cols), len(rows))))
+	for _, row := range rows {
+		args = append(args, row...)
+	}
+	_, err := inserter.session.Exec(args...)
+	return err
+}
+
+// keysetCond returns the condition of the records after the values of the columns
+// in ascending order, e.g. (a > ?) OR (a = ? AND b > ?)
+func keysetCond(colNames []string, quote func(string) string, values []interface{}) builder.Cond {
+	var cond = builder.NewCond()
+	for i, colName := range colNames {
+		var seek = builder.NewCond()
+		for j := 0; j < i; j++ {
+			seek = seek.And(builder.Eq{quote(colNames[j]): values[j]})
+		}
+		cond = cond.Or(seek.And(builder.Gt{quote(colName): values[i]}))
+	}
+	return cond
+}
+
+// convertCopyValue converts the value scanned from the source database so that it
+// could be inserted into the column of another database
+func convertCopyValue(col *core.Column, v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case []byte:
+		if col.SQLType.IsBlob() {
+			return t
+		}
+		v = string(t)
+	}
+
+	if col.SQLType.Name != core.Bool && col.SQLType.Name != core.Boolean {
+		return v
+	}
+	switch t := v.(type) {
+	case int64:
+		return t != 0
+	case string:
+		if b, err := strconv.ParseBool(t); err == nil {
+			return b
+		}
+	}
+	return v
+}
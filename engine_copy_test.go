@@ -0,0 +1,145 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+type CopyUser struct {
+	Id     int64
+	Name   string
+	Active bool
+}
+
+func TestCopyTo(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	assertSync(t, new(CopyUser))
+
+	for i := 0; i < 5; i++ {
+		_, err := engine.Insert(&CopyUser{Name: "user's name", Active: i%2 == 0})
+		assert.NoError(t, err)
+	}
+	table, err := engine.autoMapType(rValue(new(CopyUser)))
+	assert.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "xorm_copy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dst, err := NewEngine("sqlite3", filepath.Join(dir, "dst.db"))
+	assert.NoError(t, err)
+	defer dst.Close()
+
+	checkpointer, err := NewFileCopyCheckpointer(filepath.Join(dir, "checkpoints.json"))
+	assert.NoError(t, err)
+
+	var progress int64
+	err = engine.CopyToWithOptions(dst, []*core.Table{table}, &CopyOptions{
+		BatchSize:    2,
+		Checkpointer: checkpointer,
+		Progress: func(table string, rows int64) {
+			progress = rows
+		},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, progress)
+
+	var users []CopyUser
+	assert.NoError(t, dst.Asc("id").Find(&users))
+	if assert.EqualValues(t, 5, len(users)) {
+		assert.EqualValues(t, "user's name", users[0].Name)
+		assert.True(t, users[0].Active)
+		assert.False(t, users[1].Active)
+	}
+
+	checkpoint, err := checkpointer.Load(table.Name)
+	assert.NoError(t, err)
+	assert.EqualValues(t, copyCheckpointDone, checkpoint)
+
+	// the copied tables are skipped
+	assert.NoError(t, engine.CopyToWithOptions(dst, []*core.Table{table}, &CopyOptions{Checkpointer: checkpointer}))
+	cnt, err := dst.Count(new(CopyUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, cnt)
+
+	// resume after the third record is inserted but the checkpoint is not saved
+	_, err = dst.Where("id > ?", 3).Delete(new(CopyUser))
+	assert.NoError(t, err)
+	assert.NoError(t, checkpointer.Save(table.Name, `{"empty":true,"last":[2]}`))
+
+	progress = 0
+	err = engine.CopyToWithOptions(dst, []*core.Table{table}, &CopyOptions{
+		Checkpointer: checkpointer,
+		Progress: func(table string, rows int64) {
+			progress = rows
+		},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, progress)
+
+	users = users[:0]
+	assert.NoError(t, dst.Asc("id").Find(&users))
+	assert.EqualValues(t, 5, len(users))
+}
+
+func TestCopyToNonEmpty(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	assertSync(t, new(CopyUser))
+
+	for i := 0; i < 5; i++ {
+		_, err := engine.Insert(&CopyUser{Name: "user"})
+		assert.NoError(t, err)
+	}
+	table, err := engine.autoMapType(rValue(new(CopyUser)))
+	assert.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "xorm_copy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dst, err := NewEngine("sqlite3", filepath.Join(dir, "dst.db"))
+	assert.NoError(t, err)
+	defer dst.Close()
+	assert.NoError(t, dst.Sync2(new(CopyUser)))
+	_, err = dst.Insert(&CopyUser{Id: 100, Name: "existing"})
+	assert.NoError(t, err)
+
+	checkpointer, err := NewFileCopyCheckpointer(filepath.Join(dir, "checkpoints.json"))
+	assert.NoError(t, err)
+	assert.NoError(t, engine.CopyToWithOptions(dst, []*core.Table{table}, &CopyOptions{
+		BatchSize:    2,
+		Checkpointer: checkpointer,
+	}))
+	cnt, err := dst.Count(new(CopyUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 6, cnt)
+
+	// the existing rows will not be removed by resuming
+	_, err = dst.Where("id > ? AND id < ?", 3, 100).Delete(new(CopyUser))
+	assert.NoError(t, err)
+	assert.NoError(t, checkpointer.Save(table.Name, `{"empty":false,"last":[2]}`))
+	assert.Error(t, engine.CopyToWithOptions(dst, []*core.Table{table}, &CopyOptions{Checkpointer: checkpointer}))
+
+	has, err := dst.ID(100).Exist(new(CopyUser))
+	assert.NoError(t, err)
+	assert.True(t, has)
+}
+
+func TestKeysetCond(t *testing.T) {
+	sql, args, err := builder.ToSQL(keysetCond([]string{"a", "b"}, func(s string) string { return s }, []interface{}{1, 2}))
+	assert.NoError(t, err)
+	assert.EqualValues(t, "(a>?) OR (a=? AND b>?)", sql)
+	assert.EqualValues(t, []interface{}{1, 1, 2}, args)
+}
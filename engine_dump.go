@@ -0,0 +1,460 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"xorm.io/core"
+)
+
+// DumpOptions represents the options of DumpTablesWithOptions and DumpTablesParallel
+type DumpOptions struct {
+	// DBType is the database type of the generated SQL, default is the engine's
+	DBType core.DbType
+	// BatchSize is the max rows of one INSERT statement, default is 1
+	BatchSize int
+	// Gzip compresses the output with gzip
+	Gzip bool
+	// Parallel is the max tables dumped concurrently by DumpTablesParallel, default is 1
+	Parallel int
+	// TxOptions is used to begin the transaction if it's not nil, default is a plain
+	// transaction which is repeatable read on MySQL and Postgres, and snapshot isolation
+	// on MSSQL if ALLOW_SNAPSHOT_ISOLATION is on, otherwise the database's default
+	TxOptions *sql.TxOptions
+	// Progress is called after each INSERT statement is written with the table name and
+	// the count of the rows dumped of the table, the calls are serialized
+	Progress func(table string, rows int64)
+}
+
+type dumper struct {
+	engine       *Engine
+	dialect      core.Dialect
+	distDBName   string
+	batchSize    int
+	opts         DumpOptions
+	progressLock sync.Mutex
+}
+
+func (engine *Engine) newDumper(opts *DumpOptions) (*dumper, error) {
+	var d = dumper{engine: engine}
+	if opts != nil {
+		d.opts = *opts
+	}
+
+	if d.opts.DBType == "" {
+		d.dialect = engine.dialect
+		d.distDBName = string(engine.dialect.DBType())
+	} else {
+		d.dialect = core.QueryDialect(d.opts.DBType)
+		if d.dialect == nil {
+			return nil, errors.New("Unsupported database type")
+		}
+		d.dialect.Init(nil, engine.dialect.URI(), "", "")
+		d.distDBName = string(d.opts.DBType)
+	}
+
+	d.batchSize = d.opts.BatchSize
+	if d.batchSize <= 0 {
+		d.batchSize = 1
+	}
+	switch d.dialect.DBType() {
+	case core.ORACLE:
+		// oracle does not support multiple rows in VALUES
+		d.batchSize = 1
+	case core.MSSQL:
+		if d.batchSize > 1000 {
+			d.batchSize = 1000
+		}
+	}
+	return &d, nil
+}
+
+// DumpTablesWithOptions dumps the structs and data of the tables to w in one transaction,
+// so the data of all the tables are consistent if the transaction reads from a snapshot,
+// see DumpOptions.TxOptions.
+func (engine *Engine) DumpTablesWithOptions(tables []*core.Table, w io.Writer, opts *DumpOptions) error {
+	d, err := engine.newDumper(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx := engine.defaultContext
+	tx, err := engine.beginSnapshot(ctx, d.opts.TxOptions, "")
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return d.write(w, func(bw *bufio.Writer) error {
+		if err := d.writeHeader(bw); err != nil {
+			return err
+		}
+		for i, table := range tables {
+			if i > 0 {
+				if _, err := bw.WriteString("\n"); err != nil {
+					return err
+				}
+			}
+			if err := d.dumpTable(ctx, tx, table, bw); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DumpTablesParallel dumps every table to the writer returned by open, which will be
+// closed after the table is dumped. At most opts.Parallel tables are dumped concurrently,
+// each of them in its own transaction. On Postgres all the transactions share one
+// exported snapshot, on other databases the data of different tables may be inconsistent
+// if they are modified during dumping unless opts.Parallel is 1.
+func (engine *Engine) DumpTablesParallel(tables []*core.Table, open func(table *core.Table) (io.WriteCloser, error), opts *DumpOptions) error {
+	d, err := engine.newDumper(opts)
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	var workers = d.opts.Parallel
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(tables) {
+		workers = len(tables)
+	}
+
+	ctx, cancel := context.WithCancel(engine.defaultContext)
+	defer cancel()
+
+	// the snapshot transaction should be kept until all the tables are dumped
+	var sharedTx *core.Tx
+	var snapshot string
+	if workers == 1 || engine.dialect.DBType() == core.POSTGRES {
+		sharedTx, err = engine.beginSnapshot(ctx, d.opts.TxOptions, "")
+		if err != nil {
+			return err
+		}
+		defer sharedTx.Rollback()
+
+		if workers > 1 {
+			if err = sharedTx.QueryRowContext(ctx, "SELECT pg_export_snapshot()").Scan(&snapshot); err != nil {
+				return err
+			}
+		}
+	}
+
+	var (
+		next     int32 = -1
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	var setErr = func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var tx = sharedTx
+			if workers > 1 {
+				var err error
+				tx, err = engine.beginSnapshot(ctx, d.opts.TxOptions, snapshot)
+				if err != nil {
+					setErr(err)
+					return
+				}
+				defer tx.Rollback()
+			}
+
+			for ctx.Err() == nil {
+				i := int(atomic.AddInt32(&next, 1))
+				if i >= len(tables) {
+					return
+				}
+				if err := d.dumpTableTo(ctx, tx, tables[i], open); err != nil {
+					setErr(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// dumpTableTo dumps the table to the writer returned by open
+func (d *dumper) dumpTableTo(ctx context.Context, tx *core.Tx, table *core.Table, open func(table *core.Table) (io.WriteCloser, error)) error {
+	w, err := open(table)
+	if err != nil {
+		return err
+	}
+	err = d.write(w, func(bw *bufio.Writer) error {
+		if err := d.writeHeader(bw); err != nil {
+			return err
+		}
+		return d.dumpTable(ctx, tx, table, bw)
+	})
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// beginSnapshot begins the transaction in which the tables are read, if snapshot is not
+// empty the exported snapshot of Postgres is imported. If opts is nil, a plain transaction
+// is begun so that the drivers not supporting the options work, and the snapshot is got
+// by the dialect: the isolation level is set to repeatable read on Postgres, SNAPSHOT
+// isolation is used on MSSQL only if ALLOW_SNAPSHOT_ISOLATION is on, and on MySQL the
+// default repeatable read of InnoDB reads from the snapshot of the first read.
+func (engine *Engine) beginSnapshot(ctx context.Context, opts *sql.TxOptions, snapshot string) (*core.Tx, error) {
+	var stmts []string
+	if opts == nil {
+		switch engine.dialect.DBType() {
+		case core.POSTGRES:
+			stmts = append(stmts, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ")
+		case core.MSSQL:
+			var state int
+			err := engine.DB().QueryRowContext(ctx, "SELECT snapshot_isolation_state FROM sys.databases WHERE name = DB_NAME()").Scan(&state)
+			if err == nil && state == 1 {
+				opts = &sql.TxOptions{Isolation: sql.LevelSnapshot}
+			}
+		}
+	}
+	if snapshot != "" {
+		stmts = append(stmts, "SET TRANSACTION SNAPSHOT '"+snapshot+"'")
+	}
+
+	tx, err := engine.DB().BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// write buffers and compresses the output to w if necessary
+func (d *dumper) write(w io.Writer, f func(bw *bufio.Writer) error) error {
+	var gw *gzip.Writer
+	if d.opts.Gzip {
+		gw = gzip.NewWriter(w)
+		w = gw
+	}
+	bw := bufio.NewWriter(w)
+	if err := f(bw); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if gw != nil {
+		return gw.Close()
+	}
+	return nil
+}
+
+func (d *dumper) writeHeader(w *bufio.Writer) error {
+	_, err := fmt.Fprintf(w, "/*Generated by xorm v%s %s, from %s to %s*/\n\n",
+		Version, time.Now().In(d.engine.TZLocation).Format("2006-01-02 15:04:05"), d.engine.dialect.DBType(), strings.ToUpper(d.distDBName))
+	return err
+}
+
+// tableSchema returns the CHECK constraints and the attributes of the columns of the
+// table if the SQL is for the same database, the foreign keys are left out since the
+// referenced tables may be dumped later. It returns nil if they cannot be read.
+func (d *dumper) tableSchema(table *core.Table) *TableSchema {
+	dialect, ok := d.engine.dialect.(schemaDialect)
+	if !ok || d.dialect.DBType() != d.engine.dialect.DBType() {
+		return nil
+	}
+	checks, err := dialect.GetChecks(table.Name)
+	if err != nil {
+		d.engine.logger.Warnf("dump table %s without the CHECK constraints: %v", table.Name, err)
+		return nil
+	}
+	columns, err := dialect.GetColumnSchemas(table.Name)
+	if err != nil {
+		d.engine.logger.Warnf("dump table %s without the generated columns and the collations: %v", table.Name, err)
+		return nil
+	}
+	return &TableSchema{Checks: checks, Columns: columns}
+}
+
+// dumpTable writes the struct of the table and streams its rows as INSERT statements,
+// the generated columns are not inserted
+func (d *dumper) dumpTable(ctx context.Context, tx *core.Tx, table *core.Table, w *bufio.Writer) error {
+	schema := d.tableSchema(table)
+	createSQL := d.dialect.CreateTableSql(table, "", table.StoreEngine, "")
+	if dialect, ok := d.dialect.(schemaDialect); ok && !schema.isEmpty() {
+		createSQL = dialect.CreateTableWithSchemaSql(table, "", table.StoreEngine, "", schema)
+	}
+	if _, err := w.WriteString(createSQL + ";\n"); err != nil {
+		return err
+	}
+	for _, index := range table.Indexes {
+		if _, err := w.WriteString(d.dialect.CreateIndexSql(table.Name, index) + ";\n"); err != nil {
+			return err
+		}
+	}
+
+	var cols []string
+	var columns = make([]*core.Column, 0, len(table.ColumnsSeq()))
+	for _, colName := range table.ColumnsSeq() {
+		col := table.GetColumn(colName)
+		if col == nil {
+			return errors.New("unknow column error")
+		}
+		if schema.Column(colName).generated() != "" {
+			continue
+		}
+		cols = append(cols, colName)
+		columns = append(columns, col)
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT "+quoteJoin(cols, d.engine.Quote, ", ")+" FROM "+d.engine.Quote(table.Name))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	insertPrefix := "INSERT INTO " + d.dialect.Quote(table.Name) + " (" + quoteJoin(cols, d.dialect.Quote, ", ") + ") VALUES "
+	var count int64
+	var batch = make([]string, 0, d.batchSize)
+	var flush = func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := w.WriteString(insertPrefix + strings.Join(batch, ", ") + ";\n"); err != nil {
+			return err
+		}
+		count += int64(len(batch))
+		batch = batch[:0]
+		d.progress(table.Name, count)
+		return nil
+	}
+
+	var values = make([]string, len(columns))
+	for rows.Next() {
+		dest := make([]interface{}, len(cols))
+		if err := rows.ScanSlice(&dest); err != nil {
+			return err
+		}
+		for i, v := range dest {
+			values[i] = formatDumpValue(d.dialect, columns[i], v)
+		}
+		batch = append(batch, "("+strings.Join(values, ", ")+")")
+		if len(batch) >= d.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	// FIXME: Hack for postgres
+	if string(d.dialect.DBType()) == core.POSTGRES && table.AutoIncrColumn() != nil {
+		_, err := w.WriteString("SELECT setval('" + table.Name + "_id_seq', COALESCE((SELECT MAX(" + table.AutoIncrColumn().Name + ") + 1 FROM " + d.dialect.Quote(table.Name) + "), 1), false);\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dumper) progress(tableName string, rows int64) {
+	if d.opts.Progress == nil {
+		return
+	}
+	d.progressLock.Lock()
+	defer d.progressLock.Unlock()
+	d.opts.Progress(tableName, rows)
+}
+
+// formatDumpValue returns the SQL literal of the value scanned from the column
+func formatDumpValue(dialect core.Dialect, col *core.Column, d interface{}) string {
+	if d == nil {
+		return "NULL"
+	}
+
+	if col.SQLType.IsText() || col.SQLType.IsTime() {
+		var v = fmt.Sprintf("%s", d)
+		if strings.HasSuffix(v, " +0000 UTC") {
+			return fmt.Sprintf("'%s'", v[0:len(v)-len(" +0000 UTC")])
+		}
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	}
+
+	if col.SQLType.IsBlob() {
+		switch v := d.(type) {
+		case []byte:
+			return dialect.FormatBytes(v)
+		case string:
+			return "'" + strings.Replace(v, "'", "''", -1) + "'"
+		}
+		return fmt.Sprintf("'%v'", d)
+	}
+
+	if col.SQLType.IsNumeric() {
+		switch reflect.TypeOf(d).Kind() {
+		case reflect.Slice:
+			if col.SQLType.Name == core.Bool {
+				return strconv.FormatBool(d.([]byte)[0] != byte('0'))
+			}
+			return string(d.([]byte))
+		case reflect.Int16, reflect.Int8, reflect.Int32, reflect.Int64, reflect.Int:
+			if col.SQLType.Name == core.Bool {
+				return strconv.FormatBool(reflect.ValueOf(d).Int() > 0)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if col.SQLType.Name == core.Bool {
+				return strconv.FormatBool(reflect.ValueOf(d).Uint() > 0)
+			}
+		}
+		return fmt.Sprintf("%v", d)
+	}
+
+	s := fmt.Sprintf("%v", d)
+	if strings.Contains(s, ":") || strings.Contains(s, "-") {
+		if strings.HasSuffix(s, " +0000 UTC") {
+			return fmt.Sprintf("'%s'", s[0:len(s)-len(" +0000 UTC")])
+		}
+		return fmt.Sprintf("'%s'", s)
+	}
+	return s
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+type DumpUser struct {
+	Id   int64
+	Name string
+}
+
+type DumpGroup struct {
+	Id    int64
+	Title string
+}
+
+func prepareDumpTables(t *testing.T, engine *Engine) []*core.Table {
+	assertSync(t, new(DumpUser), new(DumpGroup))
+
+	for i := 0; i < 5; i++ {
+		_, err := engine.Insert(&DumpUser{Name: "user's name"}, &DumpGroup{Title: "group"})
+		assert.NoError(t, err)
+	}
+
+	var tables []*core.Table
+	for _, bean := range []interface{}{new(DumpUser), new(DumpGroup)} {
+		table, err := engine.autoMapType(rValue(bean))
+		assert.NoError(t, err)
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+func TestDumpTablesWithOptions(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	tables := prepareDumpTables(t, engine)
+
+	var progress = make(map[string]int64)
+	var buf bytes.Buffer
+	err := engine.DumpTablesWithOptions(tables, &buf, &DumpOptions{
+		BatchSize: 2,
+		Gzip:      true,
+		Progress: func(table string, rows int64) {
+			progress[table] = rows
+		},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, progress["dump_user"])
+	assert.EqualValues(t, 5, progress["dump_group"])
+
+	gr, err := gzip.NewReader(&buf)
+	assert.NoError(t, err)
+	content, err := ioutil.ReadAll(gr)
+	assert.NoError(t, err)
+
+	// 5 rows with batch size 2 need 3 INSERT statements
+	assert.EqualValues(t, 3, strings.Count(string(content), "INSERT INTO "+engine.Quote("dump_user")))
+	assert.EqualValues(t, 3, strings.Count(string(content), "INSERT INTO "+engine.Quote("dump_group")))
+
+	assert.NoError(t, engine.DropTables(new(DumpUser), new(DumpGroup)))
+	_, err = engine.Import(bytes.NewReader(content))
+	assert.NoError(t, err)
+
+	var users []DumpUser
+	assert.NoError(t, engine.Asc("id").Find(&users))
+	assert.EqualValues(t, 5, len(users))
+	assert.EqualValues(t, "user's name", users[0].Name)
+}
+
+type dumpBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *dumpBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestDumpTablesParallel(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	tables := prepareDumpTables(t, engine)
+
+	var lock sync.Mutex
+	var buffers = make(map[string]*dumpBuffer)
+	err := engine.DumpTablesParallel(tables, func(table *core.Table) (io.WriteCloser, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		buffers[table.Name] = new(dumpBuffer)
+		return buffers[table.Name], nil
+	}, &DumpOptions{Parallel: 2})
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, 2, len(buffers))
+	for _, table := range tables {
+		buf := buffers[table.Name]
+		if assert.NotNil(t, buf) {
+			assert.True(t, buf.closed)
+			// one row per INSERT statement by default
+			assert.EqualValues(t, 5, strings.Count(buf.String(), "INSERT INTO "+engine.Quote(table.Name)))
+		}
+	}
+}
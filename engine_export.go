@@ -0,0 +1,317 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"xorm.io/core"
+)
+
+// ManifestFileName is the name of the manifest file written by ExportTables
+const ManifestFileName = "manifest.json"
+
+// Manifest describes the tables and the files written by ExportTables
+type Manifest struct {
+	Version string          `json:"version"`
+	DBType  core.DbType     `json:"db_type"`
+	Format  string          `json:"format"`
+	Tables  []ManifestTable `json:"tables"`
+}
+
+// ManifestTable describes a table and its data file
+type ManifestTable struct {
+	Name        string           `json:"name"`
+	File        string           `json:"file"`
+	Rows        int64            `json:"rows"`
+	StoreEngine string           `json:"store_engine,omitempty"`
+	Columns     []ManifestColumn `json:"columns"`
+	Indexes     []ManifestIndex  `json:"indexes,omitempty"`
+}
+
+// ManifestColumn describes a column of core.Table
+type ManifestColumn struct {
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	Length          int    `json:"length,omitempty"`
+	Length2         int    `json:"length2,omitempty"`
+	Nullable        bool   `json:"nullable"`
+	Default         string `json:"default,omitempty"`
+	IsPrimaryKey    bool   `json:"is_primary_key,omitempty"`
+	IsAutoIncrement bool   `json:"is_auto_increment,omitempty"`
+	Comment         string `json:"comment,omitempty"`
+}
+
+// ManifestIndex describes an index of core.Table
+type ManifestIndex struct {
+	Name   string   `json:"name"`
+	Unique bool     `json:"unique,omitempty"`
+	Cols   []string `json:"cols"`
+}
+
+func newManifestTable(table *core.Table, file string) ManifestTable {
+	var mt = ManifestTable{
+		Name:        table.Name,
+		File:        file,
+		StoreEngine: table.StoreEngine,
+	}
+	for _, col := range table.Columns() {
+		mt.Columns = append(mt.Columns, ManifestColumn{
+			Name:            col.Name,
+			Type:            col.SQLType.Name,
+			Length:          col.Length,
+			Length2:         col.Length2,
+			Nullable:        col.Nullable,
+			Default:         col.Default,
+			IsPrimaryKey:    col.IsPrimaryKey,
+			IsAutoIncrement: col.IsAutoIncrement,
+			Comment:         col.Comment,
+		})
+	}
+	for _, index := range table.Indexes {
+		mt.Indexes = append(mt.Indexes, ManifestIndex{
+			Name:   index.Name,
+			Unique: index.Type == core.UniqueType,
+			Cols:   index.Cols,
+		})
+	}
+	sort.Slice(mt.Indexes, func(i, j int) bool {
+		return mt.Indexes[i].Name < mt.Indexes[j].Name
+	})
+	return mt
+}
+
+// Table returns the core.Table described by the manifest
+func (mt *ManifestTable) Table() *core.Table {
+	table := core.NewEmptyTable()
+	table.Name = mt.Name
+	table.StoreEngine = mt.StoreEngine
+	for _, mc := range mt.Columns {
+		col := core.NewColumn(mc.Name, "", core.SQLType{Name: mc.Type, DefaultLength: mc.Length, DefaultLength2: mc.Length2},
+			mc.Length, mc.Length2, mc.Nullable)
+		col.Default = mc.Default
+		col.IsPrimaryKey = mc.IsPrimaryKey
+		col.IsAutoIncrement = mc.IsAutoIncrement
+		col.Comment = mc.Comment
+		table.AddColumn(col)
+	}
+	for _, mi := range mt.Indexes {
+		var indexType = core.IndexType
+		if mi.Unique {
+			indexType = core.UniqueType
+		}
+		index := core.NewIndex(mi.Name, indexType)
+		index.AddColumn(mi.Cols...)
+		table.AddIndex(index)
+	}
+	return table
+}
+
+// ExportTables writes the rows of every table to a file named as the table with the
+// format's extension in dir and a manifest describing the tables. All the tables are
+// read in one snapshot transaction.
+func (engine *Engine) ExportTables(dir string, format DumpFormat, tables ...*core.Table) error {
+	if len(tables) == 0 {
+		var err error
+		if tables, err = engine.DBMetas(); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	ctx := engine.defaultContext
+	tx, err := engine.beginSnapshot(ctx, nil, "")
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var manifest = Manifest{
+		Version: Version,
+		DBType:  engine.dialect.DBType(),
+		Format:  format.Name(),
+	}
+	for _, table := range tables {
+		mt := newManifestTable(table, table.Name+"."+format.Name())
+		if mt.Rows, err = engine.exportTable(tx, table, format, filepath.Join(dir, mt.File)); err != nil {
+			return fmt.Errorf("export table %s: %v", table.Name, err)
+		}
+		manifest.Tables = append(manifest.Tables, mt)
+	}
+
+	bs, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, ManifestFileName), bs, 0644)
+}
+
+// exportTable writes the rows of the table to the file and returns the count of the rows
+func (engine *Engine) exportTable(tx *core.Tx, table *core.Table, format DumpFormat, fp string) (int64, error) {
+	f, err := os.Create(fp)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	w, err := format.NewRowWriter(f, table)
+	if err != nil {
+		return 0, err
+	}
+
+	cols := table.ColumnsSeq()
+	var columns = make([]*core.Column, 0, len(cols))
+	for _, colName := range cols {
+		col := table.GetColumn(colName)
+		if col == nil {
+			return 0, fmt.Errorf("column %s not found", colName)
+		}
+		columns = append(columns, col)
+	}
+
+	sqlStr := "SELECT " + quoteJoin(cols, engine.Quote, ", ") + " FROM " + engine.Quote(table.Name)
+	if len(table.PrimaryKeys) > 0 {
+		sqlStr += " ORDER BY " + quoteJoin(table.PrimaryKeys, engine.Quote, ", ")
+	}
+	engine.logSQL(sqlStr)
+	rows, err := tx.QueryContext(engine.defaultContext, sqlStr)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		if err := rows.ScanSlice(&values); err != nil {
+			return 0, err
+		}
+		for i, v := range values {
+			values[i] = engine.exportValue(columns[i], v)
+		}
+		if err := w.WriteRow(values); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	return count, f.Close()
+}
+
+// exportValue converts the value scanned from the column to the types of RowWriter
+func (engine *Engine) exportValue(col *core.Column, v interface{}) interface{} {
+	v = convertCopyValue(col, v)
+	if t, ok := v.(time.Time); ok {
+		return engine.formatTime(col.SQLType.Name, t)
+	}
+	return v
+}
+
+// LoadTables reads the manifest and the files written by ExportTables in dir, creates
+// the tables if not exist and inserts the rows by batches, batchSize is the max rows
+// of one INSERT statement and default is 100.
+func (engine *Engine) LoadTables(dir string, batchSize int) error {
+	bs, err := ioutil.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(bs, &manifest); err != nil {
+		return err
+	}
+	format := QueryDumpFormat(manifest.Format)
+	if format == nil {
+		return fmt.Errorf("unknown dump format %s", manifest.Format)
+	}
+
+	for _, mt := range manifest.Tables {
+		if err := engine.loadTable(filepath.Join(dir, mt.File), mt.Table(), format, batchSize); err != nil {
+			return fmt.Errorf("load table %s: %v", mt.Name, err)
+		}
+	}
+	return nil
+}
+
+func (engine *Engine) loadTable(fp string, table *core.Table, format DumpFormat, batchSize int) error {
+	f, err := os.Open(fp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := format.NewRowReader(f, table)
+	if err != nil {
+		return err
+	}
+
+	if err := engine.createCopyTable(table); err != nil {
+		return err
+	}
+
+	session := engine.NewSession()
+	defer session.Close()
+
+	cols := table.ColumnsSeq()
+	columns := table.Columns()
+	inserter := newCopyInserter(session, table, cols, batchSize)
+	if err := inserter.begin(); err != nil {
+		return err
+	}
+
+	var batch [][]interface{}
+	for {
+		values, err := r.ReadRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for i, v := range values {
+			if values[i], err = loadValue(columns[i], v); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, values)
+		if len(batch) >= inserter.batchSize {
+			if err := inserter.insert(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := inserter.insert(batch); err != nil {
+			return err
+		}
+	}
+	if err := inserter.commit(); err != nil {
+		return err
+	}
+	return engine.resetSequence(table)
+}
+
+// loadValue converts the value read by RowReader to the value of the column
+func loadValue(col *core.Column, v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok && col.SQLType.IsBlob() {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return convertCopyValue(col, v), nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+type ExportRecord struct {
+	Id      int64
+	Name    string `xorm:"index"`
+	Note    *string
+	Data    []byte
+	Active  bool
+	Created time.Time
+}
+
+func TestDumpFormats(t *testing.T) {
+	table := core.NewEmptyTable()
+	table.Name = "t"
+	table.AddColumn(core.NewColumn("id", "", core.SQLType{Name: core.BigInt}, 0, 0, false))
+	table.AddColumn(core.NewColumn("name", "", core.SQLType{Name: core.Varchar}, 0, 0, true))
+	table.AddColumn(core.NewColumn("data", "", core.SQLType{Name: core.Blob}, 0, 0, true))
+
+	for _, format := range []DumpFormat{CSVFormat{}, JSONLinesFormat{}} {
+		assert.EqualValues(t, format, QueryDumpFormat(format.Name()))
+
+		var buf bytes.Buffer
+		w, err := format.NewRowWriter(&buf, table)
+		assert.NoError(t, err)
+		assert.NoError(t, w.WriteRow([]interface{}{int64(1), "a,\"b\"\nc", []byte{0, 1}}))
+		assert.NoError(t, w.WriteRow([]interface{}{int64(2), nil, nil}))
+		assert.NoError(t, w.WriteRow([]interface{}{int64(3), `\N`, nil}))
+		assert.NoError(t, w.WriteRow([]interface{}{int64(4), `a\b\\`, nil}))
+		assert.NoError(t, w.Flush())
+
+		r, err := format.NewRowReader(&buf, table)
+		assert.NoError(t, err)
+		values, err := r.ReadRow()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{"1", "a,\"b\"\nc", "AAE="}, values, format.Name())
+		values, err = r.ReadRow()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{"2", nil, nil}, values, format.Name())
+		// the text \N and backslashes are not taken as NULL
+		values, err = r.ReadRow()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{"3", `\N`, nil}, values, format.Name())
+		values, err = r.ReadRow()
+		assert.NoError(t, err)
+		assert.EqualValues(t, []interface{}{"4", `a\b\\`, nil}, values, format.Name())
+		_, err = r.ReadRow()
+		assert.EqualValues(t, io.EOF, err)
+	}
+}
+
+func TestExportTables(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	assertSync(t, new(ExportRecord))
+
+	var note = "it's a note"
+	var created = time.Date(2019, 10, 1, 8, 30, 0, 0, engine.TZLocation)
+	_, err := engine.Insert(&ExportRecord{Name: "a", Note: &note, Data: []byte("data"), Active: true, Created: created},
+		&ExportRecord{Name: "b", Created: created})
+	assert.NoError(t, err)
+	table, err := engine.autoMapType(rValue(new(ExportRecord)))
+	assert.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "xorm_export")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, format := range []DumpFormat{CSVFormat{}, JSONLinesFormat{}} {
+		exportDir := filepath.Join(dir, format.Name())
+		assert.NoError(t, engine.ExportTables(exportDir, format, table))
+
+		dst, err := NewEngine("sqlite3", filepath.Join(dir, format.Name()+".db"))
+		assert.NoError(t, err)
+		dst.SetTZLocation(engine.TZLocation)
+		dst.SetTZDatabase(engine.DatabaseTZ)
+
+		assert.NoError(t, dst.LoadTables(exportDir, 1))
+
+		var records []ExportRecord
+		assert.NoError(t, dst.Asc("id").Find(&records))
+		if assert.EqualValues(t, 2, len(records), format.Name()) {
+			assert.EqualValues(t, "a", records[0].Name)
+			if assert.NotNil(t, records[0].Note) {
+				assert.EqualValues(t, note, *records[0].Note)
+			}
+			assert.EqualValues(t, "data", string(records[0].Data))
+			assert.True(t, records[0].Active)
+			assert.EqualValues(t, created.Unix(), records[0].Created.Unix())
+			assert.Nil(t, records[1].Note)
+			assert.False(t, records[1].Active)
+		}
+
+		indexes, err := dst.DBMetas()
+		assert.NoError(t, err)
+		if assert.EqualValues(t, 1, len(indexes)) {
+			assert.EqualValues(t, 1, len(indexes[0].Indexes))
+		}
+		assert.NoError(t, dst.Close())
+	}
+}
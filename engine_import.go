@@ -0,0 +1,455 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	"xorm.io/core"
+)
+
+// ImportOptions represents the options of ImportWithOptions
+type ImportOptions struct {
+	// Transaction runs all the statements in one transaction, it will be rollback
+	// if any statement failed unless ContinueOnError is set
+	Transaction bool
+	// ContinueOnError executes the remaining statements when a statement failed, the
+	// errors will be returned as ImportErrors. If Transaction is set too, every statement
+	// is executed in a savepoint so that only the failed statements are rollback.
+	ContinueOnError bool
+	// Progress is called after each statement is executed with the count of the
+	// executed statements and the bytes read from the input
+	Progress func(statements int, bytesRead int64)
+}
+
+// ImportError represents a failed statement when importing
+type ImportError struct {
+	Index int // the index of the statement, starts from 0
+	Query string
+	Err   error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("statement %d failed: %v", e.Index+1, e.Err)
+}
+
+// ImportErrors are the failed statements when importing with ContinueOnError
+type ImportErrors []*ImportError
+
+func (errs ImportErrors) Error() string {
+	var msgs = make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ImportWithOptions executes the SQL statements read from r. The statements are
+// separated by semicolons which are not in quotes or comments, MySQL DELIMITER
+// commands, Postgres dollar quoted bodies and MSSQL GO lines are supported. The
+// result of a failed statement is nil.
+func (engine *Engine) ImportWithOptions(r io.Reader, opts *ImportOptions) ([]sql.Result, error) {
+	var options ImportOptions
+	if opts != nil {
+		options = *opts
+	}
+
+	session := engine.NewSession()
+	defer session.Close()
+	if options.Transaction {
+		if err := session.Begin(); err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := session.importStatements(r, &options)
+	if err != nil {
+		if _, ok := err.(ImportErrors); !ok {
+			return nil, err
+		}
+	}
+	if options.Transaction {
+		if err := session.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	return results, err
+}
+
+// Import executes the SQL statements read from r in the session, they are executed
+// in the transaction of the session if it has begun.
+func (session *Session) Import(r io.Reader) ([]sql.Result, error) {
+	return session.importStatements(r, &ImportOptions{})
+}
+
+// importStatements splits the statements read from r and executes them one by one, with
+// ContinueOnError in a transaction every statement is executed in a savepoint.
+func (session *Session) importStatements(r io.Reader, options *ImportOptions) ([]sql.Result, error) {
+	var engine = session.engine
+	var exec = func(query string) (sql.Result, error) {
+		engine.logSQL(query)
+		session.saveLastSQL(query)
+		if session.isAutoCommit {
+			return session.DB().ExecContext(session.ctx, query)
+		}
+		return session.tx.ExecContext(session.ctx, query)
+	}
+	var useSavepoint = !session.isAutoCommit && options.ContinueOnError
+
+	var results []sql.Result
+	var errs ImportErrors
+	var bytesRead int64
+	splitter := newSQLSplitter(engine.dialect.DBType())
+	reader := bufio.NewReader(r)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, readErr
+		}
+		bytesRead += int64(len(line))
+
+		var queries = splitter.feed(line)
+		if readErr == io.EOF {
+			if query := splitter.flush(); query != "" {
+				queries = append(queries, query)
+			}
+		}
+
+		for _, query := range queries {
+			if useSavepoint {
+				if err := session.Begin(); err != nil {
+					return nil, err
+				}
+			}
+			result, err := exec(query)
+			if useSavepoint {
+				var spErr error
+				if err != nil {
+					spErr = session.Rollback()
+				} else {
+					spErr = session.Commit()
+				}
+				if spErr != nil {
+					return nil, spErr
+				}
+			}
+
+			if err != nil {
+				importErr := &ImportError{Index: len(results), Query: query, Err: err}
+				if !options.ContinueOnError {
+					return nil, importErr
+				}
+				errs = append(errs, importErr)
+				result = nil
+			}
+			results = append(results, result)
+			if options.Progress != nil {
+				options.Progress(len(results), bytesRead)
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if len(errs) > 0 {
+		return results, errs
+	}
+	return results, nil
+}
+
+// the kinds of the statements whose bodies contain delimiters
+const (
+	sqlBlockNone    = iota
+	sqlBlockTrigger // SQLite trigger whose body is between BEGIN and END
+	sqlBlockPLSQL   // Oracle PL/SQL unit which ends with / on its own line
+)
+
+// sqlSplitter splits SQL statements line by line, the quotes, comments and
+// dialect specific delimiters are tracked across lines.
+type sqlSplitter struct {
+	dbType    core.DbType
+	delimiter string
+	buf       bytes.Buffer
+	hasCode   bool     // whether the buffer has anything except comments and spaces
+	quote     byte     // the closing quote if in a quoted string or identifier
+	escape    bool     // whether backslash escapes in the quoted string
+	comment   int      // the depth of the block comments
+	dollarTag string   // the tag of the postgres dollar quoted string
+	word      []byte   // the keyword or identifier being read
+	head      []string // the leading keywords of the statement
+	block     int      // the kind of the statement's body
+	depth     int      // the depth of BEGIN and CASE in the trigger body
+}
+
+func newSQLSplitter(dbType core.DbType) *sqlSplitter {
+	return &sqlSplitter{
+		dbType:    dbType,
+		delimiter: ";",
+	}
+}
+
+// feed splits the line and returns the completed statements
+func (s *sqlSplitter) feed(line string) []string {
+	var queries []string
+	var emit = func() {
+		if query := s.take(); query != "" {
+			queries = append(queries, query)
+		}
+	}
+
+	if s.quote == 0 && s.comment == 0 && s.dollarTag == "" {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case s.dbType == core.MYSQL && len(trimmed) > 10 && strings.EqualFold(trimmed[:10], "DELIMITER "):
+			emit()
+			s.delimiter = strings.TrimSpace(trimmed[10:])
+			return queries
+		case s.dbType == core.MSSQL && strings.EqualFold(trimmed, "GO"):
+			emit()
+			return queries
+		case s.dbType == core.ORACLE && trimmed == "/":
+			emit()
+			return queries
+		}
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		var next byte
+		if i+1 < len(line) {
+			next = line[i+1]
+		}
+		if len(s.word) > 0 && !isIdentByte(c) {
+			s.endWord()
+		}
+
+		switch {
+		case s.quote != 0:
+			s.buf.WriteByte(c)
+			if s.escape && c == '\\' && next != 0 {
+				s.buf.WriteByte(next)
+				i++
+			} else if c == s.quote {
+				if next == s.quote {
+					// doubled quote is an escaped quote
+					s.buf.WriteByte(next)
+					i++
+				} else {
+					s.quote = 0
+				}
+			}
+		case s.comment > 0:
+			s.buf.WriteByte(c)
+			if c == '*' && next == '/' {
+				s.buf.WriteByte(next)
+				i++
+				s.comment--
+			} else if c == '/' && next == '*' && s.dbType == core.POSTGRES {
+				// postgres supports nested block comments
+				s.buf.WriteByte(next)
+				i++
+				s.comment++
+			}
+		case s.dollarTag != "":
+			if strings.HasPrefix(line[i:], s.dollarTag) {
+				s.buf.WriteString(s.dollarTag)
+				i += len(s.dollarTag) - 1
+				s.dollarTag = ""
+			} else {
+				s.buf.WriteByte(c)
+			}
+		case strings.HasPrefix(line[i:], s.delimiter) && s.inBody():
+			s.buf.WriteString(s.delimiter)
+			i += len(s.delimiter) - 1
+		case strings.HasPrefix(line[i:], s.delimiter):
+			i += len(s.delimiter) - 1
+			emit()
+		case c == '-' && next == '-' && s.isLineComment(line[i+2:]), c == '#' && s.dbType == core.MYSQL:
+			// the line comment lasts to the end of the line
+			s.buf.WriteString(line[i:])
+			i = len(line)
+		case c == '/' && next == '*':
+			s.buf.WriteString("/*")
+			i++
+			s.comment = 1
+			if s.dbType == core.MYSQL && strings.HasPrefix(line[i+1:], "!") {
+				// the executable comment of MySQL
+				s.hasCode = true
+			}
+		case c == '$' && s.dbType == core.POSTGRES && !isIdentByte(s.lastByte()):
+			if tag := dollarTag(line[i:]); tag != "" {
+				s.buf.WriteString(tag)
+				i += len(tag) - 1
+				s.dollarTag = tag
+			} else {
+				s.buf.WriteByte(c)
+			}
+			s.hasCode = true
+		default:
+			switch c {
+			case '\'':
+				s.quote = c
+				last := s.lastByte()
+				s.escape = s.dbType == core.MYSQL ||
+					(s.dbType == core.POSTGRES && (last == 'E' || last == 'e'))
+			case '"':
+				s.quote = c
+				s.escape = s.dbType == core.MYSQL
+			case '`':
+				s.quote = c
+				s.escape = false
+			case '[':
+				if s.dbType == core.MSSQL || s.dbType == core.SQLITE {
+					s.quote = ']'
+					s.escape = false
+				}
+			}
+			s.buf.WriteByte(c)
+			if !isSpaceByte(c) {
+				s.hasCode = true
+			}
+			if s.quote == 0 && isIdentByte(c) {
+				s.word = append(s.word, c)
+			}
+		}
+	}
+	if len(s.word) > 0 {
+		s.endWord()
+	}
+	return queries
+}
+
+// endWord recognizes the statements whose bodies contain delimiters by the leading
+// keywords, and tracks the depth of BEGIN ... END in the body of SQLite triggers
+func (s *sqlSplitter) endWord() {
+	word := strings.ToUpper(string(s.word))
+	s.word = s.word[:0]
+
+	switch s.block {
+	case sqlBlockTrigger:
+		switch word {
+		case "BEGIN", "CASE":
+			s.depth++
+		case "END":
+			if s.depth > 0 {
+				s.depth--
+			}
+		}
+		return
+	case sqlBlockPLSQL:
+		return
+	}
+
+	if len(s.head) >= 4 {
+		return
+	}
+	s.head = append(s.head, word)
+	switch s.dbType {
+	case core.SQLITE:
+		if isStatementOf(s.head, "TRIGGER", "TEMP", "TEMPORARY") {
+			s.block = sqlBlockTrigger
+		}
+	case core.ORACLE:
+		if s.head[0] == "DECLARE" || s.head[0] == "BEGIN" ||
+			isStatementOf(s.head, "PROCEDURE", "OR", "REPLACE", "EDITIONABLE", "NONEDITIONABLE") ||
+			isStatementOf(s.head, "FUNCTION", "OR", "REPLACE", "EDITIONABLE", "NONEDITIONABLE") ||
+			isStatementOf(s.head, "PACKAGE", "OR", "REPLACE", "EDITIONABLE", "NONEDITIONABLE") ||
+			isStatementOf(s.head, "TRIGGER", "OR", "REPLACE", "EDITIONABLE", "NONEDITIONABLE") ||
+			isStatementOf(s.head, "TYPE", "OR", "REPLACE", "EDITIONABLE", "NONEDITIONABLE") {
+			s.block = sqlBlockPLSQL
+		}
+	}
+}
+
+// inBody reports whether the delimiter is in the body of a trigger or PL/SQL unit
+// and does not end the statement
+func (s *sqlSplitter) inBody() bool {
+	return s.block == sqlBlockPLSQL || (s.block == sqlBlockTrigger && s.depth > 0)
+}
+
+// isStatementOf reports whether the last word of head is the object and the words
+// between CREATE and it are all modifiers, e.g. CREATE TEMP TRIGGER
+func isStatementOf(head []string, object string, modifiers ...string) bool {
+	if len(head) < 2 || head[0] != "CREATE" || head[len(head)-1] != object {
+		return false
+	}
+	for _, word := range head[1 : len(head)-1] {
+		var ok bool
+		for _, modifier := range modifiers {
+			if word == modifier {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// flush returns the remaining statement which has no delimiter
+func (s *sqlSplitter) flush() string {
+	return s.take()
+}
+
+// take returns the statement in the buffer and resets the buffer, comments
+// only statements are ignored
+func (s *sqlSplitter) take() string {
+	query := strings.TrimSpace(s.buf.String())
+	hasCode := s.hasCode
+	s.buf.Reset()
+	s.hasCode = false
+	s.word = s.word[:0]
+	s.head = nil
+	s.block = sqlBlockNone
+	s.depth = 0
+	if !hasCode {
+		return ""
+	}
+	return query
+}
+
+// isLineComment reports whether -- followed by rest starts a comment, MySQL needs
+// a space after --
+func (s *sqlSplitter) isLineComment(rest string) bool {
+	return s.dbType != core.MYSQL || rest == "" || isSpaceByte(rest[0])
+}
+
+func (s *sqlSplitter) lastByte() byte {
+	if s.buf.Len() == 0 {
+		return 0
+	}
+	return s.buf.Bytes()[s.buf.Len()-1]
+}
+
+// dollarTag returns the tag like $$ or $body$ at the beginning of s
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '$' {
+			return s[:i+1]
+		}
+		if !isIdentByte(s[i]) || (i == 1 && s[i] >= '0' && s[i] <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
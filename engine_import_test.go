@@ -0,0 +1,170 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+func splitSQL(dbType core.DbType, input string) []string {
+	splitter := newSQLSplitter(dbType)
+	var queries []string
+	for _, line := range strings.SplitAfter(input, "\n") {
+		queries = append(queries, splitter.feed(line)...)
+	}
+	if query := splitter.flush(); query != "" {
+		queries = append(queries, query)
+	}
+	return queries
+}
+
+func TestSQLSplitter(t *testing.T) {
+	var kases = []struct {
+		dbType  core.DbType
+		input   string
+		queries []string
+	}{
+		{
+			core.SQLITE,
+			"INSERT INTO a VALUES ('x;y', 'it''s;');\nSELECT 1; SELECT 2",
+			[]string{"INSERT INTO a VALUES ('x;y', 'it''s;')", "SELECT 1", "SELECT 2"},
+		},
+		{
+			core.SQLITE,
+			"-- comment; only\n/* block; comment */\nSELECT \"a;b\", [c;d] FROM t;\n-- trailing",
+			[]string{"-- comment; only\n/* block; comment */\nSELECT \"a;b\", [c;d] FROM t"},
+		},
+		{
+			core.MYSQL,
+			"INSERT INTO a VALUES ('it\\'s;', \"x;\"); # comment;\nDELIMITER //\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END//\nDELIMITER ;\nSELECT 3;",
+			[]string{"INSERT INTO a VALUES ('it\\'s;', \"x;\")", "CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", "SELECT 3"},
+		},
+		{
+			core.MYSQL,
+			"/*!40101 SET NAMES utf8 */;\nSELECT 1--1;",
+			[]string{"/*!40101 SET NAMES utf8 */", "SELECT 1--1"},
+		},
+		{
+			core.POSTGRES,
+			"CREATE FUNCTION f() RETURNS int AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql;\nSELECT E'a\\';b', $1;",
+			[]string{"CREATE FUNCTION f() RETURNS int AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql", "SELECT E'a\\';b', $1"},
+		},
+		{
+			core.POSTGRES,
+			"/* outer /* inner; */ still; */ SELECT 1;",
+			[]string{"/* outer /* inner; */ still; */ SELECT 1"},
+		},
+		{
+			core.MSSQL,
+			"CREATE PROCEDURE p AS\nBEGIN\n  SELECT 1\nEND\nGO\nSELECT [a;b] FROM t;",
+			[]string{"CREATE PROCEDURE p AS\nBEGIN\n  SELECT 1\nEND", "SELECT [a;b] FROM t"},
+		},
+		{
+			core.SQLITE,
+			"CREATE TEMP TRIGGER t AFTER INSERT ON a\nBEGIN\n  UPDATE b SET n = CASE WHEN n > 0 THEN n END;\n  DELETE FROM c;\nEND;\nSELECT 1;",
+			[]string{"CREATE TEMP TRIGGER t AFTER INSERT ON a\nBEGIN\n  UPDATE b SET n = CASE WHEN n > 0 THEN n END;\n  DELETE FROM c;\nEND", "SELECT 1"},
+		},
+		{
+			core.SQLITE,
+			"BEGIN;\nCREATE TABLE end_time (a);\nCOMMIT;",
+			[]string{"BEGIN", "CREATE TABLE end_time (a)", "COMMIT"},
+		},
+		{
+			core.ORACLE,
+			"CREATE OR REPLACE PROCEDURE p AS\nBEGIN\n  UPDATE a SET n = 1;\nEND;\n/\nSELECT 1 FROM dual;\nBEGIN\n  p;\nEND;\n/\nSELECT 2 FROM dual\n/\n",
+			[]string{"CREATE OR REPLACE PROCEDURE p AS\nBEGIN\n  UPDATE a SET n = 1;\nEND;", "SELECT 1 FROM dual", "BEGIN\n  p;\nEND;", "SELECT 2 FROM dual"},
+		},
+	}
+
+	for _, kase := range kases {
+		assert.EqualValues(t, kase.queries, splitSQL(kase.dbType, kase.input), kase.input)
+	}
+}
+
+type ImportRecord struct {
+	Id   int64
+	Name string `xorm:"unique"`
+}
+
+func TestImportWithOptions(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	assertSync(t, new(ImportRecord))
+
+	var tableName = engine.Quote(engine.TableName(new(ImportRecord), true))
+	var input = "INSERT INTO " + tableName + " (" + engine.Quote("name") + ") VALUES ('a;1');\n" +
+		"INSERT INTO " + tableName + " (" + engine.Quote("name") + ") VALUES ('a;1');\n" +
+		"INSERT INTO " + tableName + " (" + engine.Quote("name") + ") VALUES ('b;2');\n"
+
+	// the duplicated record fails the transaction
+	_, err := engine.ImportWithOptions(strings.NewReader(input), &ImportOptions{Transaction: true})
+	assert.Error(t, err)
+	importErr, ok := err.(*ImportError)
+	if assert.True(t, ok) {
+		assert.EqualValues(t, 1, importErr.Index)
+	}
+	cnt, err := engine.Count(new(ImportRecord))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	var progress int
+	results, err := engine.ImportWithOptions(strings.NewReader(input), &ImportOptions{
+		Transaction:     true,
+		ContinueOnError: true,
+		Progress: func(statements int, bytesRead int64) {
+			progress = statements
+		},
+	})
+	assert.EqualValues(t, 3, progress)
+	assert.EqualValues(t, 3, len(results))
+	assert.Nil(t, results[1])
+	errs, ok := err.(ImportErrors)
+	if assert.True(t, ok) && assert.EqualValues(t, 1, len(errs)) {
+		assert.EqualValues(t, 1, errs[0].Index)
+	}
+
+	var records []ImportRecord
+	assert.NoError(t, engine.Asc("id").Find(&records))
+	if assert.EqualValues(t, 2, len(records)) {
+		assert.EqualValues(t, "a;1", records[0].Name)
+		assert.EqualValues(t, "b;2", records[1].Name)
+	}
+}
+
+type ImportAudit struct {
+	Id   int64
+	Name string
+}
+
+func TestImportTrigger(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	if engine.dialect.DBType() != core.SQLITE {
+		t.Skip("the trigger syntax is of SQLite")
+	}
+	assertSync(t, new(ImportRecord), new(ImportAudit))
+
+	var input = "DROP TRIGGER IF EXISTS import_record_audit;\n" +
+		"CREATE TRIGGER import_record_audit AFTER INSERT ON import_record\n" +
+		"BEGIN\n" +
+		"  INSERT INTO import_audit (name) VALUES (CASE WHEN NEW.name = '' THEN 'empty' ELSE NEW.name END);\n" +
+		"END;\n" +
+		"INSERT INTO import_record (name) VALUES ('a');\n"
+	results, err := engine.Import(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, len(results))
+
+	var audits []ImportAudit
+	assert.NoError(t, engine.Find(&audits))
+	if assert.EqualValues(t, 1, len(audits)) {
+		assert.EqualValues(t, "a", audits[0].Name)
+	}
+	_, err = engine.Exec("DROP TRIGGER import_record_audit")
+	assert.NoError(t, err)
+}
@@ -28,6 +28,10 @@ var (
 	ErrConditionType = errors.New("Unsupported condition type")
 	// ErrUnSupportedSQLType parameter of SQL is not supported
 	ErrUnSupportedSQLType = errors.New("unsupported sql type")
+	// ErrConflictColumnsNotFound no primary key or unique index could be used to detect conflicts for upsert
+	ErrConflictColumnsNotFound = errors.New("no conflict columns found for upsert")
+	// ErrInvalidCursor the cursor of the page cannot be decoded
+	ErrInvalidCursor = errors.New("invalid page cursor")
 )
 
 // ErrFieldIsNotExist columns does not exist
@@ -49,3 +53,27 @@ type ErrFieldIsNotValid struct {
 func (e ErrFieldIsNotValid) Error() string {
 	return fmt.Sprintf("field %s is not valid on table %s", e.FieldName, e.TableName)
 }
+
+// ErrSQLiteVersion is returned when a feature requires a newer version of SQLite
+type ErrSQLiteVersion struct {
+	Feature  string
+	Required string
+	Version  string
+}
+
+func (e ErrSQLiteVersion) Error() string {
+	return fmt.Sprintf("%s requires SQLite %s or later, the version is %s", e.Feature, e.Required, e.Version)
+}
+
+// ErrColumnNarrowing is returned by SyncWithOptions with Strict when the type of
+// a column would be narrowed
+type ErrColumnNarrowing struct {
+	TableName  string
+	ColumnName string
+	From       string
+	To         string
+}
+
+func (e ErrColumnNarrowing) Error() string {
+	return fmt.Sprintf("column %s of table %s would be narrowed from %s to %s", e.ColumnName, e.TableName, e.From, e.To)
+}
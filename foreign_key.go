@@ -0,0 +1,240 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"xorm.io/core"
+)
+
+// ForeignKey describes a foreign key constraint of a table, it's declared by the
+// tags of a field, e.g.
+//
+//	UserId int64 `xorm:"fk(user.id) ondelete(cascade) onupdate(restrict)"`
+type ForeignKey struct {
+	Name     string
+	Cols     []string
+	RefTable string
+	RefCols  []string
+	// OnDelete and OnUpdate are the referential actions, one of CASCADE, RESTRICT,
+	// SET NULL, SET DEFAULT and NO ACTION, empty means the default of the database
+	OnDelete string
+	OnUpdate string
+}
+
+// Equal returns true if the foreign keys reference the same columns by the same
+// columns, the names and the actions are not compared
+func (fk *ForeignKey) Equal(dst *ForeignKey) bool {
+	return strings.EqualFold(fk.RefTable, dst.RefTable) &&
+		equalFoldSlice(fk.Cols, dst.Cols) && equalFoldSlice(fk.RefCols, dst.RefCols)
+}
+
+func equalFoldSlice(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// referentialActions are the valid params of ondelete and onupdate, SET NULL can be
+// written as set_null since the tags are split by spaces
+var referentialActions = map[string]bool{
+	"CASCADE":     true,
+	"RESTRICT":    true,
+	"SET NULL":    true,
+	"SET DEFAULT": true,
+	"NO ACTION":   true,
+}
+
+func normalizeReferentialAction(action string) string {
+	return strings.ToUpper(strings.Replace(strings.TrimSpace(action), "_", " ", -1))
+}
+
+// foreignKeyName returns the name of the foreign key declared on the column
+func foreignKeyName(tableName, colName string) string {
+	if idx := strings.LastIndex(tableName, "."); idx > -1 {
+		tableName = tableName[idx+1:]
+	}
+	return fmt.Sprintf("FK_%s_%s", tableName, colName)
+}
+
+// foreignKeysOf returns the foreign keys declared by the fk tags of the struct
+func (engine *Engine) foreignKeysOf(table *core.Table, tableName string) []*ForeignKey {
+	if table.Type == nil {
+		return nil
+	}
+
+	var fks []*ForeignKey
+	for _, col := range table.Columns() {
+		params, ok := engine.fieldTagParams(table.Type, col.FieldName, "fk")
+		if !ok || len(params) != 1 {
+			continue
+		}
+		idx := strings.LastIndex(params[0], ".")
+		if idx <= 0 {
+			continue
+		}
+		fk := &ForeignKey{
+			Name:     foreignKeyName(tableName, col.Name),
+			Cols:     []string{col.Name},
+			RefTable: params[0][:idx],
+			RefCols:  []string{params[0][idx+1:]},
+		}
+		if params, ok := engine.fieldTagParams(table.Type, col.FieldName, "ondelete"); ok && len(params) == 1 {
+			fk.OnDelete = normalizeReferentialAction(params[0])
+		}
+		if params, ok := engine.fieldTagParams(table.Type, col.FieldName, "onupdate"); ok && len(params) == 1 {
+			fk.OnUpdate = normalizeReferentialAction(params[0])
+		}
+		fks = append(fks, fk)
+	}
+	return fks
+}
+
+// sortBeansByForeignKeys sorts the beans so that the tables referenced by the foreign
+// keys are created before the tables referencing them. The order of the other beans
+// is kept, so are the beans which reference each other.
+func (engine *Engine) sortBeansByForeignKeys(beans []interface{}) []interface{} {
+	var names = make([]string, len(beans))
+	var refs = make([][]string, len(beans))
+	for i, bean := range beans {
+		v := rValue(bean)
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		table, err := engine.autoMapType(v)
+		if err != nil {
+			// the error will be returned when the table is created
+			return beans
+		}
+		names[i] = engine.TableName(bean)
+		for _, fk := range engine.foreignKeysOf(table, names[i]) {
+			refs[i] = append(refs[i], fk.RefTable)
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	var states = make([]int, len(beans))
+	var sorted = make([]interface{}, 0, len(beans))
+	var visit func(i int)
+	visit = func(i int) {
+		states[i] = visiting
+		for _, ref := range refs[i] {
+			for j, name := range names {
+				if j != i && states[j] == 0 && strings.EqualFold(name, ref) {
+					visit(j)
+				}
+			}
+		}
+		states[i] = visited
+		sorted = append(sorted, beans[i])
+	}
+	for i := range beans {
+		if states[i] == 0 {
+			visit(i)
+		}
+	}
+	return sorted
+}
+
+// appendForeignKeyColumn appends the column of the foreign key read from the database
+// to the last foreign key if they have the same name, the rows should be ordered by
+// the names and the positions of the columns
+func appendForeignKeyColumn(fks []*ForeignKey, name, col, refTable, refCol, onDelete, onUpdate string) []*ForeignKey {
+	if len(fks) > 0 && fks[len(fks)-1].Name == name {
+		fk := fks[len(fks)-1]
+		fk.Cols = append(fk.Cols, col)
+		fk.RefCols = append(fk.RefCols, refCol)
+		return fks
+	}
+	return append(fks, &ForeignKey{
+		Name:     name,
+		Cols:     []string{col},
+		RefTable: refTable,
+		RefCols:  []string{refCol},
+		OnDelete: normalizeReferentialAction(onDelete),
+		OnUpdate: normalizeReferentialAction(onUpdate),
+	})
+}
+
+// foreignKeyClause returns the constraint of the foreign key in CREATE TABLE or
+// ALTER TABLE ADD, the constraint is unnamed if the foreign key has no name
+func foreignKeyClause(quote func(string) string, fk *ForeignKey) string {
+	var sql string
+	if fk.Name != "" {
+		sql = "CONSTRAINT " + quote(fk.Name) + " "
+	}
+	sql += fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", quoteJoin(fk.Cols, quote, ", "),
+		quote(fk.RefTable), quoteJoin(fk.RefCols, quote, ", "))
+	if fk.OnDelete != "" {
+		sql += " ON DELETE " + fk.OnDelete
+	}
+	if fk.OnUpdate != "" {
+		sql += " ON UPDATE " + fk.OnUpdate
+	}
+	return sql
+}
+
+// DBForeignKeys returns the foreign keys of the table in the database
+func (engine *Engine) DBForeignKeys(tableName string) ([]*ForeignKey, error) {
+	dialect, ok := engine.dialect.(schemaDialect)
+	if !ok {
+		return nil, fmt.Errorf("foreign keys are not supported on %s", engine.dialect.DBType())
+	}
+	return dialect.GetForeignKeys(tableName)
+}
+
+// planForeignKeys returns the changes to add the foreign keys of the struct which
+// are not in the database, the foreign keys are never dropped. The columns of the
+// database foreign keys are compared by the new names of the renamed columns.
+func (engine *Engine) planForeignKeys(tbName, tbNameWithSchema string, table *core.Table, dbFKs []*ForeignKey, renamed map[string]string) []*SyncChange {
+	dialect, ok := engine.dialect.(schemaDialect)
+	if !ok {
+		return nil
+	}
+
+	var changes []*SyncChange
+	for _, fk := range engine.foreignKeysOf(table, tbName) {
+		var found bool
+		for _, dbFK := range dbFKs {
+			var cols = make([]string, len(dbFK.Cols))
+			for i, col := range dbFK.Cols {
+				if newName := renamed[strings.ToLower(col)]; newName != "" {
+					col = newName
+				}
+				cols[i] = col
+			}
+			if fk.Equal(&ForeignKey{Cols: cols, RefTable: dbFK.RefTable, RefCols: dbFK.RefCols}) {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		change := &SyncChange{
+			Type:       SyncAddForeignKey,
+			Table:      tbName,
+			ForeignKey: fk,
+		}
+		if sql := dialect.AddForeignKeySql(tbNameWithSchema, fk); sql != "" {
+			change.SQLs = []string{sql}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
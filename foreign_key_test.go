@@ -0,0 +1,152 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type FkUser struct {
+	Id   int64
+	Name string
+}
+
+type FkOrderV1 struct {
+	Id     int64
+	UserId int64
+}
+
+func (FkOrderV1) TableName() string {
+	return "fk_order"
+}
+
+type FkOrder struct {
+	Id     int64
+	UserId int64 `xorm:"fk(fk_user.id) ondelete(cascade) onupdate(set_null)"`
+}
+
+func TestForeignKeySQL(t *testing.T) {
+	fk := &ForeignKey{
+		Name:     "FK_fk_order_user_id",
+		Cols:     []string{"user_id"},
+		RefTable: "fk_user",
+		RefCols:  []string{"id"},
+		OnDelete: "RESTRICT",
+		OnUpdate: "CASCADE",
+	}
+
+	mysql, err := NewEngine("mysql", "root:@/xorm_test")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "ALTER TABLE `fk_order` ADD CONSTRAINT `FK_fk_order_user_id` FOREIGN KEY (`user_id`) REFERENCES `fk_user` (`id`) ON DELETE RESTRICT ON UPDATE CASCADE",
+		mysql.dialect.(schemaDialect).AddForeignKeySql("fk_order", fk))
+
+	postgres, err := NewEngine("postgres", "dbname=xorm_test sslmode=disable")
+	assert.NoError(t, err)
+	table, err := postgres.autoMapType(rValue(new(FkOrder)))
+	assert.NoError(t, err)
+	assert.EqualValues(t, `CREATE TABLE IF NOT EXISTS "fk_order" ("id" BIGSERIAL PRIMARY KEY  NOT NULL, "user_id" BIGINT NULL, `+
+		`CONSTRAINT "FK_fk_order_user_id" FOREIGN KEY ("user_id") REFERENCES "fk_user" ("id") ON DELETE CASCADE ON UPDATE SET NULL)`,
+		postgres.createTableSQL(table, "fk_order", "", "", postgres.tableSchemaOf(table, "fk_order")))
+
+	assert.EqualValues(t, `CONSTRAINT "FK_fk_order_user_id" FOREIGN KEY ("user_id") REFERENCES "fk_user" ("id") ON DELETE NO ACTION ON UPDATE CASCADE`,
+		new(mssql).foreignKeyClause(fk))
+	assert.EqualValues(t, `CONSTRAINT [FK_fk_order_user_id] FOREIGN KEY ([user_id]) REFERENCES [fk_user] ([id])`,
+		new(oracle).foreignKeyClause(fk))
+}
+
+func TestForeignKeyTags(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	assert.NoError(t, engine.DropTables("fk_order", new(FkUser)))
+	// the referenced table is created at first
+	assert.NoError(t, engine.CreateTables(new(FkOrder), new(FkUser)))
+
+	fks, err := engine.DBForeignKeys("fk_order")
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 1, len(fks)) {
+		assert.EqualValues(t, []string{"user_id"}, fks[0].Cols)
+		assert.EqualValues(t, "fk_user", fks[0].RefTable)
+		assert.EqualValues(t, []string{"id"}, fks[0].RefCols)
+		assert.EqualValues(t, "CASCADE", fks[0].OnDelete)
+		assert.EqualValues(t, "SET NULL", fks[0].OnUpdate)
+	}
+
+	_, err = engine.DBMetas()
+	assert.NoError(t, err)
+}
+
+type FkOrderItem struct {
+	Id      int64
+	OrderId int64 `xorm:"fk(fk_order.id)"`
+}
+
+type FkNode struct {
+	Id       int64
+	ParentId int64 `xorm:"fk(fk_node.id)"`
+}
+
+func TestSortBeansByForeignKeys(t *testing.T) {
+	engine, err := NewEngine("postgres", "dbname=xorm_test sslmode=disable")
+	assert.NoError(t, err)
+
+	var item, order, user, node = new(FkOrderItem), new(FkOrder), new(FkUser), new(FkNode)
+	assert.EqualValues(t, []interface{}{user, order, item, node},
+		engine.sortBeansByForeignKeys([]interface{}{item, order, user, node}))
+	assert.EqualValues(t, []interface{}{node, user, order},
+		engine.sortBeansByForeignKeys([]interface{}{node, user, order}))
+}
+
+func TestSyncForeignKeysOrder(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	assert.NoError(t, engine.DropTables(new(FkOrderItem), "fk_order", new(FkUser)))
+	assert.NoError(t, engine.Sync2(new(FkOrderItem), new(FkOrder), new(FkUser)))
+
+	fks, err := engine.DBForeignKeys("fk_order_item")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(fks))
+	assert.NoError(t, engine.DropTables(new(FkOrderItem)))
+}
+
+func TestSyncForeignKeys(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	assert.NoError(t, engine.DropTables("fk_order", new(FkUser)))
+	assertSync(t, new(FkUser), new(FkOrderV1))
+
+	_, err := engine.Insert(&FkUser{Id: 1, Name: "a"})
+	assert.NoError(t, err)
+	_, err = engine.Insert(&FkOrderV1{UserId: 1})
+	assert.NoError(t, err)
+
+	plan, err := engine.PlanSync(new(FkOrder))
+	assert.NoError(t, err)
+	var added *SyncChange
+	for _, change := range plan.Changes {
+		if change.Type == SyncAddForeignKey {
+			added = change
+		}
+	}
+	if assert.NotNil(t, added) {
+		assert.EqualValues(t, "FK_fk_order_user_id", added.ForeignKey.Name)
+	}
+
+	assert.NoError(t, engine.Sync2(new(FkOrder)))
+	fks, err := engine.DBForeignKeys("fk_order")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(fks))
+
+	var orders []FkOrder
+	assert.NoError(t, engine.Find(&orders))
+	if assert.EqualValues(t, 1, len(orders)) {
+		assert.EqualValues(t, 1, orders[0].UserId)
+	}
+
+	plan, err = engine.PlanSync(new(FkOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(plan.Changes))
+}
@@ -330,3 +330,40 @@ func quoteColumns(cols []string, quoteFunc func(string) string, sep string) stri
 	}
 	return strings.Join(cols, sep+" ")
 }
+
+// quoteJoin quotes the columns and joins them with sep, unlike quoteColumns cols is not changed
+func quoteJoin(cols []string, quoteFunc func(string) string, sep string) string {
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = quoteFunc(col)
+	}
+	return strings.Join(quoted, sep)
+}
+
+// valuesPlaceholders generates the placeholders of a multiple rows VALUES clause
+func valuesPlaceholders(colCount, rows int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", colCount), ", ") + ")"
+	return strings.TrimSuffix(strings.Repeat(row+",", rows), ",")
+}
+
+// stringsEqual returns true if the two slices have the same elements in the same order
+func stringsEqual(left, right []string) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for i := range left {
+		if left[i] != right[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func stringsContains(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
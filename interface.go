@@ -45,14 +45,19 @@ type Interface interface {
 	Limit(int, ...int) *Session
 	MustCols(columns ...string) *Session
 	NoAutoCondition(...bool) *Session
+	NoDefaultScopes() *Session
 	NotIn(string, ...interface{}) *Session
 	Join(joinOperator string, tablename interface{}, condition string, args ...interface{}) *Session
 	Omit(columns ...string) *Session
+	OnConflict(columns ...string) *Session
 	OrderBy(order string) *Session
+	Paginate(page *Page) *Session
 	Ping() error
+	Preload(relations ...string) *Session
 	Query(sqlOrArgs ...interface{}) (resultsSlice []map[string][]byte, err error)
 	QueryInterface(sqlOrArgs ...interface{}) ([]map[string]interface{}, error)
 	QueryString(sqlOrArgs ...interface{}) ([]map[string]string, error)
+	Returning(columns ...string) *Session
 	Rows(bean interface{}) (*Rows, error)
 	SetExpr(string, interface{}) *Session
 	SQL(interface{}, ...interface{}) *Session
@@ -63,8 +68,10 @@ type Interface interface {
 	Table(tableNameOrBean interface{}) *Session
 	Unscoped() *Session
 	Update(bean interface{}, condiBeans ...interface{}) (int64, error)
+	Upsert(beans interface{}, updateCols ...string) (int64, error)
 	UseBool(...string) *Session
 	Where(interface{}, ...interface{}) *Session
+	WithAssociations() *Session
 }
 
 // EngineInterface defines the interface which Engine, EngineGroup will implementate.
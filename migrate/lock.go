@@ -0,0 +1,212 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"xorm.io/core"
+)
+
+// ErrLockTimeout is returned when the migration lock could not be acquired in
+// Options.LockTimeout
+var ErrLockTimeout = errors.New("Timeout while waiting for the migration lock")
+
+// ErrLockSingleConn is returned when the advisory lock is needed but the database allows
+// only one open connection, which is held by the lock so the migrations would wait forever
+var ErrLockSingleConn = errors.New("The migration lock needs more than one connection, set Options.DisableLock if MaxOpenConns is 1")
+
+// defaultLockTimeout is used if Options.LockTimeout is not set
+const defaultLockTimeout = time.Minute
+
+// defaultLockTTL is used if Options.LockTTL is not set
+const defaultLockTTL = time.Hour
+
+// lockRetryInterval is the interval of trying to insert the lock row
+const lockRetryInterval = 100 * time.Millisecond
+
+// lockName returns the name of the lock of the migration table
+func (m *Migrate) lockName() string {
+	return "xorm_migrate_" + m.options.TableName
+}
+
+// lockTableName returns the table of the lock row for the databases which have
+// no advisory locks
+func (m *Migrate) lockTableName() string {
+	return m.options.TableName + "_lock"
+}
+
+// withLock runs f while holding a database level lock so that the migrations will
+// not be run by two processes at the same time, the lock is not needed in dry-run mode
+func (m *Migrate) withLock(f func() error) error {
+	if m.options.DisableLock || m.options.DryRun {
+		return f()
+	}
+
+	var timeout = m.options.LockTimeout
+	if timeout <= 0 {
+		timeout = defaultLockTimeout
+	}
+
+	unlock, err := m.lock(timeout)
+	if err != nil {
+		return err
+	}
+	err = f()
+	if unlockErr := unlock(); err == nil {
+		err = unlockErr
+	}
+	return err
+}
+
+// lock acquires the lock and returns the function to release it
+func (m *Migrate) lock(timeout time.Duration) (func() error, error) {
+	switch m.db.Dialect().DBType() {
+	case core.POSTGRES, core.MYSQL, core.MSSQL:
+		return m.lockAdvisory(timeout)
+	default:
+		return m.lockRow(timeout)
+	}
+}
+
+// lockAdvisory acquires the advisory lock of the database, the lock belongs to the
+// connection so it's released on the same connection. The connection is held until
+// the migrations are done, so another one is needed to run them.
+func (m *Migrate) lockAdvisory(timeout time.Duration) (func() error, error) {
+	if m.db.DB().Stats().MaxOpenConnections == 1 {
+		return nil, ErrLockSingleConn
+	}
+
+	ctx := context.Background()
+	conn, err := m.db.DB().Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var name = m.lockName()
+	var lockSQL, unlockSQL string
+	var args []interface{}
+	switch m.db.Dialect().DBType() {
+	case core.POSTGRES:
+		h := fnv.New64a()
+		h.Write([]byte(name))
+		key := int64(h.Sum64())
+		lockSQL = "SELECT pg_advisory_lock($1)"
+		unlockSQL = fmt.Sprintf("SELECT pg_advisory_unlock(%d)", key)
+		args = []interface{}{key}
+		// pg_advisory_lock waits forever, the timeout is applied by the context
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	case core.MYSQL:
+		lockSQL = "SELECT GET_LOCK(?, ?)"
+		unlockSQL = fmt.Sprintf("SELECT RELEASE_LOCK('%s')", name)
+		args = []interface{}{name, int64(timeout / time.Second)}
+	case core.MSSQL:
+		lockSQL = "DECLARE @result int; EXEC @result = sp_getapplock @Resource = ?, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = ?; SELECT CASE WHEN @result >= 0 THEN 1 ELSE 0 END"
+		unlockSQL = fmt.Sprintf("EXEC sp_releaseapplock @Resource = '%s', @LockOwner = 'Session'", name)
+		args = []interface{}{name, int64(timeout / time.Millisecond)}
+	}
+
+	var acquired sql.NullInt64
+	if m.db.Dialect().DBType() == core.POSTGRES {
+		// pg_advisory_lock returns void
+		_, err = conn.ExecContext(ctx, lockSQL, args...)
+		acquired.Int64 = 1
+	} else {
+		err = conn.QueryRowContext(ctx, lockSQL, args...).Scan(&acquired)
+	}
+	if err != nil {
+		conn.Close()
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, ErrLockTimeout
+		}
+		return nil, err
+	}
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return nil, ErrLockTimeout
+	}
+
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), unlockSQL)
+		return err
+	}, nil
+}
+
+// lockRow acquires the lock by inserting a row into the lock table, the insertion
+// fails while the row exists. The row older than Options.LockTTL is deleted so that
+// the lock left by a crashed process will not block the migrations forever.
+func (m *Migrate) lockRow(timeout time.Duration) (func() error, error) {
+	var tableName = m.lockTableName()
+	exists, err := m.db.IsTableExist(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		sql := fmt.Sprintf("CREATE TABLE %s (%s VARCHAR(255) PRIMARY KEY, %s VARCHAR(64))",
+			m.db.Quote(tableName), m.db.Quote("id"), m.db.Quote("locked_at"))
+		if _, err := m.db.Exec(sql); err != nil {
+			// the table may be created by another process at the same time
+			if exists, _ = m.db.IsTableExist(tableName); !exists {
+				return nil, err
+			}
+		}
+	}
+
+	var ttl = m.options.LockTTL
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+
+	var name = m.lockName()
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES (?, ?)", m.db.Quote(tableName), m.db.Quote("id"), m.db.Quote("locked_at"))
+	selectSQL := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", m.db.Quote("locked_at"), m.db.Quote(tableName), m.db.Quote("id"))
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE %s = ? AND %s = ?", m.db.Quote(tableName), m.db.Quote("id"), m.db.Quote("locked_at"))
+	deadline := time.Now().Add(timeout)
+	var missing bool
+	for {
+		_, err := m.db.Exec(insertSQL, name, time.Now().UTC().Format(time.RFC3339))
+		if err == nil {
+			break
+		}
+
+		// the insertion fails for other reasons if the row does not exist, it's tried
+		// once more since the row may be deleted just now
+		var lockedAt string
+		has, queryErr := m.db.SQL(selectSQL, name).Get(&lockedAt)
+		if queryErr != nil {
+			return nil, queryErr
+		}
+		if !has {
+			if missing {
+				return nil, err
+			}
+			missing = true
+			continue
+		}
+		missing = false
+
+		if t, parseErr := time.Parse(time.RFC3339, lockedAt); parseErr == nil && time.Since(t) > ttl {
+			// only one process could delete the stale row since locked_at is checked
+			if _, err := m.db.Exec(deleteSQL, name, lockedAt); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, ErrLockTimeout
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	return func() error {
+		_, err := m.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ?", m.db.Quote(tableName), m.db.Quote("id")), name)
+		return err
+	}, nil
+}
@@ -1,10 +1,15 @@
 package migrate
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/go-xorm/xorm"
+	"xorm.io/core"
 )
 
 // MigrateFunc is the func signature for migrating.
@@ -13,6 +18,14 @@ type MigrateFunc func(*xorm.Engine) error
 // RollbackFunc is the func signature for rollbacking.
 type RollbackFunc func(*xorm.Engine) error
 
+// MigrateTxFunc is the func signature for migrating with a session, the session is
+// in a transaction if the database supports transactional DDL.
+type MigrateTxFunc func(*xorm.Session) error
+
+// RollbackTxFunc is the func signature for rollbacking with a session, the session
+// is in a transaction if the database supports transactional DDL.
+type RollbackTxFunc func(*xorm.Session) error
+
 // InitSchemaFunc is the func signature for initializing the schema.
 type InitSchemaFunc func(*xorm.Engine) error
 
@@ -22,6 +35,25 @@ type Options struct {
 	TableName string
 	// IDColumnName is the name of column where the migration id will be stored.
 	IDColumnName string
+	// ValidateChecksums makes Migrate fail with a ChecksumMismatchError if the
+	// checksum of an applied migration has changed.
+	ValidateChecksums bool
+	// LockTimeout is how long to wait for the lock which prevents the migrations from
+	// being run by multiple processes at the same time, default is one minute.
+	LockTimeout time.Duration
+	// LockTTL is how long the lock row of the databases without advisory locks is kept,
+	// an older lock row is regarded as left by a crashed process and taken over,
+	// default is one hour. The advisory locks are released when the connection is closed.
+	LockTTL time.Duration
+	// DisableLock disables the lock. The advisory lock of MySQL, Postgres and MSSQL holds
+	// a connection, so it should be disabled if the engine's MaxOpenConns is 1.
+	DisableLock bool
+	// DryRun makes Migrate, MigrateTo and the rollbacks only report the migrations
+	// which would be run without executing them.
+	DryRun bool
+	// OnMigration is called before a migration is run or rollback, or would be in
+	// dry-run mode. The migrations are logged in dry-run mode if it's nil.
+	OnMigration func(id string, rollback bool)
 }
 
 // Migration represents a database migration (a modification to be made on the database).
@@ -32,8 +64,80 @@ type Migration struct {
 	Migrate MigrateFunc
 	// Rollback will be executed on rollback. Can be nil.
 	Rollback RollbackFunc
+	// MigrateTx will be executed instead of Migrate if it's not nil, the migration
+	// is recorded in the same transaction.
+	MigrateTx MigrateTxFunc
+	// RollbackTx will be executed instead of Rollback if it's not nil.
+	RollbackTx RollbackTxFunc
+	// Checksum identifies the content of the migration, e.g. a hash of its SQL.
+	// It is recorded when the migration is applied. Can be empty.
+	Checksum string
 }
 
+// MigrationState is the state of a migration reported by Status
+type MigrationState int
+
+const (
+	// StatePending means the migration has not been applied
+	StatePending MigrationState = iota
+	// StateApplied means the migration has been applied
+	StateApplied
+	// StateMissing means the migration has been applied but it's not defined
+	StateMissing
+)
+
+func (s MigrationState) String() string {
+	switch s {
+	case StatePending:
+		return "pending"
+	case StateApplied:
+		return "applied"
+	case StateMissing:
+		return "missing"
+	}
+	return "unknown"
+}
+
+// MigrationStatus represents the status of a migration
+type MigrationStatus struct {
+	ID        string
+	State     MigrationState
+	AppliedAt time.Time
+	Duration  time.Duration
+	// Checksum is the checksum recorded when the migration was applied
+	Checksum string
+	// ChecksumChanged is true if the checksum of the applied migration is not
+	// the same as the recorded one
+	ChecksumChanged bool
+}
+
+// ChecksumMismatchError is returned by Migrate when Options.ValidateChecksums is
+// set and the checksum of an applied migration has changed
+type ChecksumMismatchError struct {
+	ID       string
+	Applied  string
+	Expected string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("Checksum of migration %s changed from %s to %s", e.ID, e.Applied, e.Expected)
+}
+
+// appliedMigration is a record of the migration table
+type appliedMigration struct {
+	ID        string
+	AppliedAt time.Time
+	Duration  time.Duration
+	Checksum  string
+}
+
+// the columns of the migration table besides the id
+const (
+	appliedAtColumn = "applied_at"
+	durationColumn  = "duration_ms"
+	checksumColumn  = "checksum"
+)
+
 // Migrate represents a collection of all migrations of a database schema.
 type Migrate struct {
 	db         *xorm.Engine
@@ -62,6 +166,10 @@ var (
 	// ErrNoRunnedMigration is returned when any runned migration was found while
 	// running RollbackLast
 	ErrNoRunnedMigration = errors.New("Could not find last runned migration")
+
+	// ErrMigrationIDDoesNotExist is returned when the target migration of MigrateTo
+	// or RollbackTo is not defined
+	ErrMigrationIDDoesNotExist = errors.New("Tried to migrate to an ID that doesn't exist")
 )
 
 // New returns a new Gormigrate.
@@ -83,18 +191,70 @@ func (m *Migrate) InitSchema(initSchema InitSchemaFunc) {
 
 // Migrate executes all migrations that did not run yet.
 func (m *Migrate) Migrate() error {
-	if err := m.createMigrationTableIfNotExists(); err != nil {
+	return m.migrate("")
+}
+
+// MigrateTo executes the migrations that did not run yet up to and including the
+// migration with id. InitSchema is not used by MigrateTo.
+func (m *Migrate) MigrateTo(id string) error {
+	if err := m.checkIDExists(id); err != nil {
 		return err
 	}
+	return m.migrate(id)
+}
 
-	if m.initSchema != nil && m.isFirstRun() {
-		return m.runInitSchema()
+// migrate executes the migrations up to to, or all of them if to is empty
+func (m *Migrate) migrate(to string) error {
+	if m.options.DryRun {
+		return m.dryRunMigrate(to)
 	}
 
-	for _, migration := range m.migrations {
-		if err := m.runMigration(migration); err != nil {
+	return m.withLock(func() error {
+		if err := m.createMigrationTableIfNotExists(); err != nil {
 			return err
 		}
+
+		if m.options.ValidateChecksums {
+			if err := m.validateChecksums(); err != nil {
+				return err
+			}
+		}
+
+		if to == "" && m.initSchema != nil && m.isFirstRun() {
+			return m.runInitSchema()
+		}
+
+		for _, migration := range m.migrations {
+			if err := m.runMigration(migration); err != nil {
+				return err
+			}
+			if migration.ID == to {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// dryRunMigrate reports the migrations which would be run by migrate
+func (m *Migrate) dryRunMigrate(to string) error {
+	applied, err := m.appliedIDs()
+	if err != nil {
+		return err
+	}
+
+	// all the migrations are recorded by the init schema
+	var initSchema = to == "" && m.initSchema != nil && len(applied) == 0
+	for _, migration := range m.migrations {
+		if len(migration.ID) == 0 {
+			return ErrMissingID
+		}
+		if initSchema || !applied[migration.ID] {
+			m.report(migration, false)
+		}
+		if migration.ID == to {
+			break
+		}
 	}
 	return nil
 }
@@ -105,24 +265,90 @@ func (m *Migrate) RollbackLast() error {
 		return ErrNoMigrationDefined
 	}
 
-	lastRunnedMigration, err := m.getLastRunnedMigration()
-	if err != nil {
+	return m.withLock(func() error {
+		lastRunnedMigration, err := m.getLastRunnedMigration()
+		if err != nil {
+			return err
+		}
+
+		return m.rollbackMigration(lastRunnedMigration)
+	})
+}
+
+// RollbackTo undo the migrations which run after the migration with id in the
+// reverse order, the migration with id is not rollback.
+func (m *Migrate) RollbackTo(id string) error {
+	if err := m.checkIDExists(id); err != nil {
 		return err
 	}
+	return m.rollbackTo(id)
+}
 
-	if err := m.RollbackMigration(lastRunnedMigration); err != nil {
-		return err
+// RollbackAll undo all the migrations in the reverse order
+func (m *Migrate) RollbackAll() error {
+	return m.rollbackTo("")
+}
+
+// rollbackTo undo the migrations after to, or all of them if to is empty
+func (m *Migrate) rollbackTo(to string) error {
+	if len(m.migrations) == 0 {
+		return ErrNoMigrationDefined
+	}
+
+	return m.withLock(func() error {
+		applied, err := m.appliedIDs()
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.ID == to {
+				break
+			}
+			if !applied[migration.ID] {
+				continue
+			}
+			if err := m.rollbackMigration(migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrate) checkIDExists(id string) error {
+	for _, migration := range m.migrations {
+		if migration.ID == id {
+			return nil
+		}
+	}
+	return ErrMigrationIDDoesNotExist
+}
+
+// report calls OnMigration or logs the migration in dry-run mode
+func (m *Migrate) report(migration *Migration, rollback bool) {
+	if m.options.OnMigration != nil {
+		m.options.OnMigration(migration.ID, rollback)
+		return
+	}
+	if m.options.DryRun {
+		var action = "migrate"
+		if rollback {
+			action = "rollback"
+		}
+		m.db.Logger().Infof("[migrate] dry run: %s %s", action, migration.ID)
 	}
-	return nil
 }
 
 func (m *Migrate) getLastRunnedMigration() (*Migration, error) {
+	applied, err := m.appliedIDs()
+	if err != nil {
+		return nil, err
+	}
 	for i := len(m.migrations) - 1; i >= 0; i-- {
 		migration := m.migrations[i]
-		run, err := m.migrationDidRun(migration)
-		if err != nil {
-			return nil, err
-		} else if run {
+		if applied[migration.ID] {
 			return migration, nil
 		}
 	}
@@ -131,28 +357,78 @@ func (m *Migrate) getLastRunnedMigration() (*Migration, error) {
 
 // RollbackMigration undo a migration.
 func (m *Migrate) RollbackMigration(mig *Migration) error {
-	if mig.Rollback == nil {
+	return m.withLock(func() error {
+		return m.rollbackMigration(mig)
+	})
+}
+
+func (m *Migrate) rollbackMigration(mig *Migration) error {
+	if mig.Rollback == nil && mig.RollbackTx == nil {
 		return ErrRollbackImpossible
 	}
 
-	if err := mig.Rollback(m.db); err != nil {
-		return err
+	m.report(mig, true)
+	if m.options.DryRun {
+		return nil
 	}
 
 	sql := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", m.options.TableName, m.options.IDColumnName)
+	if mig.RollbackTx != nil {
+		return m.withSession(func(session *xorm.Session) error {
+			if err := mig.RollbackTx(session); err != nil {
+				return err
+			}
+			_, err := session.Exec(sql, mig.ID)
+			return err
+		})
+	}
+
+	if err := mig.Rollback(m.db); err != nil {
+		return err
+	}
 	if _, err := m.db.Exec(sql, mig.ID); err != nil {
 		return err
 	}
 	return nil
 }
 
+// withSession runs f with a new session which is in a transaction if the database
+// supports transactional DDL, the transaction is committed if f returns nil
+func (m *Migrate) withSession(f func(*xorm.Session) error) error {
+	session := m.db.NewSession()
+	defer session.Close()
+
+	var inTx = supportsTransactionalDDL(m.db.Dialect().DBType())
+	if inTx {
+		if err := session.Begin(); err != nil {
+			return err
+		}
+	}
+	if err := f(session); err != nil {
+		return err
+	}
+	if inTx {
+		return session.Commit()
+	}
+	return nil
+}
+
+// supportsTransactionalDDL returns true if the DDL statements could be rollback
+func supportsTransactionalDDL(dbType core.DbType) bool {
+	switch dbType {
+	case core.POSTGRES, core.SQLITE, core.MSSQL:
+		return true
+	}
+	return false
+}
+
 func (m *Migrate) runInitSchema() error {
 	if err := m.initSchema(m.db); err != nil {
 		return err
 	}
 
 	for _, migration := range m.migrations {
-		if err := m.insertMigration(migration.ID); err != nil {
+		if err := m.insertMigration(m.db, migration, time.Now(), 0); err != nil {
 			return err
 		}
 	}
@@ -170,34 +446,199 @@ func (m *Migrate) runMigration(migration *Migration) error {
 		return err
 	}
 
-	if !run {
-		if err := migration.Migrate(m.db); err != nil {
-			return err
+	if run {
+		return nil
+	}
+
+	m.report(migration, false)
+	start := time.Now()
+	if migration.MigrateTx != nil {
+		return m.withSession(func(session *xorm.Session) error {
+			if err := migration.MigrateTx(session); err != nil {
+				return err
+			}
+			return m.insertMigration(session, migration, start, time.Since(start))
+		})
+	}
+
+	if err := migration.Migrate(m.db); err != nil {
+		return err
+	}
+	return m.insertMigration(m.db, migration, start, time.Since(start))
+}
+
+// migrationColumns returns the columns of the migration table
+func (m *Migrate) migrationColumns() []*core.Column {
+	id := core.NewColumn(m.options.IDColumnName, "", core.SQLType{Name: core.Varchar}, 255, 0, false)
+	id.IsPrimaryKey = true
+	return []*core.Column{
+		id,
+		core.NewColumn(appliedAtColumn, "", core.SQLType{Name: core.DateTime}, 0, 0, true),
+		core.NewColumn(durationColumn, "", core.SQLType{Name: core.BigInt}, 0, 0, true),
+		core.NewColumn(checksumColumn, "", core.SQLType{Name: core.Varchar}, 255, 0, true),
+	}
+}
+
+func (m *Migrate) createMigrationTableIfNotExists() error {
+	exists, err := m.db.IsTableExist(m.options.TableName)
+	if err != nil {
+		return err
+	}
+
+	dialect := m.db.Dialect()
+	columns := m.migrationColumns()
+	if !exists {
+		table := core.NewEmptyTable()
+		for _, col := range columns {
+			table.AddColumn(col)
 		}
+		_, err := m.db.Exec(dialect.CreateTableSql(table, m.options.TableName, "", ""))
+		return err
+	}
 
-		if err := m.insertMigration(migration.ID); err != nil {
+	// the migration table created by the old versions only has the id column
+	for _, col := range columns[1:] {
+		exists, err := dialect.IsColumnExist(m.options.TableName, col.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		sql := fmt.Sprintf("ALTER TABLE %s ADD %s", m.db.Quote(m.options.TableName), col.String(dialect))
+		if _, err := m.db.Exec(sql); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (m *Migrate) createMigrationTableIfNotExists() error {
-	exists, err := m.db.IsTableExist(m.options.TableName)
+// appliedMigrations returns the records of the migration table ordered by id
+func (m *Migrate) appliedMigrations() ([]*appliedMigration, error) {
+	records, err := m.db.QueryString(fmt.Sprintf("SELECT %s, %s, %s, %s FROM %s ORDER BY %s",
+		m.db.Quote(m.options.IDColumnName), m.db.Quote(appliedAtColumn), m.db.Quote(durationColumn),
+		m.db.Quote(checksumColumn), m.db.Quote(m.options.TableName), m.db.Quote(m.options.IDColumnName)))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if exists {
-		return nil
+
+	var applied = make([]*appliedMigration, 0, len(records))
+	for _, record := range records {
+		duration, _ := strconv.ParseInt(record[durationColumn], 10, 64)
+		applied = append(applied, &appliedMigration{
+			ID:        record[m.options.IDColumnName],
+			AppliedAt: parseAppliedAt(record[appliedAtColumn]),
+			Duration:  time.Duration(duration) * time.Millisecond,
+			Checksum:  record[checksumColumn],
+		})
 	}
+	return applied, nil
+}
 
-	sql := fmt.Sprintf("CREATE TABLE %s (%s VARCHAR(255) PRIMARY KEY)", m.options.TableName, m.options.IDColumnName)
-	if _, err := m.db.Exec(sql); err != nil {
+// parseAppliedAt parses the time which may be formatted differently by the drivers
+func parseAppliedAt(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02 15:04:05Z07:00"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Status returns the status of the defined migrations in order followed by the
+// applied migrations which are not defined.
+func (m *Migrate) Status() ([]*MigrationStatus, error) {
+	if err := m.createMigrationTableIfNotExists(); err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var appliedByID = make(map[string]*appliedMigration, len(applied))
+	for _, record := range applied {
+		appliedByID[record.ID] = record
+	}
+
+	var statuses = make([]*MigrationStatus, 0, len(m.migrations))
+	var defined = make(map[string]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		defined[migration.ID] = true
+		status := &MigrationStatus{ID: migration.ID, State: StatePending}
+		if record, ok := appliedByID[migration.ID]; ok {
+			status.State = StateApplied
+			status.AppliedAt = record.AppliedAt
+			status.Duration = record.Duration
+			status.Checksum = record.Checksum
+			status.ChecksumChanged = checksumChanged(record.Checksum, migration.Checksum)
+		}
+		statuses = append(statuses, status)
+	}
+
+	var missing []*MigrationStatus
+	for _, record := range applied {
+		if !defined[record.ID] {
+			missing = append(missing, &MigrationStatus{
+				ID:        record.ID,
+				State:     StateMissing,
+				AppliedAt: record.AppliedAt,
+				Duration:  record.Duration,
+				Checksum:  record.Checksum,
+			})
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].ID < missing[j].ID
+	})
+	return append(statuses, missing...), nil
+}
+
+// checksumChanged returns true if both checksums are not empty and different
+func checksumChanged(applied, current string) bool {
+	return applied != "" && current != "" && applied != current
+}
+
+func (m *Migrate) validateChecksums() error {
+	applied, err := m.appliedMigrations()
+	if err != nil {
 		return err
 	}
+	var appliedByID = make(map[string]*appliedMigration, len(applied))
+	for _, record := range applied {
+		appliedByID[record.ID] = record
+	}
+	for _, migration := range m.migrations {
+		if record, ok := appliedByID[migration.ID]; ok && checksumChanged(record.Checksum, migration.Checksum) {
+			return &ChecksumMismatchError{
+				ID:       migration.ID,
+				Applied:  record.Checksum,
+				Expected: migration.Checksum,
+			}
+		}
+	}
 	return nil
 }
 
+// appliedIDs returns the ids of the applied migrations, it's empty if the migration
+// table does not exist
+func (m *Migrate) appliedIDs() (map[string]bool, error) {
+	exists, err := m.db.IsTableExist(m.options.TableName)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	records, err := m.db.QueryString(fmt.Sprintf("SELECT %s FROM %s", m.db.Quote(m.options.IDColumnName), m.db.Quote(m.options.TableName)))
+	if err != nil {
+		return nil, err
+	}
+	var applied = make(map[string]bool, len(records))
+	for _, record := range records {
+		applied[record[m.options.IDColumnName]] = true
+	}
+	return applied, nil
+}
+
 func (m *Migrate) migrationDidRun(mig *Migration) (bool, error) {
 	count, err := m.db.SQL(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", m.options.TableName, m.options.IDColumnName), mig.ID).Count()
 	return count > 0, err
@@ -210,8 +651,15 @@ func (m *Migrate) isFirstRun() bool {
 	return count == 0
 }
 
-func (m *Migrate) insertMigration(id string) error {
-	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?)", m.options.TableName, m.options.IDColumnName)
-	_, err := m.db.Exec(sql, id)
+// execer is implemented by *xorm.Engine and *xorm.Session
+type execer interface {
+	Exec(sqlOrArgs ...interface{}) (sql.Result, error)
+}
+
+func (m *Migrate) insertMigration(db execer, migration *Migration, appliedAt time.Time, duration time.Duration) error {
+	sql := fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s) VALUES (?, ?, ?, ?)", m.options.TableName,
+		m.options.IDColumnName, appliedAtColumn, durationColumn, checksumColumn)
+	_, err := db.Exec(sql, migration.ID, appliedAt.UTC().Format("2006-01-02 15:04:05"),
+		int64(duration/time.Millisecond), migration.Checksum)
 	return err
 }
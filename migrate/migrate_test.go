@@ -1,14 +1,19 @@
 package migrate
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-xorm/xorm"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
 )
 
 type Person struct {
@@ -148,3 +153,291 @@ func tableCount(db *xorm.Engine, tableName string) (count int) {
 	row.Scan(&count)
 	return
 }
+
+func TestMigrationStatus(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// the migration table of the old versions only has the id column
+	_, err = db.Exec("CREATE TABLE migrations (id VARCHAR(255) PRIMARY KEY)")
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO migrations (id) VALUES (?)", "201608301300")
+	assert.NoError(t, err)
+
+	var checksumMigrations = []*Migration{
+		{
+			ID:       "201608301400",
+			Checksum: "v1",
+			Migrate: func(tx *xorm.Engine) error {
+				return tx.Sync2(&Person{})
+			},
+		},
+		{
+			ID: "201608301430",
+			Migrate: func(tx *xorm.Engine) error {
+				return tx.Sync2(&Pet{})
+			},
+		},
+	}
+
+	m := New(db, &Options{TableName: "migrations", IDColumnName: "id"}, checksumMigrations[:1])
+	assert.NoError(t, m.Migrate())
+
+	m = New(db, &Options{TableName: "migrations", IDColumnName: "id"}, checksumMigrations)
+	statuses, err := m.Status()
+	assert.NoError(t, err)
+	if assert.Equal(t, 3, len(statuses)) {
+		assert.Equal(t, "201608301400", statuses[0].ID)
+		assert.Equal(t, StateApplied, statuses[0].State)
+		assert.Equal(t, "v1", statuses[0].Checksum)
+		assert.False(t, statuses[0].AppliedAt.IsZero())
+		assert.False(t, statuses[0].ChecksumChanged)
+
+		assert.Equal(t, "201608301430", statuses[1].ID)
+		assert.Equal(t, StatePending, statuses[1].State)
+
+		assert.Equal(t, "201608301300", statuses[2].ID)
+		assert.Equal(t, StateMissing, statuses[2].State)
+		assert.True(t, statuses[2].AppliedAt.IsZero())
+	}
+
+	checksumMigrations[0].Checksum = "v2"
+	statuses, err = m.Status()
+	assert.NoError(t, err)
+	assert.True(t, statuses[0].ChecksumChanged)
+
+	m = New(db, &Options{TableName: "migrations", IDColumnName: "id", ValidateChecksums: true}, checksumMigrations)
+	err = m.Migrate()
+	mismatchErr, ok := err.(*ChecksumMismatchError)
+	if assert.True(t, ok) {
+		assert.Equal(t, "201608301400", mismatchErr.ID)
+		assert.Equal(t, "v1", mismatchErr.Applied)
+		assert.Equal(t, "v2", mismatchErr.Expected)
+	}
+	exists, _ := db.IsTableExist(&Pet{})
+	assert.False(t, exists)
+}
+
+func TestMigrationTx(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	var errMigrate = errors.New("migrate failed")
+	m := New(db, DefaultOptions, []*Migration{
+		{
+			ID: "201608301400",
+			MigrateTx: func(tx *xorm.Session) error {
+				if err := tx.Sync2(&Person{}); err != nil {
+					return err
+				}
+				return errMigrate
+			},
+		},
+	})
+	assert.Equal(t, errMigrate, m.Migrate())
+	exists, _ := db.IsTableExist(&Person{})
+	assert.False(t, exists)
+	assert.Equal(t, 0, tableCount(db, "migrations"))
+
+	m = New(db, DefaultOptions, []*Migration{
+		{
+			ID: "201608301400",
+			MigrateTx: func(tx *xorm.Session) error {
+				return tx.Sync2(&Person{})
+			},
+			RollbackTx: func(tx *xorm.Session) error {
+				return tx.DropTable(&Person{})
+			},
+		},
+	})
+	assert.NoError(t, m.Migrate())
+	exists, _ = db.IsTableExist(&Person{})
+	assert.True(t, exists)
+	assert.Equal(t, 1, tableCount(db, "migrations"))
+
+	assert.NoError(t, m.RollbackLast())
+	exists, _ = db.IsTableExist(&Person{})
+	assert.False(t, exists)
+	assert.Equal(t, 0, tableCount(db, "migrations"))
+}
+
+func TestMigrationLock(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := New(db, &Options{TableName: "migrations", IDColumnName: "id", LockTimeout: 200 * time.Millisecond}, migrations)
+	unlock, err := m.lock(time.Second)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ErrLockTimeout, m.Migrate())
+	exists, _ := db.IsTableExist(&Person{})
+	assert.False(t, exists)
+
+	assert.NoError(t, unlock())
+	assert.NoError(t, m.Migrate())
+	exists, _ = db.IsTableExist(&Person{})
+	assert.True(t, exists)
+}
+
+func TestMigrationLockSingleConn(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// the advisory lock would hold the only connection
+	db.SetMaxOpenConns(1)
+	m := New(db, &Options{TableName: "migrations", IDColumnName: "id"}, migrations)
+	_, err = m.lockAdvisory(time.Second)
+	assert.Equal(t, ErrLockSingleConn, err)
+}
+
+func TestMigrationLockRow(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := New(db, &Options{TableName: "migrations", IDColumnName: "id", LockTTL: time.Minute}, migrations)
+	unlock, err := m.lockRow(time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, unlock())
+
+	// the lock row left by a crashed process is taken over after the TTL
+	_, err = db.Exec("INSERT INTO migrations_lock (id, locked_at) VALUES (?, ?)",
+		m.lockName(), time.Now().Add(-2*time.Minute).UTC().Format(time.RFC3339))
+	assert.NoError(t, err)
+	unlock, err = m.lockRow(200 * time.Millisecond)
+	assert.NoError(t, err)
+
+	_, err = m.lockRow(200 * time.Millisecond)
+	assert.Equal(t, ErrLockTimeout, err)
+	assert.NoError(t, unlock())
+
+	// the errors except the lock row exists are returned at once
+	_, err = db.Exec("DROP TABLE migrations_lock")
+	assert.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE migrations_lock (id VARCHAR(255) PRIMARY KEY, locked_at VARCHAR(64), holder VARCHAR(64) NOT NULL)")
+	assert.NoError(t, err)
+	start := time.Now()
+	_, err = m.lockRow(time.Second)
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrLockTimeout, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestMigrateToAndRollbackTo(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := New(db, DefaultOptions, migrations)
+	assert.Equal(t, ErrMigrationIDDoesNotExist, m.MigrateTo("unknown"))
+	assert.Equal(t, ErrMigrationIDDoesNotExist, m.RollbackTo("unknown"))
+
+	assert.NoError(t, m.MigrateTo("201608301400"))
+	exists, _ := db.IsTableExist(&Person{})
+	assert.True(t, exists)
+	exists, _ = db.IsTableExist(&Pet{})
+	assert.False(t, exists)
+	assert.Equal(t, 1, tableCount(db, "migrations"))
+
+	var reports []string
+	dryRun := New(db, &Options{
+		TableName:    "migrations",
+		IDColumnName: "id",
+		DryRun:       true,
+		OnMigration: func(id string, rollback bool) {
+			reports = append(reports, fmt.Sprintf("%s %v", id, rollback))
+		},
+	}, migrations)
+	assert.NoError(t, dryRun.Migrate())
+	assert.NoError(t, dryRun.RollbackAll())
+	assert.Equal(t, []string{"201608301430 false", "201608301400 true"}, reports)
+	exists, _ = db.IsTableExist(&Pet{})
+	assert.False(t, exists)
+	assert.Equal(t, 1, tableCount(db, "migrations"))
+
+	assert.NoError(t, m.Migrate())
+	assert.Equal(t, 2, tableCount(db, "migrations"))
+
+	assert.NoError(t, m.RollbackTo("201608301400"))
+	exists, _ = db.IsTableExist(&Person{})
+	assert.True(t, exists)
+	exists, _ = db.IsTableExist(&Pet{})
+	assert.False(t, exists)
+	assert.Equal(t, 1, tableCount(db, "migrations"))
+
+	assert.NoError(t, m.Migrate())
+	assert.NoError(t, m.RollbackAll())
+	exists, _ = db.IsTableExist(&Person{})
+	assert.False(t, exists)
+	assert.Equal(t, 0, tableCount(db, "migrations"))
+}
+
+func TestSQLMigrations(t *testing.T) {
+	os.Remove(dbName)
+
+	dir, err := ioutil.TempDir("", "xorm_migrate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var files = map[string]string{
+		"0001_create_person.up.sql":            "CREATE TABLE person (id INTEGER PRIMARY KEY, name TEXT);\nINSERT INTO person (name) VALUES ('a;b');",
+		"0001_create_person.down.sql":          "DROP TABLE person;",
+		"0002_create_pet.up.sql":               "-- pets\nCREATE TABLE pet (id INTEGER PRIMARY KEY, person_id INTEGER);",
+		"0002_create_pet.down.sql":             "DROP TABLE pet;",
+		"0010_add_index.up.sql":                "CREATE INDEX idx_pet_person ON pet USING btree (person_id);",
+		"0010_add_index.sqlite3.up.sql":        "CREATE INDEX idx_pet_person ON pet (person_id);",
+		"0010_add_index.down.sql":              "DROP INDEX idx_pet_person;",
+		"0011_postgres_only.postgres.up.sql":   "CREATE EXTENSION hstore;",
+		"0011_postgres_only.postgres.down.sql": "DROP EXTENSION hstore;",
+		"README.md":                            "not a migration",
+	}
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	migrations, err := LoadSQLMigrationsFromDir(dir, core.SQLITE)
+	assert.NoError(t, err)
+	var ids []string
+	for _, migration := range migrations {
+		ids = append(ids, migration.ID)
+		assert.NotEmpty(t, migration.Checksum)
+	}
+	assert.Equal(t, []string{"0001_create_person", "0002_create_pet", "0010_add_index"}, ids)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := New(db, DefaultOptions, migrations)
+	assert.NoError(t, m.Migrate())
+	assert.Equal(t, 1, tableCount(db, "person"))
+	assert.Equal(t, 3, tableCount(db, "migrations"))
+	var name string
+	assert.NoError(t, db.DB().QueryRow("SELECT name FROM person").Scan(&name))
+	assert.Equal(t, "a;b", name)
+
+	assert.NoError(t, m.RollbackAll())
+	exists, _ := db.IsTableExist("person")
+	assert.False(t, exists)
+	assert.Equal(t, 0, tableCount(db, "migrations"))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "0012_bad.mysqlx.up.sql"), nil, 0644))
+	_, err = LoadSQLMigrationsFromDir(dir, core.SQLITE)
+	assert.Error(t, err)
+}
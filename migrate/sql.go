@@ -0,0 +1,166 @@
+package migrate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/go-xorm/xorm"
+	"xorm.io/core"
+)
+
+// sqlFileRegexp matches the SQL migration files like 0001_create_user.up.sql or
+// 0003_add_index.postgres.down.sql
+var sqlFileRegexp = regexp.MustCompile(`^(\d+)_([^.]+)(?:\.([^.]+))?\.(up|down)\.sql$`)
+
+// sqlDialects are the dialect names which can be used in the names of the SQL files
+var sqlDialects = map[string]core.DbType{
+	string(core.MYSQL):    core.MYSQL,
+	string(core.POSTGRES): core.POSTGRES,
+	string(core.SQLITE):   core.SQLITE,
+	"sqlite":              core.SQLITE,
+	string(core.MSSQL):    core.MSSQL,
+	string(core.ORACLE):   core.ORACLE,
+}
+
+// sqlMigration is the SQL files of a migration
+type sqlMigration struct {
+	id      string
+	version uint64
+	up      []byte
+	down    []byte
+}
+
+// LoadSQLMigrationsFromDir is LoadSQLMigrations with the files in dir
+func LoadSQLMigrationsFromDir(dir string, dbType core.DbType) ([]*Migration, error) {
+	return LoadSQLMigrations(http.Dir(dir), dbType)
+}
+
+// LoadSQLMigrations builds the migrations from the SQL files in the root directory
+// of fs, the files are named as <version>_<name>.up.sql and <version>_<name>.down.sql
+// where version is a number and the migrations are ordered by it. The ID of the
+// migration is <version>_<name> and the checksum is the SHA-256 of the up file.
+//
+// A file named as <version>_<name>.<dialect>.up.sql, e.g. 0003_add_index.postgres.up.sql,
+// is used instead of the common one for the dialect, the dialect is one of mysql,
+// postgres, sqlite3, mssql and oracle. A migration which has no up file for dbType is
+// skipped. The statements are split by the rules of ImportWithOptions and executed
+// in a transaction if the database supports transactional DDL.
+func LoadSQLMigrations(fs http.FileSystem, dbType core.DbType) ([]*Migration, error) {
+	dir, err := fs.Open("/")
+	if err != nil {
+		return nil, err
+	}
+	infos, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var sqlMigrations = make(map[string]*sqlMigration)
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		matches := sqlFileRegexp.FindStringSubmatch(info.Name())
+		if matches == nil {
+			continue
+		}
+
+		var isDialect bool
+		if matches[3] != "" {
+			tp, ok := sqlDialects[matches[3]]
+			if !ok {
+				return nil, fmt.Errorf("unknown dialect %s of migration file %s", matches[3], info.Name())
+			}
+			if tp != dbType {
+				continue
+			}
+			isDialect = true
+		}
+
+		version, err := strconv.ParseUint(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version of migration file %s: %v", info.Name(), err)
+		}
+		id := matches[1] + "_" + matches[2]
+		mig, ok := sqlMigrations[id]
+		if !ok {
+			mig = &sqlMigration{id: id, version: version}
+			sqlMigrations[id] = mig
+		}
+
+		content, err := readSQLFile(fs, info.Name())
+		if err != nil {
+			return nil, err
+		}
+		// the dialect variant takes precedence over the common file
+		if matches[4] == "up" {
+			if mig.up == nil || isDialect {
+				mig.up = content
+			}
+		} else if mig.down == nil || isDialect {
+			mig.down = content
+		}
+	}
+
+	var sorted = make([]*sqlMigration, 0, len(sqlMigrations))
+	for _, mig := range sqlMigrations {
+		if mig.up == nil {
+			if mig.down != nil {
+				return nil, fmt.Errorf("migration %s has a down file but no up file", mig.id)
+			}
+			continue
+		}
+		sorted = append(sorted, mig)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].version != sorted[j].version {
+			return sorted[i].version < sorted[j].version
+		}
+		return sorted[i].id < sorted[j].id
+	})
+
+	var migrations = make([]*Migration, 0, len(sorted))
+	for _, mig := range sorted {
+		migrations = append(migrations, mig.migration())
+	}
+	return migrations, nil
+}
+
+func readSQLFile(fs http.FileSystem, name string) ([]byte, error) {
+	f, err := fs.Open("/" + name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func (mig *sqlMigration) migration() *Migration {
+	sum := sha256.Sum256(mig.up)
+	var migration = &Migration{
+		ID:       mig.id,
+		Checksum: hex.EncodeToString(sum[:]),
+		MigrateTx: func(tx *xorm.Session) error {
+			return execSQL(tx, mig.up)
+		},
+	}
+	if mig.down != nil {
+		migration.RollbackTx = func(tx *xorm.Session) error {
+			return execSQL(tx, mig.down)
+		}
+	}
+	return migration
+}
+
+func execSQL(tx *xorm.Session, content []byte) error {
+	_, err := tx.Import(bytes.NewReader(content))
+	return err
+}
@@ -4,6 +4,8 @@
 
 package xorm
 
+import "context"
+
 // BeforeInsertProcessor executed before an object is initially persisted to the database
 type BeforeInsertProcessor interface {
 	BeforeInsert()
@@ -54,6 +56,54 @@ type AfterLoadSessionProcessor interface {
 	AfterLoad(*Session)
 }
 
+// BeforeInsertSessionProcessor executed before an object is initially persisted to the database
+// with the session's context. A non-nil error aborts the insert and rolls back the transaction
+type BeforeInsertSessionProcessor interface {
+	BeforeInsert(context.Context, *Session) error
+}
+
+// BeforeUpdateSessionProcessor executed before an object is updated with the session's context.
+// A non-nil error aborts the update and rolls back the transaction
+type BeforeUpdateSessionProcessor interface {
+	BeforeUpdate(context.Context, *Session) error
+}
+
+// BeforeDeleteSessionProcessor executed before an object is deleted with the session's context.
+// A non-nil error aborts the delete and rolls back the transaction
+type BeforeDeleteSessionProcessor interface {
+	BeforeDelete(context.Context, *Session) error
+}
+
+// BeforeGetSessionProcessor executed before an object is retrieved by Get with the session's context.
+// A non-nil error aborts the query and rolls back the transaction
+type BeforeGetSessionProcessor interface {
+	BeforeGet(context.Context, *Session) error
+}
+
+// AfterInsertSessionProcessor executed after an object is persisted to the database, inside the
+// session's transaction. A non-nil error is returned by Insert and rolls back the transaction
+type AfterInsertSessionProcessor interface {
+	AfterInsert(context.Context, *Session) error
+}
+
+// AfterUpdateSessionProcessor executed after an object has been updated, inside the session's
+// transaction. A non-nil error is returned by Update and rolls back the transaction
+type AfterUpdateSessionProcessor interface {
+	AfterUpdate(context.Context, *Session) error
+}
+
+// AfterDeleteSessionProcessor executed after an object has been deleted, inside the session's
+// transaction. A non-nil error is returned by Delete and rolls back the transaction
+type AfterDeleteSessionProcessor interface {
+	AfterDelete(context.Context, *Session) error
+}
+
+// AfterGetSessionProcessor executed after an object has been retrieved by Get with the session's
+// context. A non-nil error is returned by Get and rolls back the transaction
+type AfterGetSessionProcessor interface {
+	AfterGet(context.Context, *Session) error
+}
+
 type executedProcessorFunc func(*Session, interface{}) error
 
 type executedProcessor struct {
@@ -76,3 +126,93 @@ func (session *Session) executeProcessors() error {
 	}
 	return nil
 }
+
+// hookSession returns a session which shares the connection, transaction and
+// context of session but has its own statement, so that a hook could run
+// queries without disturbing the operation which is in progress. The nested
+// transactions and the callbacks of the hook session are delegated to session.
+func (session *Session) hookSession() *Session {
+	hookSession := session.Clone()
+	hookSession.parent = session
+	if session.parent != nil {
+		hookSession.parent = session.parent
+	}
+	hookSession.statement.Init()
+	hookSession.statement.Engine = session.engine
+	hookSession.isAutoClose = false
+	hookSession.autoResetStatement = true
+	hookSession.beforeClosures = make([]func(interface{}), 0)
+	hookSession.afterClosures = make([]func(interface{}), 0)
+	hookSession.afterProcessors = make([]executedProcessor, 0)
+	return hookSession
+}
+
+// runHook executes a session processor, when it returns an error the
+// transaction of the session will be rollbacked if there is one.
+func (session *Session) runHook(hook func(context.Context, *Session) error) error {
+	if err := hook(session.ctx, session.hookSession()); err != nil {
+		if !session.isAutoCommit && !session.isCommitedOrRollbacked {
+			if rollbackErr := session.Rollback(); rollbackErr != nil {
+				session.engine.logger.Error(rollbackErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (session *Session) beforeInsertHook(bean interface{}) error {
+	if processor, ok := bean.(BeforeInsertSessionProcessor); ok {
+		return session.runHook(processor.BeforeInsert)
+	}
+	return nil
+}
+
+func (session *Session) beforeUpdateHook(bean interface{}) error {
+	if processor, ok := bean.(BeforeUpdateSessionProcessor); ok {
+		return session.runHook(processor.BeforeUpdate)
+	}
+	return nil
+}
+
+func (session *Session) beforeDeleteHook(bean interface{}) error {
+	if processor, ok := bean.(BeforeDeleteSessionProcessor); ok {
+		return session.runHook(processor.BeforeDelete)
+	}
+	return nil
+}
+
+func (session *Session) beforeGetHook(bean interface{}) error {
+	if processor, ok := bean.(BeforeGetSessionProcessor); ok {
+		return session.runHook(processor.BeforeGet)
+	}
+	return nil
+}
+
+func (session *Session) afterInsertHook(bean interface{}) error {
+	if processor, ok := bean.(AfterInsertSessionProcessor); ok {
+		return session.runHook(processor.AfterInsert)
+	}
+	return nil
+}
+
+func (session *Session) afterUpdateHook(bean interface{}) error {
+	if processor, ok := bean.(AfterUpdateSessionProcessor); ok {
+		return session.runHook(processor.AfterUpdate)
+	}
+	return nil
+}
+
+func (session *Session) afterDeleteHook(bean interface{}) error {
+	if processor, ok := bean.(AfterDeleteSessionProcessor); ok {
+		return session.runHook(processor.AfterDelete)
+	}
+	return nil
+}
+
+func (session *Session) afterGetHook(bean interface{}) error {
+	if processor, ok := bean.(AfterGetSessionProcessor); ok {
+		return session.runHook(processor.AfterGet)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@
 package xorm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -932,3 +933,185 @@ func TestAfterInsert(t *testing.T) {
 	_, err := testEngine.Insert(&AfterInsertStruct{})
 	assert.NoError(t, err)
 }
+
+type SessionProcessorsStruct struct {
+	Id      int64
+	Name    string
+	Version int `xorm:"-"`
+}
+
+type SessionProcessorsLog struct {
+	Id      int64
+	Content string
+}
+
+var errEmptyName = errors.New("name should not be empty")
+
+func (s *SessionProcessorsStruct) BeforeInsert(ctx context.Context, session *Session) error {
+	if s.Name == "" {
+		return errEmptyName
+	}
+	return nil
+}
+
+func (s *SessionProcessorsStruct) AfterInsert(ctx context.Context, session *Session) error {
+	_, err := session.Insert(&SessionProcessorsLog{Content: "insert " + s.Name})
+	return err
+}
+
+func (s *SessionProcessorsStruct) BeforeUpdate(ctx context.Context, session *Session) error {
+	if s.Name == "" {
+		return errEmptyName
+	}
+	return nil
+}
+
+func (s *SessionProcessorsStruct) AfterUpdate(ctx context.Context, session *Session) error {
+	_, err := session.Insert(&SessionProcessorsLog{Content: "update " + s.Name})
+	return err
+}
+
+func (s *SessionProcessorsStruct) BeforeDelete(ctx context.Context, session *Session) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (s *SessionProcessorsStruct) AfterDelete(ctx context.Context, session *Session) error {
+	_, err := session.Insert(&SessionProcessorsLog{Content: "delete"})
+	return err
+}
+
+func (s *SessionProcessorsStruct) AfterGet(ctx context.Context, session *Session) error {
+	s.Version++
+	return nil
+}
+
+func TestSessionProcessors(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(SessionProcessorsStruct), new(SessionProcessorsLog))
+
+	var s = SessionProcessorsStruct{Name: "a"}
+	cnt, err := testEngine.Insert(&s)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	cnt, err = testEngine.Insert(&SessionProcessorsStruct{})
+	assert.EqualValues(t, errEmptyName, err)
+	assert.EqualValues(t, 0, cnt)
+
+	var s2 SessionProcessorsStruct
+	has, err := testEngine.ID(s.Id).Get(&s2)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 1, s2.Version)
+
+	s2.Name = ""
+	_, err = testEngine.ID(s.Id).Update(&s2)
+	assert.EqualValues(t, errEmptyName, err)
+
+	s2.Name = "b"
+	cnt, err = testEngine.ID(s.Id).Update(&s2)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	cnt, err = testEngine.Count(new(SessionProcessorsLog))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = testEngine.Context(ctx).ID(s.Id).Delete(new(SessionProcessorsStruct))
+	assert.EqualValues(t, context.Canceled, err)
+
+	cnt, err = testEngine.ID(s.Id).Delete(new(SessionProcessorsStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	cnt, err = testEngine.Count(new(SessionProcessorsLog))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, cnt)
+}
+
+func TestSessionProcessorsTx(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(SessionProcessorsStruct), new(SessionProcessorsLog))
+
+	session := testEngine.NewSession()
+	defer session.Close()
+
+	assert.NoError(t, session.Begin())
+	_, err := session.Insert(&SessionProcessorsStruct{Name: "a"})
+	assert.NoError(t, err)
+	_, err = session.Insert(&[]SessionProcessorsStruct{{Name: "b"}, {}})
+	assert.EqualValues(t, errEmptyName, err)
+	assert.NoError(t, session.Commit())
+
+	cnt, err := testEngine.Count(new(SessionProcessorsStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	cnt, err = testEngine.Count(new(SessionProcessorsLog))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	assert.NoError(t, session.Begin())
+	_, err = session.Insert(&[]SessionProcessorsStruct{{Name: "a"}, {Name: "b"}})
+	assert.NoError(t, err)
+	assert.NoError(t, session.Commit())
+
+	cnt, err = testEngine.Count(new(SessionProcessorsStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	cnt, err = testEngine.Count(new(SessionProcessorsLog))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+}
+
+type SessionProcessorsCallback struct {
+	Id   int64
+	Name string
+}
+
+var sessionProcessorsCommitted bool
+
+func (s *SessionProcessorsCallback) BeforeInsert(ctx context.Context, session *Session) error {
+	session.OnCommit(func() {
+		sessionProcessorsCommitted = true
+	})
+
+	// the nested transaction of the hook belongs to the transaction of the insert
+	if err := session.Begin(); err != nil {
+		return err
+	}
+	if _, err := session.Insert(&SessionProcessorsLog{Content: "insert " + s.Name}); err != nil {
+		return err
+	}
+	return session.Rollback()
+}
+
+func TestSessionProcessorsTxCallbacks(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(SessionProcessorsCallback), new(SessionProcessorsLog))
+
+	session := testEngine.NewSession()
+	defer session.Close()
+
+	sessionProcessorsCommitted = false
+	assert.NoError(t, session.Begin())
+	_, err := session.Insert(&SessionProcessorsCallback{Name: "a"})
+	assert.NoError(t, err)
+	assert.False(t, sessionProcessorsCommitted)
+	assert.EqualValues(t, 0, len(session.savepoints))
+	assert.NoError(t, session.Commit())
+	assert.True(t, sessionProcessorsCommitted)
+
+	cnt, err := testEngine.Count(new(SessionProcessorsCallback))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	cnt, err = testEngine.Count(new(SessionProcessorsLog))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+}
@@ -0,0 +1,215 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"xorm.io/core"
+)
+
+type relationType int
+
+const (
+	// relationHasOne means the related record has the foreign keys referencing this record
+	relationHasOne relationType = iota + 1
+	// relationHasMany means the related records have the foreign keys referencing this record
+	relationHasMany
+	// relationBelongsTo means this record has the foreign keys referencing the related record
+	relationBelongsTo
+	// relationManyToMany means the records are related via a join table
+	relationManyToMany
+)
+
+var relationTypes = map[string]relationType{
+	"HAS_ONE":    relationHasOne,
+	"HAS_MANY":   relationHasMany,
+	"BELONGS_TO": relationBelongsTo,
+	"MANY2MANY":  relationManyToMany,
+}
+
+// relation describes a struct field which is tagged as a relation, e.g.
+//
+//	Orders []Order `xorm:"has_many(user_id)"`
+//	User   *User   `xorm:"belongs_to(user_id)"`
+//	Groups []Group `xorm:"many2many(user_group,user_id,group_id)"`
+type relation struct {
+	Type      relationType
+	FieldName string
+	FieldType reflect.Type
+	ElemType  reflect.Type // the struct type of the related records
+	Params    []string
+}
+
+// relationElemType returns the struct type of the related records, has_one and
+// belongs_to should be a struct or a pointer to struct, has_many and many2many
+// should be a slice of them.
+func relationElemType(tp relationType, fieldType reflect.Type) (reflect.Type, error) {
+	t := fieldType
+	if tp == relationHasMany || tp == relationManyToMany {
+		if t.Kind() != reflect.Slice {
+			return nil, errors.New("has_many or many2many relation should be a slice")
+		}
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("relation should be a struct or a slice of struct")
+	}
+	return t, nil
+}
+
+// parseRelation returns the relation of the field or nil if it's not a relation
+func (engine *Engine) parseRelation(field reflect.StructField) (*relation, error) {
+	for _, key := range splitTag(field.Tag.Get(engine.TagIdentifier)) {
+		var name = strings.ToUpper(key)
+		var params []string
+		if pStart := strings.Index(key, "("); pStart > 0 && strings.HasSuffix(key, ")") {
+			name = strings.ToUpper(key[:pStart])
+			for _, param := range strings.Split(key[pStart+1:len(key)-1], ",") {
+				params = append(params, strings.Trim(strings.TrimSpace(param), "'\""))
+			}
+		}
+
+		tp, ok := relationTypes[name]
+		if !ok {
+			continue
+		}
+		elemType, err := relationElemType(tp, field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", field.Name, err)
+		}
+		return &relation{
+			Type:      tp,
+			FieldName: field.Name,
+			FieldType: field.Type,
+			ElemType:  elemType,
+			Params:    params,
+		}, nil
+	}
+	return nil, nil
+}
+
+// relationOf returns the relation of the struct's field
+func (engine *Engine) relationOf(t reflect.Type, fieldName string) (*relation, error) {
+	field, ok := t.FieldByName(fieldName)
+	if !ok {
+		return nil, fmt.Errorf("field %s not found in %s", fieldName, t.Name())
+	}
+	rel, err := engine.parseRelation(field)
+	if err != nil {
+		return nil, err
+	}
+	if rel == nil {
+		return nil, fmt.Errorf("field %s of %s is not a relation", fieldName, t.Name())
+	}
+	return rel, nil
+}
+
+// relationsOf returns all the relations of the struct
+func (engine *Engine) relationsOf(t reflect.Type) ([]*relation, error) {
+	var relations []*relation
+	for i := 0; i < t.NumField(); i++ {
+		rel, err := engine.parseRelation(t.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		if rel != nil {
+			relations = append(relations, rel)
+		}
+	}
+	return relations, nil
+}
+
+// joinColumns returns the columns of the owner table and the related table which
+// have the same values for the related records, for many2many they are the primary
+// keys referenced by the join table
+func (rel *relation) joinColumns(engine *Engine, owner, related *core.Table) (ownerCols, relatedCols []string, err error) {
+	switch rel.Type {
+	case relationHasOne, relationHasMany:
+		ownerCols = owner.PrimaryKeys
+		relatedCols = rel.Params
+		if len(relatedCols) == 0 {
+			relatedCols = defaultForeignKeys(engine, owner.Type.Name(), owner.PrimaryKeys)
+		}
+	case relationBelongsTo:
+		ownerCols = rel.Params
+		if len(ownerCols) == 0 {
+			ownerCols = defaultForeignKeys(engine, rel.FieldName, related.PrimaryKeys)
+		}
+		relatedCols = related.PrimaryKeys
+	case relationManyToMany:
+		return owner.PrimaryKeys, related.PrimaryKeys, nil
+	}
+
+	if len(ownerCols) == 0 || len(ownerCols) != len(relatedCols) {
+		return nil, nil, fmt.Errorf("relation %s has mismatched keys %v and %v", rel.FieldName, ownerCols, relatedCols)
+	}
+	return ownerCols, relatedCols, nil
+}
+
+// joinTable returns the join table of many2many relation and the columns which
+// reference the owner table and the related table
+func (rel *relation) joinTable(engine *Engine, owner, related *core.Table) (tableName string, ownerCols, relatedCols []string, err error) {
+	if len(rel.Params) == 0 {
+		return "", nil, nil, fmt.Errorf("many2many relation %s needs a join table", rel.FieldName)
+	}
+	tableName = rel.Params[0]
+
+	if len(rel.Params) >= 3 {
+		ownerCols = []string{rel.Params[1]}
+		relatedCols = []string{rel.Params[2]}
+	} else {
+		ownerCols = defaultForeignKeys(engine, owner.Type.Name(), owner.PrimaryKeys)
+		relatedCols = defaultForeignKeys(engine, related.Type.Name(), related.PrimaryKeys)
+	}
+
+	if len(ownerCols) != len(owner.PrimaryKeys) || len(relatedCols) != len(related.PrimaryKeys) {
+		return "", nil, nil, fmt.Errorf("many2many relation %s has mismatched keys", rel.FieldName)
+	}
+	return tableName, ownerCols, relatedCols, nil
+}
+
+// defaultForeignKeys returns the foreign key columns as name_pk, e.g. user_id
+func defaultForeignKeys(engine *Engine, name string, pkCols []string) []string {
+	var prefix = engine.ColumnMapper.Obj2Table(name)
+	var cols = make([]string, 0, len(pkCols))
+	for _, pk := range pkCols {
+		cols = append(cols, prefix+"_"+pk)
+	}
+	return cols
+}
+
+// relationKey returns the values of the columns of the struct and the key which
+// identifies the values, ok is false if any value is nil
+func relationKey(table *core.Table, structValue reflect.Value, colNames []string) (values []interface{}, key string, ok bool, err error) {
+	var keys = make([]string, 0, len(colNames))
+	values = make([]interface{}, 0, len(colNames))
+	for _, colName := range colNames {
+		col := table.GetColumn(colName)
+		if col == nil {
+			return nil, "", false, fmt.Errorf("column %s not found in table %s", colName, table.Name)
+		}
+		fieldValue, err := col.ValueOfV(&structValue)
+		if err != nil {
+			return nil, "", false, err
+		}
+		if fieldValue.Kind() == reflect.Ptr {
+			if fieldValue.IsNil() {
+				return nil, "", false, nil
+			}
+			v := fieldValue.Elem()
+			fieldValue = &v
+		}
+		values = append(values, fieldValue.Interface())
+		keys = append(keys, fmt.Sprint(fieldValue.Interface()))
+	}
+	return values, strings.Join(keys, "\x00"), true, nil
+}
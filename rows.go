@@ -17,6 +17,7 @@ type Rows struct {
 	session   *Session
 	rows      *core.Rows
 	beanType  reflect.Type
+	page      *Page
 	lastError error
 }
 
@@ -37,6 +38,14 @@ func newRows(session *Session, bean interface{}) (*Rows, error) {
 		return nil, ErrTableNotFound
 	}
 
+	if rows.page = session.statement.page; rows.page != nil {
+		pageCond, err := rows.page.prepare(session, session.statement.RefTable)
+		if err != nil {
+			return nil, err
+		}
+		session.statement.cond = session.statement.cond.And(pageCond)
+	}
+
 	if rows.session.statement.RawSQL == "" {
 		sqlStr, args, err = rows.session.statement.genGetSQL(bean)
 		if err != nil {
@@ -104,6 +113,12 @@ func (rows *Rows) Scan(bean interface{}) error {
 		return err
 	}
 
+	if rows.page != nil {
+		if err := rows.page.scan(bean); err != nil {
+			return err
+		}
+	}
+
 	return rows.session.executeProcessors()
 }
 
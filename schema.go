@@ -0,0 +1,375 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"strings"
+
+	"xorm.io/core"
+)
+
+// Check is a CHECK constraint of a table, it's declared by the check tag of a field,
+// e.g.
+//
+//	Price int `xorm:"check('price > 0')"`
+type Check struct {
+	Name string
+	Expr string
+}
+
+// ColumnSchema describes the attributes of a column which core.Column has not, they
+// are declared by the tags of a field, e.g.
+//
+//	Name  string `xorm:"varchar(50) collate(utf8mb4_bin)"`
+//	Total int    `xorm:"generated('price * quantity') stored"`
+type ColumnSchema struct {
+	Collation string
+	// Generated is the expression of the generated column, the column is read only
+	Generated string
+	// Stored means the generated column is stored instead of computed when read
+	Stored bool
+}
+
+func (attrs *ColumnSchema) collation() string {
+	if attrs == nil {
+		return ""
+	}
+	return attrs.Collation
+}
+
+func (attrs *ColumnSchema) generated() string {
+	if attrs == nil {
+		return ""
+	}
+	return attrs.Generated
+}
+
+// TableSchema describes the constraints and the column attributes of a table which
+// core.Table has not
+type TableSchema struct {
+	ForeignKeys []*ForeignKey
+	Checks      []*Check
+	// Columns are the attributes of the columns by the column names
+	Columns map[string]*ColumnSchema
+}
+
+// Column returns the attributes of the column or nil
+func (schema *TableSchema) Column(name string) *ColumnSchema {
+	if schema == nil {
+		return nil
+	}
+	if attrs, ok := schema.Columns[name]; ok {
+		return attrs
+	}
+	for colName, attrs := range schema.Columns {
+		if strings.EqualFold(colName, name) {
+			return attrs
+		}
+	}
+	return nil
+}
+
+func (schema *TableSchema) isEmpty() bool {
+	return schema == nil || len(schema.ForeignKeys) == 0 && len(schema.Checks) == 0 && len(schema.Columns) == 0
+}
+
+// schemaDialect is implemented by the dialects which support the foreign keys, the
+// CHECK constraints, the generated columns and the collations of the columns
+type schemaDialect interface {
+	// CreateTableWithSchemaSql is CreateTableSql with the constraints and the
+	// attributes of the columns
+	CreateTableWithSchemaSql(table *core.Table, tableName, storeEngine, charset string, schema *TableSchema) string
+	// AddColumnSql returns the statement to add the column with its attributes or ""
+	// if the column cannot be added by ALTER TABLE
+	AddColumnSql(tableName string, col *core.Column, attrs *ColumnSchema) string
+	// AddForeignKeySql returns the statement to add the foreign key to the table or
+	// "" if the constraints cannot be added by ALTER TABLE
+	AddForeignKeySql(tableName string, fk *ForeignKey) string
+	// AddCheckSql returns the statement to add the CHECK constraint to the table or
+	// "" if the constraints cannot be added by ALTER TABLE
+	AddCheckSql(tableName string, check *Check) string
+	// GetForeignKeys returns the foreign keys of the table
+	GetForeignKeys(tableName string) ([]*ForeignKey, error)
+	// GetChecks returns the CHECK constraints of the table
+	GetChecks(tableName string) ([]*Check, error)
+	// GetColumnSchemas returns the attributes of the columns which have any
+	GetColumnSchemas(tableName string) (map[string]*ColumnSchema, error)
+}
+
+// checkName returns the name of the CHECK constraint declared on the column
+func checkName(tableName, colName string) string {
+	if idx := strings.LastIndex(tableName, "."); idx > -1 {
+		tableName = tableName[idx+1:]
+	}
+	return fmt.Sprintf("CK_%s_%s", tableName, colName)
+}
+
+// checkTableSchema returns an error if the attributes of the columns are not supported
+// by the database
+func (engine *Engine) checkTableSchema(schema *TableSchema) error {
+	if schema == nil {
+		return nil
+	}
+	for _, attrs := range schema.Columns {
+		if err := engine.checkColumnSchema(attrs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkColumnSchema returns an error if the attributes of the column are not supported
+// by the database, the generated columns need SQLite 3.31.0 or later
+func (engine *Engine) checkColumnSchema(attrs *ColumnSchema) error {
+	if dialect, ok := engine.dialect.(*sqlite3); ok && attrs != nil && attrs.Generated != "" {
+		return dialect.requireVersion("generated columns", "3.31.0")
+	}
+	return nil
+}
+
+// tableSchemaOf returns the foreign keys, the CHECK constraints and the attributes of
+// the columns declared by the tags of the struct
+func (engine *Engine) tableSchemaOf(table *core.Table, tableName string) *TableSchema {
+	var schema = TableSchema{
+		ForeignKeys: engine.foreignKeysOf(table, tableName),
+		Columns:     make(map[string]*ColumnSchema),
+	}
+	if table.Type == nil {
+		return &schema
+	}
+
+	for _, col := range table.Columns() {
+		tags := engine.fieldTags(table.Type, col.FieldName)
+		if expr, ok := tagParam(tags, "check"); ok {
+			schema.Checks = append(schema.Checks, &Check{
+				Name: checkName(tableName, col.Name),
+				Expr: expr,
+			})
+		}
+
+		var attrs ColumnSchema
+		attrs.Collation, _ = tagParam(tags, "collate")
+		if expr, ok := tagParam(tags, "generated"); ok {
+			attrs.Generated = expr
+			attrs.Stored = hasTagAfter(tags, "generated", "stored")
+		}
+		if attrs != (ColumnSchema{}) {
+			schema.Columns[col.Name] = &attrs
+		}
+	}
+	return &schema
+}
+
+// createTableSQL returns the statement to create the table with its constraints and
+// the attributes of its columns
+func (engine *Engine) createTableSQL(table *core.Table, tableName, storeEngine, charset string, schema *TableSchema) string {
+	if dialect, ok := engine.dialect.(schemaDialect); ok && !schema.isEmpty() {
+		return dialect.CreateTableWithSchemaSql(table, tableName, storeEngine, charset, schema)
+	}
+	return engine.dialect.CreateTableSql(table, tableName, storeEngine, charset)
+}
+
+// DBTableSchema returns the foreign keys, the CHECK constraints and the attributes of
+// the columns of the table in the database
+func (engine *Engine) DBTableSchema(tableName string) (*TableSchema, error) {
+	dialect, ok := engine.dialect.(schemaDialect)
+	if !ok {
+		return nil, fmt.Errorf("table schema is not supported on %s", engine.dialect.DBType())
+	}
+
+	var schema TableSchema
+	var err error
+	if schema.ForeignKeys, err = dialect.GetForeignKeys(tableName); err != nil {
+		return nil, err
+	}
+	if schema.Checks, err = dialect.GetChecks(tableName); err != nil {
+		return nil, err
+	}
+	if schema.Columns, err = dialect.GetColumnSchemas(tableName); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// dbTableSchemaFor is DBTableSchema for Sync2, the CHECK constraints and the
+// attributes of the columns are read only if the struct declares any since reading
+// them requires the newer versions of the databases. SQLite reads them always to
+// rebuild the tables.
+func (engine *Engine) dbTableSchemaFor(tableName string, schema *TableSchema) (*TableSchema, error) {
+	var dbSchema TableSchema
+	dialect, ok := engine.dialect.(schemaDialect)
+	if !ok {
+		return &dbSchema, nil
+	}
+
+	var err error
+	var always = engine.dialect.DBType() == core.SQLITE
+	if dbSchema.ForeignKeys, err = dialect.GetForeignKeys(tableName); err != nil {
+		return nil, err
+	}
+	if always || len(schema.Checks) > 0 {
+		if dbSchema.Checks, err = dialect.GetChecks(tableName); err != nil {
+			return nil, err
+		}
+	}
+	if always || len(schema.Columns) > 0 {
+		if dbSchema.Columns, err = dialect.GetColumnSchemas(tableName); err != nil {
+			return nil, err
+		}
+	}
+	return &dbSchema, nil
+}
+
+// columnDefinition returns the definition of the column in CREATE TABLE or ADD, the
+// collation and the generated clause follow the type and the default of the generated
+// column is left out
+func columnDefinition(d core.Dialect, col *core.Column, pk bool, attrs *ColumnSchema) string {
+	if attrs != nil && attrs.Generated != "" && col.Default != "" {
+		var generated = *col
+		generated.Default = ""
+		col = &generated
+	}
+
+	var sql string
+	if pk {
+		sql = col.String(d)
+	} else {
+		sql = col.StringNoPk(d)
+	}
+	if attrs == nil {
+		return sql
+	}
+
+	var clauses string
+	if attrs.Collation != "" {
+		// the collations of Postgres are identifiers which are case sensitive
+		if d.DBType() == core.POSTGRES {
+			clauses += `COLLATE "` + attrs.Collation + `" `
+		} else {
+			clauses += "COLLATE " + attrs.Collation + " "
+		}
+	}
+	if attrs.Generated != "" {
+		// Oracle only has the virtual columns
+		if attrs.Stored && d.DBType() != core.ORACLE {
+			clauses += "GENERATED ALWAYS AS (" + attrs.Generated + ") STORED "
+		} else {
+			clauses += "GENERATED ALWAYS AS (" + attrs.Generated + ") VIRTUAL "
+		}
+	}
+	prefix := d.Quote(col.Name) + " " + d.SqlType(col) + " "
+	return prefix + clauses + sql[len(prefix):]
+}
+
+// createTableSql is CreateTableSql of core.Base with the constraints and the
+// attributes of the columns for the dialects which have no engine or charset
+func createTableSql(d core.Dialect, table *core.Table, tableName string, schema *TableSchema) string {
+	if tableName == "" {
+		tableName = table.Name
+	}
+	sql := "CREATE TABLE IF NOT EXISTS " + d.Quote(tableName) + " ("
+
+	if len(table.ColumnsSeq()) > 0 {
+		pkList := table.PrimaryKeys
+
+		for _, colName := range table.ColumnsSeq() {
+			col := table.GetColumn(colName)
+			sql += columnDefinition(d, col, col.IsPrimaryKey && len(pkList) == 1, schema.Column(colName))
+			sql = strings.TrimSpace(sql)
+			sql += ", "
+		}
+
+		if len(pkList) > 1 {
+			sql += "PRIMARY KEY ( "
+			sql += d.Quote(strings.Join(pkList, d.Quote(",")))
+			sql += " ), "
+		}
+
+		if schema != nil {
+			for _, fk := range schema.ForeignKeys {
+				sql += foreignKeyClause(d.Quote, fk) + ", "
+			}
+			for _, check := range schema.Checks {
+				sql += checkClause(d.Quote, check) + ", "
+			}
+		}
+
+		sql = sql[:len(sql)-2]
+	}
+	return sql + ")"
+}
+
+// checkClause returns the CHECK constraint in CREATE TABLE or ALTER TABLE ADD, the
+// constraint is unnamed if the check has no name
+func checkClause(quote func(string) string, check *Check) string {
+	var sql string
+	if check.Name != "" {
+		sql = "CONSTRAINT " + quote(check.Name) + " "
+	}
+	return sql + "CHECK (" + check.Expr + ")"
+}
+
+// unwrapCheck returns the expression of the CHECK constraint which is read from the
+// database as CHECK ((price > 0)) or (price > 0)
+func unwrapCheck(def string) string {
+	def = strings.TrimSpace(def)
+	if strings.HasPrefix(strings.ToUpper(def), "CHECK") {
+		def = strings.TrimSpace(def[len("CHECK"):])
+	}
+	if strings.HasSuffix(strings.ToUpper(def), " NOT VALID") {
+		def = strings.TrimSpace(def[:len(def)-len(" NOT VALID")])
+	}
+	// only the parentheses which enclose the whole expression are removed
+	for strings.HasPrefix(def, "(") && strings.HasSuffix(def, ")") {
+		var depth int
+		for i, c := range def {
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				depth--
+			}
+			if depth == 0 && i < len(def)-1 {
+				return def
+			}
+		}
+		def = strings.TrimSpace(def[1 : len(def)-1])
+	}
+	return def
+}
+
+// planChecks returns the changes to add the CHECK constraints of the struct which
+// are not in the database by the names, the constraints are never dropped
+func (engine *Engine) planChecks(tbName, tbNameWithSchema string, checks, dbChecks []*Check) []*SyncChange {
+	dialect, ok := engine.dialect.(schemaDialect)
+	if !ok {
+		return nil
+	}
+
+	var changes []*SyncChange
+	for _, check := range checks {
+		var found bool
+		for _, dbCheck := range dbChecks {
+			if strings.EqualFold(check.Name, dbCheck.Name) {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		change := &SyncChange{
+			Type:  SyncAddCheck,
+			Table: tbName,
+			Check: check,
+		}
+		if sql := dialect.AddCheckSql(tbNameWithSchema, check); sql != "" {
+			change.SQLs = []string{sql}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
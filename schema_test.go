@@ -0,0 +1,162 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+type SchemaOrderLine struct {
+	Id       int64
+	Name     string `xorm:"varchar(50) collate(utf8mb4_bin)"`
+	Price    int    `xorm:"check('price > 0')"`
+	Quantity int    `xorm:"default 1"`
+	Total    int    `xorm:"generated('price * quantity') stored"`
+}
+
+func TestTableSchemaSQL(t *testing.T) {
+	mysql, err := NewEngine("mysql", "root:@/xorm_test")
+	assert.NoError(t, err)
+	table, err := mysql.autoMapType(rValue(new(SchemaOrderLine)))
+	assert.NoError(t, err)
+	assert.EqualValues(t, "CREATE TABLE IF NOT EXISTS `schema_order_line` (`id` BIGINT(20) PRIMARY KEY AUTO_INCREMENT NOT NULL, "+
+		"`name` VARCHAR(50) COLLATE utf8mb4_bin NULL, `price` INT NULL, `quantity` INT DEFAULT 1 NULL, "+
+		"`total` INT GENERATED ALWAYS AS (price * quantity) STORED NULL, "+
+		"CONSTRAINT `CK_schema_order_line_price` CHECK (price > 0))",
+		mysql.createTableSQL(table, "schema_order_line", "", "", mysql.tableSchemaOf(table, "schema_order_line")))
+
+	// the generated column is only read from the database
+	assert.EqualValues(t, core.ONLYFROMDB, table.GetColumn("total").MapType)
+
+	postgres, err := NewEngine("postgres", "dbname=xorm_test sslmode=disable")
+	assert.NoError(t, err)
+	table, err = postgres.autoMapType(rValue(new(SchemaOrderLine)))
+	assert.NoError(t, err)
+	schema := postgres.tableSchemaOf(table, "schema_order_line")
+	assert.EqualValues(t, `ALTER TABLE "schema_order_line" ADD "name" VARCHAR(50) COLLATE "utf8mb4_bin" NULL`,
+		postgres.dialect.(schemaDialect).AddColumnSql("schema_order_line", table.GetColumn("name"), schema.Column("name")))
+	assert.EqualValues(t, `ALTER TABLE "schema_order_line" ADD CONSTRAINT "CK_schema_order_line_price" CHECK (price > 0)`,
+		postgres.dialect.(schemaDialect).AddCheckSql("schema_order_line", schema.Checks[0]))
+
+	assert.EqualValues(t, `"total" AS (price * quantity) PERSISTED`,
+		new(mssql).columnDefinition(table.GetColumn("total"), false, schema.Column("total")))
+}
+
+func TestUnwrapCheck(t *testing.T) {
+	assert.EqualValues(t, "price > 0", unwrapCheck("CHECK ((price > 0))"))
+	assert.EqualValues(t, "[price]>(0)", unwrapCheck("([price]>(0))"))
+	assert.EqualValues(t, "(a > 0) AND (b > 0)", unwrapCheck("CHECK ((a > 0) AND (b > 0)) NOT VALID"))
+}
+
+type SchemaProduct struct {
+	Id    int64
+	Name  string `xorm:"varchar(50) collate(NOCASE)"`
+	Price int    `xorm:"check('price > 0')"`
+}
+
+type SchemaProductV1 struct {
+	Id    int64
+	Name  string `xorm:"varchar(50)"`
+	Price int
+}
+
+func (SchemaProductV1) TableName() string {
+	return "schema_product"
+}
+
+func TestTableSchemaTags(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	if engine.dialect.DBType() != core.SQLITE {
+		t.Skip("the collation is of SQLite")
+	}
+	assert.NoError(t, engine.DropTables(new(SchemaProduct)))
+	assert.NoError(t, engine.CreateTables(new(SchemaProduct)))
+
+	schema, err := engine.DBTableSchema("schema_product")
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 1, len(schema.Checks)) {
+		assert.EqualValues(t, "CK_schema_product_price", schema.Checks[0].Name)
+		assert.EqualValues(t, "price > 0", schema.Checks[0].Expr)
+	}
+	assert.EqualValues(t, "NOCASE", schema.Column("name").collation())
+
+	_, err = engine.Insert(&SchemaProduct{Name: "a", Price: 0})
+	assert.Error(t, err)
+}
+
+func TestSyncTableSchema(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	if engine.dialect.DBType() != core.SQLITE {
+		t.Skip("the collation is of SQLite")
+	}
+	assert.NoError(t, engine.DropTables(new(SchemaProduct)))
+	assert.NoError(t, engine.Sync2(new(SchemaProductV1)))
+	_, err := engine.Insert(&SchemaProductV1{Name: "a", Price: 1})
+	assert.NoError(t, err)
+
+	plan, err := engine.PlanSync(new(SchemaProduct))
+	assert.NoError(t, err)
+	var types = make(map[SyncChangeType]int)
+	for _, change := range plan.Changes {
+		types[change.Type]++
+	}
+	assert.EqualValues(t, map[SyncChangeType]int{SyncAlterColumn: 1, SyncAddCheck: 1, SyncRebuildTable: 1}, types)
+
+	assert.NoError(t, engine.Sync2(new(SchemaProduct)))
+	schema, err := engine.DBTableSchema("schema_product")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(schema.Checks))
+	assert.EqualValues(t, "NOCASE", schema.Column("name").collation())
+
+	var product SchemaProduct
+	has, err := engine.Where("name = ?", "A").Get(&product)
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	plan, err = engine.PlanSync(new(SchemaProduct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(plan.Changes))
+}
+
+func TestCheckTableSchema(t *testing.T) {
+	var schema = &TableSchema{Columns: map[string]*ColumnSchema{"total": {Generated: "price * quantity"}}}
+	engine := &Engine{dialect: &sqlite3{version: "3.25.2"}}
+	assert.EqualValues(t, ErrSQLiteVersion{Feature: "generated columns", Required: "3.31.0", Version: "3.25.2"},
+		engine.checkTableSchema(schema))
+	engine = &Engine{dialect: &sqlite3{version: "3.31.0"}}
+	assert.NoError(t, engine.checkTableSchema(schema))
+	engine = &Engine{dialect: &mysql{}}
+	assert.NoError(t, engine.checkTableSchema(schema))
+}
+
+func TestSyncGeneratedColumnsSQLite(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	dialect, ok := engine.dialect.(*sqlite3)
+	if !ok {
+		t.Skip("the version is of SQLite")
+	}
+	version, err := dialect.sqliteVersion()
+	assert.NoError(t, err)
+	if compareVersions(version, "3.31.0") >= 0 {
+		t.Skip("the generated columns are supported")
+	}
+	assert.NoError(t, engine.DropTables(new(SchemaOrderLine)))
+
+	// the error is returned before any DDL is executed
+	_, ok = engine.CreateTables(new(SchemaOrderLine)).(ErrSQLiteVersion)
+	assert.True(t, ok)
+	_, err = engine.PlanSync(new(SchemaOrderLine))
+	_, ok = err.(ErrSQLiteVersion)
+	assert.True(t, ok)
+	exist, err := engine.IsTableExist(new(SchemaOrderLine))
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
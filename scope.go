@@ -0,0 +1,69 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+// Scope is a reusable function which adds conditions, joins, orders and so on to a session,
+// it should return the session passed in, e.g.
+//
+//	func Active(session *Session) *Session {
+//		return session.Where("status = ?", "active")
+//	}
+type Scope func(*Session) *Session
+
+// Scopes applies the scopes to the session
+func (session *Session) Scopes(scopes ...Scope) *Session {
+	for _, scope := range scopes {
+		session = scope(session)
+	}
+	return session
+}
+
+// SetDefaultScopes sets the scopes which will be applied to Find, Get, Count, Update and
+// Delete of the table automatically, the scopes could be disabled by NoDefaultScopes(). Calling
+// it without scopes removes the default scopes of the table.
+func (engine *Engine) SetDefaultScopes(tableNameOrBean interface{}, scopes ...Scope) {
+	tableName := engine.TableName(tableNameOrBean)
+
+	engine.defaultScopesLock.Lock()
+	defer engine.defaultScopesLock.Unlock()
+	if engine.defaultScopes == nil {
+		engine.defaultScopes = make(map[string][]Scope)
+	}
+	if len(scopes) == 0 {
+		delete(engine.defaultScopes, tableName)
+		return
+	}
+	engine.defaultScopes[tableName] = scopes
+}
+
+// NoDefaultScopes disables the default scopes of the table
+func (engine *Engine) NoDefaultScopes() *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.NoDefaultScopes()
+}
+
+// NoDefaultScopes disables the default scopes of the table
+func (session *Session) NoDefaultScopes() *Session {
+	session.statement.noDefaultScopes = true
+	return session
+}
+
+func (engine *Engine) getDefaultScopes(tableName string) []Scope {
+	engine.defaultScopesLock.RLock()
+	defer engine.defaultScopesLock.RUnlock()
+	return engine.defaultScopes[tableName]
+}
+
+// applyDefaultScopes applies the default scopes of the statement's table once
+func (session *Session) applyDefaultScopes() {
+	if session.statement.noDefaultScopes || session.statement.scopesApplied {
+		return
+	}
+	session.statement.scopesApplied = true
+	for _, scope := range session.engine.getDefaultScopes(session.statement.TableName()) {
+		scope(session)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopes(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type ScopeStruct struct {
+		Id       int64
+		TenantId int64
+		Name     string
+		Active   bool
+	}
+
+	assertSync(t, new(ScopeStruct))
+
+	_, err := testEngine.Insert([]ScopeStruct{
+		{TenantId: 1, Name: "a", Active: true},
+		{TenantId: 1, Name: "b", Active: false},
+		{TenantId: 2, Name: "c", Active: true},
+	})
+	assert.NoError(t, err)
+
+	tenant := func(id int64) Scope {
+		return func(session *Session) *Session {
+			return session.And("tenant_id = ?", id)
+		}
+	}
+	active := func(session *Session) *Session {
+		return session.And("active = ?", true)
+	}
+
+	var ss []ScopeStruct
+	session := testEngine.NewSession()
+	defer session.Close()
+	assert.NoError(t, session.Scopes(tenant(1), active).Find(&ss))
+	assert.EqualValues(t, 1, len(ss))
+	assert.EqualValues(t, "a", ss[0].Name)
+
+	engine := testEngine.(*Engine)
+	engine.SetDefaultScopes(new(ScopeStruct), tenant(1))
+	defer engine.SetDefaultScopes(new(ScopeStruct))
+
+	ss = nil
+	assert.NoError(t, testEngine.Asc("id").Find(&ss))
+	assert.EqualValues(t, 2, len(ss))
+
+	cnt, err := testEngine.Count(new(ScopeStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	cnt, err = testEngine.Where("name = ?", "a").FindAndCount(&ss)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	var s ScopeStruct
+	has, err := testEngine.Where("name = ?", "c").Get(&s)
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	cnt, err = testEngine.Cols("active").Update(&ScopeStruct{Active: true})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	cnt, err = testEngine.Where("active = ?", true).Delete(new(ScopeStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	// Unscoped only disables the soft delete
+	cnt, err = testEngine.Unscoped().Count(new(ScopeStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	cnt, err = testEngine.NoDefaultScopes().Count(new(ScopeStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+}
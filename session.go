@@ -34,6 +34,11 @@ type Session struct {
 	isAutoCommit           bool
 	isCommitedOrRollbacked bool
 	isAutoClose            bool
+	savepoints             []*savepoint
+
+	// parent is the session which the hook session is created from, the savepoints
+	// and the callbacks of the transaction are kept by it
+	parent *Session
 
 	// Automatically reset the statement after operations that execute a SQL
 	// query such as Count(), Find(), Get(), ...
@@ -45,6 +50,10 @@ type Session struct {
 	afterDeleteBeans map[interface{}]*[]func(interface{})
 	// --
 
+	// the callbacks which will be called when the transaction ends
+	commitCallbacks   []func()
+	rollbackCallbacks []func()
+
 	beforeClosures []func(interface{})
 	afterClosures  []func(interface{})
 
@@ -53,6 +62,9 @@ type Session struct {
 	prepareStmt bool
 	stmtCache   map[uint32]*core.Stmt //key: hash.Hash32 of (queryStr, len(queryStr))
 
+	// the max records count of one insert statement, 0 means decided by dialect
+	insertChunkSize int
+
 	// !evalphobia! stored the last executed query on this session
 	//beforeSQLExec func(string, ...interface{})
 	lastSQL     string
@@ -77,6 +89,10 @@ func (session *Session) Init() {
 	session.isAutoClose = false
 	session.autoResetStatement = true
 	session.prepareStmt = false
+	session.insertChunkSize = 0
+	session.savepoints = nil
+	session.commitCallbacks = nil
+	session.rollbackCallbacks = nil
 
 	// !nashtsai! is lazy init better?
 	session.afterInsertBeans = make(map[interface{}]*[]func(interface{}), 0)
@@ -104,6 +120,8 @@ func (session *Session) Close() {
 		// When Close be called, if session is a transaction and do not call
 		// Commit or Rollback, then call Rollback.
 		if session.tx != nil && !session.isCommitedOrRollbacked {
+			// rollback the whole transaction but not the last savepoint
+			session.savepoints = nil
 			session.Rollback()
 		}
 		session.tx = nil
@@ -135,6 +153,13 @@ func (session *Session) Prepare() *Session {
 	return session
 }
 
+// InsertChunkSize set how many records one insert statement contains when
+// inserting multiple records on this session, 0 means decided by the dialect
+func (session *Session) InsertChunkSize(size int) *Session {
+	session.insertChunkSize = size
+	return session
+}
+
 // Before Apply before Processor, affected bean is passed to closure arg
 func (session *Session) Before(closures func(interface{})) *Session {
 	if closures != nil {
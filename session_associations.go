@@ -0,0 +1,458 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// WithAssociations makes Insert, Update and Delete also save or remove the has_one,
+// has_many and many2many relations of the bean in the same transaction. The has_one
+// and has_many records will be inserted if their primary keys are empty or not exist,
+// otherwise updated; the has_many records which are removed from a non-nil slice will be
+// deleted with their associations, and the join rows of many2many will be replaced. Nil
+// or zero valued relation fields are regarded as not loaded and will be ignored when
+// saving. The associations of the records which are soft deleted, i.e. have a deleted
+// column and are not deleted via Unscoped, are kept. belongs_to relations are never
+// saved or removed.
+func (session *Session) WithAssociations() *Session {
+	session.statement.associations = true
+	return session
+}
+
+// withAssociations runs f in a transaction, if the session is not in a transaction
+// a new one will be begun and committed
+func (session *Session) withAssociations(f func() (int64, error)) (int64, error) {
+	if session.isAutoClose {
+		session.isAutoClose = false
+		defer session.Close()
+	}
+	session.statement.associations = false
+
+	if !session.isAutoCommit {
+		return f()
+	}
+
+	if err := session.Begin(); err != nil {
+		return 0, err
+	}
+	affected, err := f()
+	if err != nil {
+		if rollbackErr := session.Rollback(); rollbackErr != nil {
+			session.engine.logger.Error(rollbackErr)
+		}
+		return affected, err
+	}
+	return affected, session.Commit()
+}
+
+func (session *Session) insertWithAssociations(beans []interface{}) (int64, error) {
+	return session.withAssociations(func() (int64, error) {
+		affected, err := session.Insert(beans...)
+		if err != nil {
+			return affected, err
+		}
+		for _, bean := range beans {
+			if err := session.saveBeansAssociations(bean); err != nil {
+				return affected, err
+			}
+		}
+		return affected, nil
+	})
+}
+
+func (session *Session) updateWithAssociations(bean interface{}, condiBean []interface{}) (int64, error) {
+	var idParam = session.statement.idParam
+	return session.withAssociations(func() (int64, error) {
+		affected, err := session.Update(bean, condiBean...)
+		if err != nil {
+			return affected, err
+		}
+
+		v := reflect.Indirect(reflect.ValueOf(bean))
+		if v.Kind() != reflect.Struct {
+			return affected, nil
+		}
+		pk, err := session.associationsPK(v, idParam)
+		if err != nil {
+			return affected, err
+		}
+		return affected, session.saveAssociations(v, pk)
+	})
+}
+
+func (session *Session) deleteWithAssociations(bean interface{}) (int64, error) {
+	var idParam = session.statement.idParam
+	return session.withAssociations(func() (int64, error) {
+		v := reflect.Indirect(reflect.ValueOf(bean))
+		if v.Kind() != reflect.Struct {
+			return 0, ErrParamsType
+		}
+		table, err := session.engine.autoMapType(v)
+		if err != nil {
+			return 0, err
+		}
+		if session.statement.unscoped || table.DeletedColumn() == nil {
+			pk, err := session.associationsPK(v, idParam)
+			if err != nil {
+				return 0, err
+			}
+			if err := session.deleteAssociations(v.Type(), pk); err != nil {
+				return 0, err
+			}
+		}
+		return session.Delete(bean)
+	})
+}
+
+// associationsPK returns the primary key specified by ID() or the bean's
+func (session *Session) associationsPK(v reflect.Value, idParam *core.PK) (core.PK, error) {
+	if idParam != nil {
+		return *idParam, nil
+	}
+	pk, err := session.engine.idOfV(v)
+	if err != nil {
+		return nil, err
+	}
+	if isPKZero(pk) {
+		return nil, errors.New("associations need the primary key of the bean")
+	}
+	return pk, nil
+}
+
+// saveBeansAssociations saves the associations of a struct or a slice of structs
+func (session *Session) saveBeansAssociations(bean interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(bean))
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			elem := reflect.Indirect(v.Index(i))
+			if !elem.IsValid() || !elem.CanAddr() {
+				continue
+			}
+			if err := session.saveBeansAssociations(elem.Addr().Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	pk, err := session.associationsPK(v, nil)
+	if err != nil {
+		return err
+	}
+	return session.saveAssociations(v, pk)
+}
+
+// saveAssociations saves has_one, has_many and many2many relations of the struct v
+// whose primary key is pk, the related records' associations are saved recursively
+func (session *Session) saveAssociations(v reflect.Value, pk core.PK) error {
+	relations, err := session.engine.relationsOf(v.Type())
+	if err != nil {
+		return err
+	}
+	if len(relations) == 0 {
+		return nil
+	}
+	table, err := session.engine.autoMapType(v)
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range relations {
+		if rel.Type == relationBelongsTo {
+			continue
+		}
+
+		field := v.FieldByName(rel.FieldName)
+		var records []reflect.Value
+		switch field.Kind() {
+		case reflect.Slice:
+			if field.IsNil() {
+				continue
+			}
+			for i := 0; i < field.Len(); i++ {
+				if record := reflect.Indirect(field.Index(i)); record.IsValid() {
+					records = append(records, record)
+				}
+			}
+		case reflect.Ptr:
+			if field.IsNil() {
+				continue
+			}
+			records = append(records, field.Elem())
+		default:
+			// a zero valued struct is regarded as not loaded
+			if reflect.DeepEqual(field.Interface(), reflect.Zero(field.Type()).Interface()) {
+				continue
+			}
+			records = append(records, field)
+		}
+
+		relatedTable, err := session.engine.autoMapType(reflect.New(rel.ElemType).Elem())
+		if err != nil {
+			return err
+		}
+
+		if rel.Type == relationManyToMany {
+			if err := session.saveManyToMany(rel, table, relatedTable, pk, records); err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, relatedCols, err := rel.joinColumns(session.engine, table, relatedTable)
+		if err != nil {
+			return err
+		}
+		var saved = make(map[string]bool, len(records))
+		for _, record := range records {
+			for i, colName := range relatedCols {
+				if err := setColumnValue(relatedTable, record, colName, pk[i]); err != nil {
+					return err
+				}
+			}
+			recordPK, err := session.saveRecord(record)
+			if err != nil {
+				return err
+			}
+			if err := session.saveAssociations(record, recordPK); err != nil {
+				return err
+			}
+			saved[fmt.Sprint(recordPK)] = true
+		}
+
+		if rel.Type == relationHasMany {
+			if err := session.deleteOrphans(rel.ElemType, relatedTable, relatedCols, pk, saved); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteOrphans deletes the has_many records of the owner whose primary key is pk but
+// which are not in the saved ones, i.e. have been removed from the slice, together with
+// their associations unless they are soft deleted
+func (session *Session) deleteOrphans(elemType reflect.Type, relatedTable *core.Table, relatedCols []string, pk core.PK, saved map[string]bool) error {
+	records, err := session.findRelated(elemType, relatedCols, [][]interface{}{pk})
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		recordPK, err := session.engine.idOfV(record.Elem())
+		if err != nil {
+			return err
+		}
+		if saved[fmt.Sprint(recordPK)] {
+			continue
+		}
+		if relatedTable.DeletedColumn() == nil {
+			if err := session.deleteAssociations(elemType, recordPK); err != nil {
+				return err
+			}
+		}
+		if _, err := session.hookSession().ID(recordPK).Delete(reflect.New(elemType).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveManyToMany inserts the related records which not exist and replaces the join rows
+func (session *Session) saveManyToMany(rel *relation, owner, related *core.Table, pk core.PK, records []reflect.Value) error {
+	joinTableName, joinOwnerCols, joinRelatedCols, err := rel.joinTable(session.engine, owner, related)
+	if err != nil {
+		return err
+	}
+
+	if err := session.deleteJoinRows(joinTableName, joinOwnerCols, pk); err != nil {
+		return err
+	}
+
+	var cols = make([]string, 0, len(joinOwnerCols)+len(joinRelatedCols))
+	cols = append(cols, joinOwnerCols...)
+	cols = append(cols, joinRelatedCols...)
+	insertSQL := fmt.Sprintf("INSERT INTO %v (%v) VALUES %v",
+		session.engine.Quote(joinTableName),
+		quoteJoin(cols, session.engine.Quote, ", "),
+		valuesPlaceholders(len(cols), 1))
+
+	for _, record := range records {
+		recordPK, err := session.engine.idOfV(record)
+		if err != nil {
+			return err
+		}
+		if isPKZero(recordPK) {
+			if recordPK, err = session.saveRecord(record); err != nil {
+				return err
+			}
+		}
+
+		args := append([]interface{}{insertSQL}, pk...)
+		args = append(args, recordPK...)
+		if _, err := session.hookSession().Exec(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteJoinRows deletes the join rows of many2many which reference the owner's primary key
+func (session *Session) deleteJoinRows(joinTableName string, joinOwnerCols []string, pk core.PK) error {
+	condSQL, condArgs, err := builder.ToSQL(session.relationCond(joinOwnerCols, [][]interface{}{pk}))
+	if err != nil {
+		return err
+	}
+	deleteSQL := fmt.Sprintf("DELETE FROM %v WHERE %v", session.engine.Quote(joinTableName), condSQL)
+	_, err = session.hookSession().Exec(append([]interface{}{deleteSQL}, condArgs...)...)
+	return err
+}
+
+// saveRecord inserts the record if its primary key is empty or not exist, otherwise
+// updates it, and returns its primary key.
+func (session *Session) saveRecord(record reflect.Value) (core.PK, error) {
+	bean := record.Addr().Interface()
+	pk, err := session.engine.idOfV(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isPKZero(pk) {
+		has, err := session.hookSession().ID(pk).Exist(reflect.New(record.Type()).Interface())
+		if err != nil {
+			return nil, err
+		}
+		if has {
+			if _, err := session.hookSession().ID(pk).Update(bean); err != nil {
+				return nil, err
+			}
+			return pk, nil
+		}
+	}
+
+	if _, err := session.hookSession().Insert(bean); err != nil {
+		return nil, err
+	}
+	return session.engine.idOfV(record)
+}
+
+// deleteAssociations deletes has_one and has_many related records and many2many join
+// rows of the record of type t whose primary key is pk, recursively. The related
+// records with a deleted column are soft deleted and their associations are kept.
+func (session *Session) deleteAssociations(t reflect.Type, pk core.PK) error {
+	relations, err := session.engine.relationsOf(t)
+	if err != nil {
+		return err
+	}
+	if len(relations) == 0 {
+		return nil
+	}
+	table, err := session.engine.autoMapType(reflect.New(t).Elem())
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range relations {
+		if rel.Type == relationBelongsTo {
+			continue
+		}
+		relatedTable, err := session.engine.autoMapType(reflect.New(rel.ElemType).Elem())
+		if err != nil {
+			return err
+		}
+
+		if rel.Type == relationManyToMany {
+			joinTableName, joinOwnerCols, _, err := rel.joinTable(session.engine, table, relatedTable)
+			if err != nil {
+				return err
+			}
+			if err := session.deleteJoinRows(joinTableName, joinOwnerCols, pk); err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, relatedCols, err := rel.joinColumns(session.engine, table, relatedTable)
+		if err != nil {
+			return err
+		}
+
+		// the related records' associations should be deleted at first
+		nestedRelations, err := session.engine.relationsOf(rel.ElemType)
+		if err != nil {
+			return err
+		}
+		if len(nestedRelations) > 0 && relatedTable.DeletedColumn() == nil {
+			records, err := session.findRelated(rel.ElemType, relatedCols, [][]interface{}{pk})
+			if err != nil {
+				return err
+			}
+			for _, record := range records {
+				recordPK, err := session.engine.idOfV(record.Elem())
+				if err != nil {
+					return err
+				}
+				if err := session.deleteAssociations(rel.ElemType, recordPK); err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := session.hookSession().Where(session.relationCond(relatedCols, [][]interface{}{pk})).
+			Delete(reflect.New(rel.ElemType).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setColumnValue sets the value to the field of the column
+func setColumnValue(table *core.Table, structValue reflect.Value, colName string, value interface{}) error {
+	col := table.GetColumn(colName)
+	if col == nil {
+		return fmt.Errorf("column %s not found in table %s", colName, table.Name)
+	}
+	fieldValue, err := col.ValueOfV(&structValue)
+	if err != nil {
+		return err
+	}
+
+	var fieldType = fieldValue.Type()
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	v := reflect.ValueOf(value)
+	if fieldType.Kind() == reflect.String {
+		// Convert turns an integer into the rune it represents
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v = reflect.ValueOf(strconv.FormatInt(v.Int(), 10))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v = reflect.ValueOf(strconv.FormatUint(v.Uint(), 10))
+		}
+	}
+	if !v.Type().ConvertibleTo(fieldType) {
+		return fmt.Errorf("cannot set %v to field %s", value, col.FieldName)
+	}
+	v = v.Convert(fieldType)
+
+	if fieldValue.Kind() == reflect.Ptr {
+		ptr := reflect.New(fieldType)
+		ptr.Elem().Set(v)
+		fieldValue.Set(ptr)
+	} else {
+		fieldValue.Set(v)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type AssocUser struct {
+	Id      int64
+	Name    string
+	Profile *AssocProfile `xorm:"has_one"`
+	Orders  []AssocOrder  `xorm:"has_many"`
+	Tags    []AssocTag    `xorm:"many2many(assoc_user_tag)"`
+}
+
+type AssocProfile struct {
+	Id          int64
+	AssocUserId int64
+	Bio         string
+}
+
+type AssocOrder struct {
+	Id          int64
+	AssocUserId int64
+	Name        string
+	Items       []*AssocOrderItem `xorm:"has_many"`
+}
+
+type AssocOrderItem struct {
+	Id           int64
+	AssocOrderId int64
+	Name         string
+}
+
+type AssocTag struct {
+	Id   int64
+	Name string
+}
+
+type AssocUserTag struct {
+	AssocUserId int64 `xorm:"pk"`
+	AssocTagId  int64 `xorm:"pk"`
+}
+
+func TestWithAssociations(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(AssocUser), new(AssocProfile), new(AssocOrder),
+		new(AssocOrderItem), new(AssocTag), new(AssocUserTag))
+
+	var tag = AssocTag{Name: "existing"}
+	_, err := testEngine.Insert(&tag)
+	assert.NoError(t, err)
+
+	var user = AssocUser{
+		Name:    "a",
+		Profile: &AssocProfile{Bio: "bio"},
+		Orders: []AssocOrder{
+			{Name: "o1", Items: []*AssocOrderItem{{Name: "i1"}, {Name: "i2"}}},
+			{Name: "o2"},
+		},
+		Tags: []AssocTag{tag, {Name: "new"}},
+	}
+	cnt, err := testEngine.WithAssociations().Insert(&user)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.True(t, user.Profile.Id > 0)
+	assert.EqualValues(t, user.Id, user.Profile.AssocUserId)
+	assert.EqualValues(t, user.Id, user.Orders[1].AssocUserId)
+	assert.EqualValues(t, user.Orders[0].Id, user.Orders[0].Items[1].AssocOrderId)
+
+	var loaded AssocUser
+	has, err := testEngine.ID(user.Id).Preload("Profile", "Orders.Items", "Tags").Get(&loaded)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "bio", loaded.Profile.Bio)
+	assert.EqualValues(t, 2, len(loaded.Orders))
+	assert.EqualValues(t, 2, len(loaded.Orders[0].Items)+len(loaded.Orders[1].Items))
+	assert.EqualValues(t, 2, len(loaded.Tags))
+
+	cnt, err = testEngine.Count(new(AssocTag))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	// update the existing records, add a new order and replace the tags, nil fields are ignored
+	loaded.Profile.Bio = "new bio"
+	loaded.Orders = append(loaded.Orders, AssocOrder{Name: "o3"})
+	loaded.Tags = loaded.Tags[:1]
+	_, err = testEngine.ID(user.Id).WithAssociations().Update(&AssocUser{
+		Name:    "b",
+		Profile: loaded.Profile,
+		Orders:  loaded.Orders,
+		Tags:    loaded.Tags,
+	})
+	assert.NoError(t, err)
+
+	var updated AssocUser
+	has, err = testEngine.ID(user.Id).Preload("Profile", "Orders", "Tags").Get(&updated)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "b", updated.Name)
+	assert.EqualValues(t, "new bio", updated.Profile.Bio)
+	assert.EqualValues(t, 3, len(updated.Orders))
+	assert.EqualValues(t, 1, len(updated.Tags))
+
+	// the orders removed from the slice are deleted with their items
+	var orders []AssocOrder
+	for _, order := range updated.Orders {
+		if order.Name != "o1" {
+			orders = append(orders, order)
+		}
+	}
+	_, err = testEngine.ID(user.Id).WithAssociations().Update(&AssocUser{Name: "b", Orders: orders})
+	assert.NoError(t, err)
+
+	cnt, err = testEngine.Count(new(AssocOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	cnt, err = testEngine.Count(new(AssocOrderItem))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+	cnt, err = testEngine.Count(new(AssocUserTag))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// delete all the associations
+	cnt, err = testEngine.WithAssociations().Delete(&AssocUser{Id: user.Id})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	for _, bean := range []interface{}{new(AssocUser), new(AssocProfile), new(AssocOrder), new(AssocOrderItem), new(AssocUserTag)} {
+		cnt, err = testEngine.Count(bean)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, cnt)
+	}
+	cnt, err = testEngine.Count(new(AssocTag))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	// without associations
+	_, err = testEngine.Insert(&AssocUser{Name: "c", Profile: &AssocProfile{Bio: "bio"}})
+	assert.NoError(t, err)
+	cnt, err = testEngine.Count(new(AssocProfile))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+}
+
+type AssocSoftUser struct {
+	Id      int64
+	Name    string
+	Deleted time.Time         `xorm:"deleted"`
+	Profile AssocSoftProfile  `xorm:"has_one"`
+	Orders  []*AssocSoftOrder `xorm:"has_many"`
+}
+
+type AssocSoftProfile struct {
+	Id              int64
+	AssocSoftUserId int64
+	Bio             string
+}
+
+type AssocSoftOrder struct {
+	Id              int64
+	AssocSoftUserId int64
+	Name            string
+}
+
+func TestWithAssociationsSoftDelete(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(AssocSoftUser), new(AssocSoftProfile), new(AssocSoftOrder))
+
+	// the zero valued has_one is not loaded
+	var user = AssocSoftUser{
+		Name:   "a",
+		Orders: []*AssocSoftOrder{{Name: "o1"}, {Name: "o2"}},
+	}
+	_, err := testEngine.WithAssociations().Insert(&user)
+	assert.NoError(t, err)
+
+	cnt, err := testEngine.Count(new(AssocSoftProfile))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+	cnt, err = testEngine.Count(new(AssocSoftOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	// the associations are kept when soft deleted
+	cnt, err = testEngine.WithAssociations().Delete(&AssocSoftUser{Id: user.Id})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	cnt, err = testEngine.Count(new(AssocSoftUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+	cnt, err = testEngine.Count(new(AssocSoftOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	cnt, err = testEngine.Unscoped().WithAssociations().Delete(&AssocSoftUser{Id: user.Id})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	cnt, err = testEngine.Count(new(AssocSoftOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+}
+
+func TestSetColumnValue(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type AssocStringOwner struct {
+		Id      int64
+		OwnerId string
+		Ref     *int32
+	}
+
+	var bean AssocStringOwner
+	v := reflect.ValueOf(&bean).Elem()
+	table, err := testEngine.(*Engine).autoMapType(v)
+	assert.NoError(t, err)
+
+	assert.NoError(t, setColumnValue(table, v, "owner_id", int64(65)))
+	assert.EqualValues(t, "65", bean.OwnerId)
+	assert.NoError(t, setColumnValue(table, v, "owner_id", uint8(7)))
+	assert.EqualValues(t, "7", bean.OwnerId)
+	assert.NoError(t, setColumnValue(table, v, "ref", int64(3)))
+	assert.EqualValues(t, 3, *bean.Ref)
+	assert.Error(t, setColumnValue(table, v, "ref", "3"))
+	assert.Error(t, setColumnValue(table, v, "missing", int64(1)))
+}
@@ -5,6 +5,7 @@
 package xorm
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"strconv"
@@ -75,6 +76,10 @@ func (session *Session) cacheDelete(table *core.Table, tableName, sqlStr string,
 
 // Delete records, bean's non-empty fields are conditions
 func (session *Session) Delete(bean interface{}) (int64, error) {
+	if session.statement.associations {
+		return session.deleteWithAssociations(bean)
+	}
+
 	if session.isAutoClose {
 		defer session.Close()
 	}
@@ -86,6 +91,7 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 	if err := session.statement.setRefBean(bean); err != nil {
 		return 0, err
 	}
+	session.applyDefaultScopes()
 
 	// handle before delete processors
 	for _, closure := range session.beforeClosures {
@@ -96,6 +102,9 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 	if processor, ok := interface{}(bean).(BeforeDeleteProcessor); ok {
 		processor.BeforeDelete()
 	}
+	if err := session.beforeDeleteHook(bean); err != nil {
+		return 0, err
+	}
 
 	condSQL, condArgs, err := session.statement.genConds(bean)
 	if err != nil {
@@ -203,13 +212,60 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 		})
 	}
 
+	var isReturning = session.statement.returning
+	if isReturning {
+		var isSoftDelete = !session.statement.unscoped && table.DeletedColumn() != nil
+		var prefix = "DELETED"
+		if isSoftDelete {
+			prefix = "INSERTED"
+		}
+		output, returning, err := session.statement.genReturning(prefix)
+		if err != nil {
+			return 0, err
+		}
+
+		// OUTPUT should be placed before WHERE, and MSSQL has returned for ORDER BY or LIMIT
+		if len(output) > 0 {
+			if isSoftDelete {
+				realSQL = fmt.Sprintf("UPDATE %v SET %v = ?%v WHERE %v",
+					tableName,
+					session.engine.Quote(table.DeletedColumn().Name),
+					output,
+					condSQL)
+			} else if len(condSQL) > 0 {
+				realSQL = fmt.Sprintf("DELETE FROM %v%v WHERE %v", tableName, output, condSQL)
+			} else {
+				realSQL = fmt.Sprintf("DELETE FROM %v%v", tableName, output)
+			}
+		}
+		realSQL += returning
+	}
+
 	if cacher := session.engine.getCacher(tableNameNoQuote); cacher != nil && session.statement.UseCache {
 		session.cacheDelete(table, tableNameNoQuote, deleteSQL, argsForCache...)
 	}
 
 	session.statement.RefTable = table
-	res, err := session.exec(realSQL, condArgs...)
-	if err != nil {
+	var res sql.Result
+	var affected int64
+	if isReturning {
+		fields, results, err := session.queryReturning(realSQL, condArgs...)
+		if err != nil {
+			return 0, err
+		}
+		if err := session.fillReturning(table, fields, results, bean); err != nil {
+			return 0, err
+		}
+		affected = int64(len(results))
+	} else {
+		res, err = session.exec(realSQL, condArgs...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := session.afterDeleteHook(bean); err != nil {
+		cleanupProcessorsClosures(&session.afterClosures)
 		return 0, err
 	}
 
@@ -240,5 +296,8 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 	cleanupProcessorsClosures(&session.afterClosures)
 	// --
 
+	if isReturning {
+		return affected, nil
+	}
 	return res.RowsAffected()
 }
@@ -26,7 +26,27 @@ func (session *Session) Find(rowsSlicePtr interface{}, condiBean ...interface{})
 	if session.isAutoClose {
 		defer session.Close()
 	}
-	return session.find(rowsSlicePtr, condiBean...)
+
+	preloads := session.statement.preloads
+	page, start := session.statement.page, sliceLen(rowsSlicePtr)
+	if err := session.find(rowsSlicePtr, condiBean...); err != nil {
+		return err
+	}
+	if page != nil {
+		if err := page.setSliceCursors(rowsSlicePtr, start); err != nil {
+			return err
+		}
+	}
+	return session.preload(rowsSlicePtr, preloads)
+}
+
+// sliceLen returns the length of the slice which rowsSlicePtr points to, or 0
+func sliceLen(rowsSlicePtr interface{}) int {
+	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
+	if sliceValue.Kind() != reflect.Slice {
+		return 0
+	}
+	return sliceValue.Len()
 }
 
 // FindAndCount find the results and also return the counts
@@ -36,10 +56,19 @@ func (session *Session) FindAndCount(rowsSlicePtr interface{}, condiBean ...inte
 	}
 
 	session.autoResetStatement = false
+	page, start := session.statement.page, sliceLen(rowsSlicePtr)
 	err := session.find(rowsSlicePtr, condiBean...)
 	if err != nil {
 		return 0, err
 	}
+	if page != nil {
+		if err := page.setSliceCursors(rowsSlicePtr, start); err != nil {
+			return 0, err
+		}
+	}
+	if err := session.preload(rowsSlicePtr, session.statement.preloads); err != nil {
+		return 0, err
+	}
 
 	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
 	if sliceValue.Kind() != reflect.Slice && sliceValue.Kind() != reflect.Map {
@@ -97,8 +126,20 @@ func (session *Session) find(rowsSlicePtr interface{}, condiBean ...interface{})
 		}
 	}
 
+	session.applyDefaultScopes()
 	var table = session.statement.RefTable
 
+	var pageCond builder.Cond
+	if page := session.statement.page; page != nil {
+		if tp != tpStruct || sliceValue.Kind() != reflect.Slice {
+			return errors.New("pagination needs a slice of structs")
+		}
+		var err error
+		if pageCond, err = page.prepare(session, table); err != nil {
+			return err
+		}
+	}
+
 	var addedTableName = (len(session.statement.JoinStr) > 0)
 	var autoCond builder.Cond
 	if tp == tpStruct {
@@ -161,7 +202,11 @@ func (session *Session) find(rowsSlicePtr interface{}, condiBean ...interface{})
 		}
 
 		session.statement.cond = session.statement.cond.And(autoCond)
-		condSQL, condArgs, err := builder.ToSQL(session.statement.cond)
+		var cond = session.statement.cond
+		if pageCond != nil {
+			cond = cond.And(pageCond)
+		}
+		condSQL, condArgs, err := builder.ToSQL(cond)
 		if err != nil {
 			return err
 		}
@@ -20,7 +20,13 @@ func (session *Session) Get(bean interface{}) (bool, error) {
 	if session.isAutoClose {
 		defer session.Close()
 	}
-	return session.get(bean)
+
+	preloads := session.statement.preloads
+	has, err := session.get(bean)
+	if err != nil || !has {
+		return has, err
+	}
+	return has, session.preload(bean, preloads)
 }
 
 func (session *Session) get(bean interface{}) (bool, error) {
@@ -41,7 +47,11 @@ func (session *Session) get(bean interface{}) (bool, error) {
 		if err := session.statement.setRefBean(bean); err != nil {
 			return false, err
 		}
+		if err := session.beforeGetHook(bean); err != nil {
+			return false, err
+		}
 	}
+	session.applyDefaultScopes()
 
 	var sqlStr string
 	var args []interface{}
@@ -68,7 +78,10 @@ func (session *Session) get(bean interface{}) (bool, error) {
 			!session.statement.unscoped {
 			has, err := session.cacheGet(bean, sqlStr, args...)
 			if err != ErrCacheFailed {
-				return has, err
+				if err != nil || !has {
+					return has, err
+				}
+				return true, session.afterGetHook(bean)
 			}
 		}
 	}
@@ -83,7 +96,7 @@ func (session *Session) get(bean interface{}) (bool, error) {
 			structValue.Set(reflect.Indirect(reflect.ValueOf(res)))
 			session.lastSQL = ""
 			session.lastSQLArgs = nil
-			return true, nil
+			return true, session.afterGetHook(bean)
 		}
 	}
 
@@ -96,7 +109,7 @@ func (session *Session) get(bean interface{}) (bool, error) {
 		context.Put(fmt.Sprintf("%v-%v", sqlStr, args), bean)
 	}
 
-	return true, nil
+	return true, session.afterGetHook(bean)
 }
 
 func (session *Session) nocacheGet(beanKind reflect.Kind, table *core.Table, bean interface{}, sqlStr string, args ...interface{}) (bool, error) {
@@ -18,6 +18,10 @@ import (
 
 // Insert insert one or more beans
 func (session *Session) Insert(beans ...interface{}) (int64, error) {
+	if session.statement.associations {
+		return session.insertWithAssociations(beans)
+	}
+
 	var affected int64
 	var err error
 
@@ -97,6 +101,37 @@ func (session *Session) Insert(beans ...interface{}) (int64, error) {
 	return affected, err
 }
 
+// insertMultiMaxArgs returns the maximum number of the parameters which one
+// statement could contain
+func (session *Session) insertMultiMaxArgs() int {
+	switch session.engine.dialect.DBType() {
+	case core.MSSQL:
+		return 2100
+	case core.SQLITE:
+		// the default value of SQLITE_MAX_VARIABLE_NUMBER before 3.32.0
+		return 999
+	default:
+		return 65535
+	}
+}
+
+// insertMultiChunkSize returns how many records one insert statement will contain
+func (session *Session) insertMultiChunkSize(table *core.Table) int {
+	if session.insertChunkSize > 0 {
+		return session.insertChunkSize
+	}
+
+	size := session.insertMultiMaxArgs() / len(table.ColumnsSeq())
+	// MSSQL allows no more than 1000 rows in a VALUES clause
+	if session.engine.dialect.DBType() == core.MSSQL && size > 1000 {
+		size = 1000
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
 func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error) {
 	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
 	if sliceValue.Kind() != reflect.Slice {
@@ -111,6 +146,72 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 		return 0, err
 	}
 
+	// the step is read once before inserting, and the ids are not filled back
+	// if it could not be read, e.g. on TiDB
+	var autoIncrStep int64
+	if session.engine.dialect.DBType() == core.MYSQL && len(session.statement.RefTable.AutoIncrement) > 0 {
+		step, err := session.mysqlAutoIncrStep()
+		if err != nil {
+			session.engine.logger.Warnf("read auto increment step failed, the ids will not be filled back: %v", err)
+		} else {
+			autoIncrStep = step
+		}
+	}
+
+	size := sliceValue.Len()
+	chunkSize := session.insertMultiChunkSize(session.statement.RefTable)
+	if size <= chunkSize {
+		return session.insertMultiChunk(sliceValue, autoIncrStep)
+	}
+
+	// all the chunks are inserted in one transaction
+	var isOwnTx = session.isAutoCommit
+	if isOwnTx {
+		if err := session.Begin(); err != nil {
+			return 0, err
+		}
+	}
+
+	var beforeClosures = session.beforeClosures
+	var afterClosures = session.afterClosures
+	var affected int64
+	for start := 0; start < size; start += chunkSize {
+		end := start + chunkSize
+		if end > size {
+			end = size
+		}
+
+		// every chunk should be handled by the closures
+		session.beforeClosures = append(make([]func(interface{}), 0, len(beforeClosures)), beforeClosures...)
+		session.afterClosures = append(make([]func(interface{}), 0, len(afterClosures)), afterClosures...)
+
+		cnt, err := session.insertMultiChunk(sliceValue.Slice(start, end), autoIncrStep)
+		if err != nil {
+			if isOwnTx {
+				if rollbackErr := session.Rollback(); rollbackErr != nil {
+					session.engine.logger.Error(rollbackErr)
+				}
+			}
+			return affected, err
+		}
+		affected += cnt
+	}
+
+	if isOwnTx {
+		if err := session.Commit(); err != nil {
+			return affected, err
+		}
+	}
+	return affected, nil
+}
+
+// insertMultiChunk inserts all the records of sliceValue in one statement,
+// autoIncrStep is the step of the ids generated on MySQL, 0 means not to fill them back
+func (session *Session) insertMultiChunk(sliceValue reflect.Value, autoIncrStep int64) (int64, error) {
+	if err := session.statement.setRefBean(sliceValue.Index(0).Interface()); err != nil {
+		return 0, err
+	}
+
 	tableName := session.statement.TableName()
 	if len(tableName) <= 0 {
 		return 0, ErrTableNotFound
@@ -139,6 +240,9 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 		if processor, ok := interface{}(elemValue).(BeforeInsertProcessor); ok {
 			processor.BeforeInsert()
 		}
+		if err := session.beforeInsertHook(reflect.Indirect(v).Addr().Interface()); err != nil {
+			return 0, err
+		}
 		// --
 
 		if i == 0 {
@@ -260,9 +364,93 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 			quoteColumns(colNames, session.engine.Quote, ","),
 			strings.Join(colMultiPlaces, "),("))
 	}
-	res, err := session.exec(sql, args...)
-	if err != nil {
-		return 0, err
+	// the autoincrement ids could be filled back only if all of them are generated by database
+	var fillAutoIncr = len(table.AutoIncrement) > 0 && !stringsContains(colNames, table.AutoIncrement)
+	var ids []int64
+	var affected int64
+	if session.statement.returning {
+		// the order of the records returned by OUTPUT is not guaranteed on MSSQL
+		if session.engine.dialect.DBType() == core.MSSQL {
+			return 0, ErrNotImplemented
+		}
+		_, returning, err := session.statement.genReturning("INSERTED")
+		if err != nil {
+			return 0, err
+		}
+
+		fields, results, err := session.queryReturning(sql+returning, args...)
+		if err != nil {
+			return 0, err
+		}
+		var beans = make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			beans = append(beans, reflect.Indirect(sliceValue.Index(i)).Addr().Interface())
+		}
+		if err := session.fillReturning(table, fields, results, beans...); err != nil {
+			return 0, err
+		}
+		affected = int64(len(results))
+	} else if fillAutoIncr && session.engine.dialect.DBType() == core.POSTGRES {
+		sql += " RETURNING " + session.engine.Quote(table.AutoIncrement)
+		rows, err := session.queryRows(sql, args...)
+		if err != nil {
+			return 0, err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		affected = int64(len(ids))
+	} else {
+		res, err := session.exec(sql, args...)
+		if err != nil {
+			return 0, err
+		}
+		affected, err = res.RowsAffected()
+		if err != nil {
+			return affected, err
+		}
+
+		if fillAutoIncr {
+			switch session.engine.dialect.DBType() {
+			case core.MYSQL:
+				// LastInsertId returns the first one
+				if id, err := res.LastInsertId(); err == nil && id > 0 && autoIncrStep > 0 {
+					for i := 0; i < size; i++ {
+						ids = append(ids, id+int64(i)*autoIncrStep)
+					}
+				}
+			case core.SQLITE:
+				// LastInsertId returns the rowid of the last record
+				if id, err := res.LastInsertId(); err == nil && id > 0 {
+					for i := 0; i < size; i++ {
+						ids = append(ids, id-int64(size-1-i))
+					}
+				}
+			}
+		}
+	}
+
+	if len(ids) == size {
+		for i := 0; i < size; i++ {
+			elemValue := reflect.Indirect(sliceValue.Index(i))
+			aiValue, err := table.AutoIncrColumn().ValueOfV(&elemValue)
+			if err != nil {
+				session.engine.logger.Error(err)
+				continue
+			}
+			if aiValue.IsValid() && aiValue.CanSet() {
+				aiValue.Set(int64ToIntValue(ids[i], aiValue.Type()))
+			}
+		}
 	}
 
 	session.cacheInsert(tableName)
@@ -298,10 +486,36 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 	}
 
 	cleanupProcessorsClosures(&session.afterClosures)
-	return res.RowsAffected()
+
+	for i := 0; i < size; i++ {
+		elemValue := reflect.Indirect(sliceValue.Index(i)).Addr().Interface()
+		if err := session.afterInsertHook(elemValue); err != nil {
+			return affected, err
+		}
+	}
+	return affected, nil
 }
 
-// InsertMulti insert multiple records
+// mysqlAutoIncrStep returns auto_increment_increment which is the step of the ids
+// generated by one statement, or 0 if the ids may be not consecutive since
+// innodb_autoinc_lock_mode is neither 0 nor 1
+func (session *Session) mysqlAutoIncrStep() (int64, error) {
+	var lockMode, step int64
+	err := session.queryRow("SELECT @@innodb_autoinc_lock_mode, @@auto_increment_increment").Scan(&lockMode, &step)
+	if err != nil {
+		return 0, err
+	}
+	if lockMode != 0 && lockMode != 1 {
+		return 0, nil
+	}
+	return step, nil
+}
+
+// InsertMulti insert multiple records. The records will be split into chunks
+// according to the parameters limitation of the database, which could be changed
+// via InsertChunkSize, and all the chunks are inserted in one transaction. The
+// autoincrement fields will be filled back on Postgres and SQLite, and on MySQL if
+// innodb_autoinc_lock_mode is 0 or 1 and could be read before inserting.
 func (session *Session) InsertMulti(rowsSlicePtr interface{}) (int64, error) {
 	if session.isAutoClose {
 		defer session.Close()
@@ -317,10 +531,24 @@ func (session *Session) InsertMulti(rowsSlicePtr interface{}) (int64, error) {
 		return 0, nil
 	}
 
+	session.autoResetStatement = false
+	defer func() {
+		session.autoResetStatement = true
+		session.resetStatement()
+	}()
+
 	return session.innerInsertMulti(rowsSlicePtr)
 }
 
 func (session *Session) innerInsert(bean interface{}) (int64, error) {
+	affected, err := session.insertStruct(bean)
+	if err != nil {
+		return affected, err
+	}
+	return affected, session.afterInsertHook(bean)
+}
+
+func (session *Session) insertStruct(bean interface{}) (int64, error) {
 	if err := session.statement.setRefBean(bean); err != nil {
 		return 0, err
 	}
@@ -339,6 +567,9 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 	if processor, ok := interface{}(bean).(BeforeInsertProcessor); ok {
 		processor.BeforeInsert()
 	}
+	if err := session.beforeInsertHook(bean); err != nil {
+		return 0, err
+	}
 
 	colNames, args, err := session.genInsertColumns(bean)
 	if err != nil {
@@ -352,8 +583,13 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 	}
 
 	var tableName = session.statement.TableName()
-	var output string
-	if session.engine.dialect.DBType() == core.MSSQL && len(table.AutoIncrement) > 0 {
+	var output, returning string
+	if session.statement.returning {
+		output, returning, err = session.statement.genReturning("INSERTED")
+		if err != nil {
+			return 0, err
+		}
+	} else if session.engine.dialect.DBType() == core.MSSQL && len(table.AutoIncrement) > 0 {
 		output = fmt.Sprintf(" OUTPUT Inserted.%s", table.AutoIncrement)
 	}
 
@@ -425,7 +661,11 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 		}
 	}
 
-	if len(table.AutoIncrement) > 0 && session.engine.dialect.DBType() == core.POSTGRES {
+	if session.statement.returning {
+		if _, err := buf.WriteString(returning); err != nil {
+			return 0, err
+		}
+	} else if len(table.AutoIncrement) > 0 && session.engine.dialect.DBType() == core.POSTGRES {
 		if _, err := buf.WriteString(" RETURNING " + session.engine.Quote(table.AutoIncrement)); err != nil {
 			return 0, err
 		}
@@ -462,6 +702,35 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 		cleanupProcessorsClosures(&session.afterClosures) // cleanup after used
 	}
 
+	if session.statement.returning {
+		// the statement may be reset after the query
+		var incrVersion = table.Version != "" && session.statement.checkVersion &&
+			!session.statement.returningContains(table.Version)
+
+		fields, results, err := session.queryReturning(sqlStr, args...)
+		if err != nil {
+			return 0, err
+		}
+
+		defer handleAfterInsertProcessorFunc(bean)
+
+		session.cacheInsert(tableName)
+
+		if incrVersion {
+			verValue, err := table.VersionColumn().ValueOf(bean)
+			if err != nil {
+				session.engine.logger.Error(err)
+			} else if verValue.IsValid() && verValue.CanSet() {
+				session.incrVersionFieldValue(verValue)
+			}
+		}
+
+		if err := session.fillReturning(table, fields, results, bean); err != nil {
+			return 0, err
+		}
+		return int64(len(results)), nil
+	}
+
 	// for postgres, many of them didn't implement lastInsertId, so we should
 	// implemented it ourself.
 	if session.engine.dialect.DBType() == core.ORACLE && len(table.AutoIncrement) > 0 {
@@ -1024,3 +1024,48 @@ func TestInsertMultiWithOmit(t *testing.T) {
 	assert.EqualValues(t, 3, num)
 	check()
 }
+
+func TestInsertMultiChunks(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type InsertMultiChunks struct {
+		Id   int64
+		Name string `xorm:"unique"`
+	}
+
+	assertSync(t, new(InsertMultiChunks))
+
+	var records = make([]InsertMultiChunks, 0, 2500)
+	for i := 0; i < 2500; i++ {
+		records = append(records, InsertMultiChunks{Name: fmt.Sprintf("name%d", i)})
+	}
+
+	cnt, err := testEngine.Insert(&records)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2500, cnt)
+
+	var res []InsertMultiChunks
+	assert.NoError(t, testEngine.Asc("id").Find(&res))
+	assert.EqualValues(t, 2500, len(res))
+	if dbType == "mysql" || dbType == "postgres" || dbType == "sqlite3" {
+		for i := range records {
+			assert.EqualValues(t, res[i].Id, records[i].Id)
+			assert.EqualValues(t, res[i].Name, records[i].Name)
+		}
+	}
+
+	// the failure of the last chunk should rollback all the chunks
+	records = []InsertMultiChunks{
+		{Name: "new1"},
+		{Name: "new2"},
+		{Name: "name0"},
+	}
+	session := testEngine.NewSession().InsertChunkSize(2)
+	defer session.Close()
+	_, err = session.InsertMulti(&records)
+	assert.Error(t, err)
+
+	cnt, err = testEngine.Count(new(InsertMultiChunks))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2500, cnt)
+}
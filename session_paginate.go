@@ -0,0 +1,309 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// Page is used by keyset pagination, the records are sought by the values of the
+// ORDER BY columns and the primary keys of the last or first record of the previous
+// page instead of OFFSET, e.g.
+//
+//	var page = xorm.Page{Size: 20}
+//	err := engine.Paginate(&page).Desc("created").Find(&users)
+//	// load the next page
+//	page.After = page.NextCursor
+//	err = engine.Paginate(&page).Desc("created").Find(&users)
+//
+// The ORDER BY should only contain the columns of the table which are not null.
+type Page struct {
+	// Size is the max records of one page
+	Size int
+	// After is a cursor, the records after it will be returned
+	After string
+	// Before is a cursor, the records before it will be returned, it is ignored if After is set
+	Before string
+
+	// NextCursor and PrevCursor are set after the query, they are empty if there
+	// are no more records on that direction.
+	NextCursor string
+	PrevCursor string
+
+	table    *core.Table
+	orders   []pageOrder
+	backward bool
+	scanned  int
+	first    string
+}
+
+type pageOrder struct {
+	col  *core.Column
+	expr string // the column used in SQL, may be quoted or with table name
+	desc bool
+}
+
+// Paginate enables keyset pagination for Find, FindAndCount and Rows, the cursors of
+// the next and previous pages will be set to page after the query. The primary keys are
+// appended to the ORDER BY to make the order unique. Records are always returned in the
+// order of ORDER BY except Rows with page.Before, which iterates in the reverse order.
+func (session *Session) Paginate(page *Page) *Session {
+	session.statement.page = page
+	return session
+}
+
+// parsePageOrders parses the ORDER BY of the statement and appends the primary keys
+func parsePageOrders(orderStr string, table *core.Table) ([]pageOrder, error) {
+	var orders []pageOrder
+	var hasCols = make(map[string]bool)
+	for _, item := range strings.Split(orderStr, ",") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("pagination does not support order by %s", item)
+		}
+
+		var desc bool
+		if len(fields) == 2 {
+			switch strings.ToUpper(fields[1]) {
+			case "DESC":
+				desc = true
+			case "ASC":
+			default:
+				return nil, fmt.Errorf("pagination does not support order by %s", item)
+			}
+		}
+
+		parts := strings.Split(fields[0], ".")
+		colName := strings.Trim(parts[len(parts)-1], "`\"[]")
+		col := table.GetColumn(colName)
+		if col == nil {
+			return nil, fmt.Errorf("pagination needs order by columns of table %s but %s", table.Name, fields[0])
+		}
+		hasCols[strings.ToLower(col.Name)] = true
+		orders = append(orders, pageOrder{col: col, expr: fields[0], desc: desc})
+	}
+
+	for _, col := range table.PKColumns() {
+		if !hasCols[strings.ToLower(col.Name)] {
+			orders = append(orders, pageOrder{col: col, expr: col.Name})
+		}
+	}
+	if len(orders) == 0 {
+		return nil, errors.New("pagination needs order by columns or primary keys")
+	}
+	return orders, nil
+}
+
+// prepare sets the order and limit of the statement and returns the condition to
+// seek the records after or before the cursor
+func (page *Page) prepare(session *Session, table *core.Table) (builder.Cond, error) {
+	if page.Size <= 0 {
+		return nil, errors.New("page size should be larger than 0")
+	}
+	if table == nil {
+		return nil, errors.New("pagination needs a struct")
+	}
+
+	orders, err := parsePageOrders(session.statement.OrderStr, table)
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		if !strings.ContainsAny(orders[i].expr, "`\"[") {
+			orders[i].expr = quoteJoin(strings.Split(orders[i].expr, "."), session.engine.Quote, ".")
+		}
+	}
+
+	page.table = table
+	page.orders = orders
+	page.backward = page.After == "" && page.Before != ""
+	page.scanned = 0
+	page.first = ""
+	page.NextCursor = ""
+	page.PrevCursor = ""
+
+	var orderStrs = make([]string, 0, len(orders))
+	for _, order := range orders {
+		if order.desc != page.backward {
+			orderStrs = append(orderStrs, order.expr+" DESC")
+		} else {
+			orderStrs = append(orderStrs, order.expr+" ASC")
+		}
+	}
+	session.statement.OrderStr = strings.Join(orderStrs, ", ")
+	session.statement.LimitN = page.Size
+	session.statement.Start = 0
+
+	var cursor = page.After
+	if page.backward {
+		cursor = page.Before
+	}
+	if cursor == "" {
+		return nil, nil
+	}
+
+	values, err := page.decodeCursor(session.engine, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// (a > ?) OR (a = ? AND b > ?) OR (a = ? AND b = ? AND id > ?)
+	var cond = builder.NewCond()
+	for i, order := range orders {
+		var seek = builder.NewCond()
+		for j := 0; j < i; j++ {
+			seek = seek.And(builder.Eq{orders[j].expr: values[j]})
+		}
+		if order.desc != page.backward {
+			seek = seek.And(builder.Lt{order.expr: values[i]})
+		} else {
+			seek = seek.And(builder.Gt{order.expr: values[i]})
+		}
+		cond = cond.Or(seek)
+	}
+	return cond, nil
+}
+
+// encodeCursor returns the cursor of the record
+func (page *Page) encodeCursor(structValue reflect.Value) (string, error) {
+	var values = make([]interface{}, 0, len(page.orders))
+	for _, order := range page.orders {
+		fieldValue, err := order.col.ValueOfV(&structValue)
+		if err != nil {
+			return "", err
+		}
+		values = append(values, fieldValue.Interface())
+	}
+	bs, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+// decodeCursor returns the values of the cursor which could be used as arguments
+func (page *Page) decodeCursor(engine *Engine, cursor string) ([]interface{}, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal(bs, &raws); err != nil || len(raws) != len(page.orders) {
+		return nil, ErrInvalidCursor
+	}
+
+	var structValue = reflect.New(page.table.Type).Elem()
+	var values = make([]interface{}, 0, len(raws))
+	for i, order := range page.orders {
+		fieldValue, err := order.col.ValueOfV(&structValue)
+		if err != nil {
+			return nil, err
+		}
+		v := reflect.New(fieldValue.Type())
+		if err := json.Unmarshal(raws[i], v.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		elem := v.Elem()
+		if elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				values = append(values, nil)
+				continue
+			}
+			elem = elem.Elem()
+		}
+		value := elem.Interface()
+		if t, ok := value.(time.Time); ok {
+			value = engine.formatColTime(order.col, t)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// setCursors sets the cursors of the next and previous pages, first and last are
+// the cursors of the first and last records of the current page in the order of
+// ORDER BY and n is the count of the records.
+func (page *Page) setCursors(first, last string, n int) {
+	page.NextCursor, page.PrevCursor = "", ""
+	if n == 0 {
+		return
+	}
+	if page.backward {
+		if n >= page.Size {
+			page.PrevCursor = first
+		}
+		page.NextCursor = last
+		return
+	}
+	if n >= page.Size {
+		page.NextCursor = last
+	}
+	if page.After != "" {
+		page.PrevCursor = first
+	}
+}
+
+// setSliceCursors reverses the records of a backward page which are appended to the
+// slice after start and sets the cursors
+func (page *Page) setSliceCursors(rowsSlicePtr interface{}, start int) error {
+	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
+	if sliceValue.Kind() != reflect.Slice {
+		return errors.New("pagination needs a slice")
+	}
+
+	var n = sliceValue.Len() - start
+	if page.backward {
+		swap := reflect.Swapper(sliceValue.Interface())
+		for i, j := start, sliceValue.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+	if n <= 0 {
+		page.setCursors("", "", 0)
+		return nil
+	}
+
+	first, err := page.encodeCursor(reflect.Indirect(sliceValue.Index(start)))
+	if err != nil {
+		return err
+	}
+	last, err := page.encodeCursor(reflect.Indirect(sliceValue.Index(sliceValue.Len() - 1)))
+	if err != nil {
+		return err
+	}
+	page.setCursors(first, last, n)
+	return nil
+}
+
+// scan updates the cursors with the record scanned by Rows
+func (page *Page) scan(bean interface{}) error {
+	cursor, err := page.encodeCursor(reflect.Indirect(reflect.ValueOf(bean)))
+	if err != nil {
+		return err
+	}
+	page.scanned++
+	if page.scanned == 1 {
+		page.first = cursor
+	}
+	if page.backward {
+		// the records are scanned in the reverse order
+		page.setCursors(cursor, page.first, page.scanned)
+	} else {
+		page.setCursors(page.first, cursor, page.scanned)
+	}
+	return nil
+}
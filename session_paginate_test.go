@@ -0,0 +1,99 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type PaginateStruct struct {
+	Id      int64
+	Name    string
+	Score   int
+	Created time.Time
+}
+
+func TestPaginate(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(PaginateStruct))
+
+	var now = time.Now().Truncate(time.Second)
+	var records []PaginateStruct
+	for i := 0; i < 10; i++ {
+		records = append(records, PaginateStruct{
+			Name:    fmt.Sprintf("n%d", i),
+			Score:   i / 3, // duplicated scores need the primary key to break ties
+			Created: now.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	_, err := testEngine.Insert(&records)
+	assert.NoError(t, err)
+
+	names := func(ss []PaginateStruct) []string {
+		var res []string
+		for _, s := range ss {
+			res = append(res, s.Name)
+		}
+		return res
+	}
+
+	// forward by score desc
+	var page = Page{Size: 4}
+	var ss []PaginateStruct
+	assert.NoError(t, testEngine.Paginate(&page).Desc("score").Find(&ss))
+	assert.EqualValues(t, []string{"n9", "n6", "n7", "n8"}, names(ss))
+	assert.NotEmpty(t, page.NextCursor)
+	assert.Empty(t, page.PrevCursor)
+
+	page.After = page.NextCursor
+	ss = nil
+	assert.NoError(t, testEngine.Paginate(&page).Desc("score").Find(&ss))
+	assert.EqualValues(t, []string{"n3", "n4", "n5", "n0"}, names(ss))
+	assert.NotEmpty(t, page.PrevCursor)
+
+	page.After = page.NextCursor
+	ss = nil
+	cnt, err := testEngine.Paginate(&page).Desc("score").FindAndCount(&ss)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, cnt)
+	assert.EqualValues(t, []string{"n1", "n2"}, names(ss))
+	assert.Empty(t, page.NextCursor)
+
+	// backward
+	page.After, page.Before = "", page.PrevCursor
+	ss = nil
+	assert.NoError(t, testEngine.Paginate(&page).Desc("score").Find(&ss))
+	assert.EqualValues(t, []string{"n3", "n4", "n5", "n0"}, names(ss))
+	assert.NotEmpty(t, page.PrevCursor)
+
+	page.Before = page.PrevCursor
+	ss = nil
+	assert.NoError(t, testEngine.Paginate(&page).Desc("score").Find(&ss))
+	assert.EqualValues(t, []string{"n9", "n6", "n7", "n8"}, names(ss))
+
+	// time column and rows
+	page = Page{Size: 3}
+	assert.NoError(t, testEngine.Paginate(&page).Asc("created").Find(&ss))
+	page.After = page.NextCursor
+
+	rows, err := testEngine.Paginate(&page).Asc("created").Rows(new(PaginateStruct))
+	assert.NoError(t, err)
+	var scanned []PaginateStruct
+	for rows.Next() {
+		var s PaginateStruct
+		assert.NoError(t, rows.Scan(&s))
+		scanned = append(scanned, s)
+	}
+	rows.Close()
+	assert.EqualValues(t, []string{"n3", "n4", "n5"}, names(scanned))
+	assert.NotEmpty(t, page.NextCursor)
+
+	page.After = "invalid"
+	assert.EqualValues(t, ErrInvalidCursor, testEngine.Paginate(&page).Find(&ss))
+}
@@ -0,0 +1,405 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// Preload loads the relations tagged by has_one, has_many, belongs_to or many2many
+// for the records of Find or Get. The relations of all the records are loaded by
+// batched IN queries, nested relations are separated by dots, e.g.
+//
+//	engine.Preload("Orders", "Orders.Items").Find(&users)
+func (session *Session) Preload(relations ...string) *Session {
+	session.statement.preloads = append(session.statement.preloads, relations...)
+	return session
+}
+
+// preload loads the relations of beans which could be a pointer to a struct,
+// a slice or a map of structs
+func (session *Session) preload(beans interface{}, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	var elems []reflect.Value
+	var appendElem = func(v reflect.Value) {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return
+			}
+			v = v.Elem()
+		}
+		elems = append(elems, v)
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(beans))
+	switch v.Kind() {
+	case reflect.Struct:
+		elems = append(elems, v)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			appendElem(v.Index(i))
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.Ptr {
+			return errors.New("preload needs a map of pointers")
+		}
+		for _, key := range v.MapKeys() {
+			appendElem(v.MapIndex(key))
+		}
+	default:
+		return ErrParamsType
+	}
+
+	if len(elems) == 0 {
+		return nil
+	}
+	return session.loadRelations(elems, paths)
+}
+
+// splitPreloadPaths returns the first level relations and their nested paths
+func splitPreloadPaths(paths []string) ([]string, map[string][]string) {
+	var names []string
+	var children = make(map[string][]string)
+	for _, path := range paths {
+		parts := strings.SplitN(path, ".", 2)
+		if _, ok := children[parts[0]]; !ok {
+			names = append(names, parts[0])
+			children[parts[0]] = nil
+		}
+		if len(parts) > 1 {
+			children[parts[0]] = append(children[parts[0]], parts[1])
+		}
+	}
+	return names, children
+}
+
+// loadRelations loads the relations of elems which are addressable structs of the same type
+func (session *Session) loadRelations(elems []reflect.Value, paths []string) error {
+	table, err := session.engine.autoMapType(elems[0])
+	if err != nil {
+		return err
+	}
+
+	names, children := splitPreloadPaths(paths)
+	for _, name := range names {
+		rel, err := session.engine.relationOf(elems[0].Type(), name)
+		if err != nil {
+			return err
+		}
+		relatedTable, err := session.engine.autoMapType(reflect.New(rel.ElemType).Elem())
+		if err != nil {
+			return err
+		}
+
+		var ownerCols []string
+		var groups map[string][]reflect.Value
+		var results []reflect.Value
+		if rel.Type == relationManyToMany {
+			ownerCols, groups, results, err = session.loadManyToMany(rel, table, relatedTable, elems)
+		} else {
+			ownerCols, groups, results, err = session.loadRelated(rel, table, relatedTable, elems)
+		}
+		if err != nil {
+			return err
+		}
+
+		// nested relations should be loaded before the records are copied to the fields
+		if len(children[name]) > 0 && len(results) > 0 {
+			var relatedElems = make([]reflect.Value, 0, len(results))
+			for _, result := range results {
+				relatedElems = append(relatedElems, result.Elem())
+			}
+			if err := session.loadRelations(relatedElems, children[name]); err != nil {
+				return err
+			}
+		}
+
+		for _, elem := range elems {
+			_, key, ok, err := relationKey(table, elem, ownerCols)
+			if err != nil {
+				return err
+			}
+			var matches []reflect.Value
+			if ok {
+				matches = groups[key]
+			}
+			setRelationField(elem.FieldByName(rel.FieldName), matches)
+		}
+	}
+	return nil
+}
+
+// setRelationField sets the related records which are pointers to structs to the field
+func setRelationField(field reflect.Value, matches []reflect.Value) {
+	var fieldType = field.Type()
+	if fieldType.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(fieldType, 0, len(matches))
+		for _, match := range matches {
+			if fieldType.Elem().Kind() == reflect.Ptr {
+				slice = reflect.Append(slice, match)
+			} else {
+				slice = reflect.Append(slice, match.Elem())
+			}
+		}
+		field.Set(slice)
+		return
+	}
+
+	if len(matches) == 0 {
+		field.Set(reflect.Zero(fieldType))
+	} else if fieldType.Kind() == reflect.Ptr {
+		field.Set(matches[0])
+	} else {
+		field.Set(matches[0].Elem())
+	}
+}
+
+// relationKeys returns the distinct values of the columns of elems
+func relationKeys(table *core.Table, elems []reflect.Value, colNames []string) ([][]interface{}, error) {
+	var keys [][]interface{}
+	var seen = make(map[string]bool, len(elems))
+	for _, elem := range elems {
+		values, key, ok, err := relationKey(table, elem, colNames)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, values)
+	}
+	return keys, nil
+}
+
+// relationCond returns the condition which matches any of the keys
+func (session *Session) relationCond(colNames []string, keys [][]interface{}) builder.Cond {
+	if len(colNames) == 1 {
+		var values = make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			values = append(values, key[0])
+		}
+		// In expands a single value which is a slice such as []byte
+		if len(values) == 1 {
+			return builder.Eq{session.engine.Quote(colNames[0]): values[0]}
+		}
+		return builder.In(session.engine.Quote(colNames[0]), values...)
+	}
+
+	var cond = builder.NewCond()
+	for _, key := range keys {
+		var eq = builder.Eq{}
+		for i, colName := range colNames {
+			eq[session.engine.Quote(colName)] = key[i]
+		}
+		cond = cond.Or(eq)
+	}
+	return cond
+}
+
+// relationChunks splits the keys so that the args of one query will not exceed
+// the limitation of the database
+func (session *Session) relationChunks(colNames []string, keys [][]interface{}) [][][]interface{} {
+	var size = session.insertMultiMaxArgs() / len(colNames)
+	if size < 1 {
+		size = 1
+	}
+	var chunks [][][]interface{}
+	for start := 0; start < len(keys); start += size {
+		end := start + size
+		if end > len(keys) {
+			end = len(keys)
+		}
+		chunks = append(chunks, keys[start:end])
+	}
+	return chunks
+}
+
+// findRelated finds the records of elemType whose columns match the keys,
+// the results are pointers to the structs
+func (session *Session) findRelated(elemType reflect.Type, colNames []string, keys [][]interface{}) ([]reflect.Value, error) {
+	var results []reflect.Value
+	for _, chunk := range session.relationChunks(colNames, keys) {
+		slicePtr := reflect.New(reflect.SliceOf(reflect.PtrTo(elemType)))
+		if err := session.hookSession().Where(session.relationCond(colNames, chunk)).Find(slicePtr.Interface()); err != nil {
+			return nil, err
+		}
+		slice := slicePtr.Elem()
+		for i := 0; i < slice.Len(); i++ {
+			results = append(results, slice.Index(i))
+		}
+	}
+	return results, nil
+}
+
+// loadRelated loads has_one, has_many and belongs_to relations, the returned groups
+// are keyed by the values of ownerCols
+func (session *Session) loadRelated(rel *relation, owner, related *core.Table, elems []reflect.Value) (ownerCols []string, groups map[string][]reflect.Value, results []reflect.Value, err error) {
+	ownerCols, relatedCols, err := rel.joinColumns(session.engine, owner, related)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	keys, err := relationKeys(owner, elems, ownerCols)
+	if err != nil || len(keys) == 0 {
+		return ownerCols, nil, nil, err
+	}
+
+	results, err = session.findRelated(rel.ElemType, relatedCols, keys)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	groups = make(map[string][]reflect.Value)
+	for _, result := range results {
+		_, key, ok, err := relationKey(related, result.Elem(), relatedCols)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if ok {
+			groups[key] = append(groups[key], result)
+		}
+	}
+	return ownerCols, groups, results, nil
+}
+
+// fieldTypeOf returns the type of the field of the column which is not a pointer
+func fieldTypeOf(table *core.Table, structValue reflect.Value, colName string) (reflect.Type, error) {
+	col := table.GetColumn(colName)
+	if col == nil {
+		return nil, fmt.Errorf("column %s not found in table %s", colName, table.Name)
+	}
+	fieldValue, err := col.ValueOfV(&structValue)
+	if err != nil {
+		return nil, err
+	}
+	tp := fieldValue.Type()
+	for tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	return tp, nil
+}
+
+// loadManyToMany loads many2many relations via the join table, the returned groups
+// are keyed by the primary keys of the owner
+func (session *Session) loadManyToMany(rel *relation, owner, related *core.Table, elems []reflect.Value) (ownerCols []string, groups map[string][]reflect.Value, results []reflect.Value, err error) {
+	joinTableName, joinOwnerCols, joinRelatedCols, err := rel.joinTable(session.engine, owner, related)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	keys, err := relationKeys(owner, elems, owner.PrimaryKeys)
+	if err != nil || len(keys) == 0 {
+		return owner.PrimaryKeys, nil, nil, err
+	}
+
+	var cols = make([]string, 0, len(joinOwnerCols)+len(joinRelatedCols))
+	cols = append(cols, joinOwnerCols...)
+	cols = append(cols, joinRelatedCols...)
+
+	// the keys of the join table are scanned into the types of the primary keys so
+	// that they are formatted as the keys of the structs
+	var types = make([]reflect.Type, 0, len(cols))
+	for _, colName := range owner.PrimaryKeys {
+		tp, err := fieldTypeOf(owner, elems[0], colName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		types = append(types, tp)
+	}
+	for _, colName := range related.PrimaryKeys {
+		tp, err := fieldTypeOf(related, reflect.New(rel.ElemType).Elem(), colName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		types = append(types, tp)
+	}
+	if len(types) != len(cols) {
+		return nil, nil, nil, fmt.Errorf("the columns of the join table %s do not match the primary keys", joinTableName)
+	}
+
+	type joinPair struct {
+		ownerKey, relatedKey string
+	}
+	var pairs []joinPair
+	var relatedKeys [][]interface{}
+	var seen = make(map[string]bool)
+	for _, chunk := range session.relationChunks(joinOwnerCols, keys) {
+		query := session.hookSession().Table(joinTableName).Cols(cols...).
+			Where(session.relationCond(joinOwnerCols, chunk))
+		sqlStr, args, err := query.genQuerySQL()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		rows, err := query.queryRows(sqlStr, args...)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for rows.Next() {
+			var dests = make([]interface{}, len(types))
+			for i, tp := range types {
+				dests[i] = reflect.New(tp).Interface()
+			}
+			if err := rows.Scan(dests...); err != nil {
+				rows.Close()
+				return nil, nil, nil, err
+			}
+
+			var keyStrs = make([]string, len(dests))
+			var values = make([]interface{}, len(dests))
+			for i, dest := range dests {
+				values[i] = reflect.ValueOf(dest).Elem().Interface()
+				keyStrs[i] = fmt.Sprint(values[i])
+			}
+			n := len(joinOwnerCols)
+			pair := joinPair{strings.Join(keyStrs[:n], "\x00"), strings.Join(keyStrs[n:], "\x00")}
+			pairs = append(pairs, pair)
+			if !seen[pair.relatedKey] {
+				seen[pair.relatedKey] = true
+				relatedKeys = append(relatedKeys, values[n:])
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if len(relatedKeys) == 0 {
+		return owner.PrimaryKeys, nil, nil, nil
+	}
+
+	results, err = session.findRelated(rel.ElemType, related.PrimaryKeys, relatedKeys)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var resultsByKey = make(map[string]reflect.Value, len(results))
+	for _, result := range results {
+		_, key, ok, err := relationKey(related, result.Elem(), related.PrimaryKeys)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if ok {
+			resultsByKey[key] = result
+		}
+	}
+
+	groups = make(map[string][]reflect.Value)
+	for _, pair := range pairs {
+		if result, ok := resultsByKey[pair.relatedKey]; ok {
+			groups[pair.ownerKey] = append(groups[pair.ownerKey], result)
+		}
+	}
+	return owner.PrimaryKeys, groups, results, nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type PreloadUser struct {
+	Id      int64
+	Name    string
+	Profile *PreloadProfile `xorm:"has_one(preload_user_id)"`
+	Orders  []PreloadOrder  `xorm:"has_many(preload_user_id)"`
+	Groups  []*PreloadGroup `xorm:"many2many(preload_user_group,preload_user_id,preload_group_id)"`
+}
+
+type PreloadProfile struct {
+	Id            int64
+	PreloadUserId int64
+	Bio           string
+}
+
+type PreloadOrder struct {
+	Id            int64
+	PreloadUserId int64
+	Name          string
+	PreloadUser   *PreloadUser       `xorm:"belongs_to"`
+	Items         []PreloadOrderItem `xorm:"has_many(order_id)"`
+}
+
+type PreloadOrderItem struct {
+	Id      int64
+	OrderId int64
+	Name    string
+}
+
+type PreloadGroup struct {
+	Id   int64
+	Name string
+}
+
+type PreloadUserGroup struct {
+	PreloadUserId  int64 `xorm:"pk"`
+	PreloadGroupId int64 `xorm:"pk"`
+}
+
+func TestPreload(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(PreloadUser), new(PreloadProfile), new(PreloadOrder),
+		new(PreloadOrderItem), new(PreloadGroup), new(PreloadUserGroup))
+
+	var users = []PreloadUser{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	for i := range users {
+		_, err := testEngine.Insert(&users[i])
+		assert.NoError(t, err)
+	}
+
+	_, err := testEngine.Insert(&PreloadProfile{PreloadUserId: users[0].Id, Bio: "bio a"})
+	assert.NoError(t, err)
+
+	var orders = []PreloadOrder{
+		{PreloadUserId: users[0].Id, Name: "o1"},
+		{PreloadUserId: users[0].Id, Name: "o2"},
+		{PreloadUserId: users[1].Id, Name: "o3"},
+	}
+	for i := range orders {
+		_, err = testEngine.Insert(&orders[i])
+		assert.NoError(t, err)
+	}
+	_, err = testEngine.Insert([]PreloadOrderItem{
+		{OrderId: orders[0].Id, Name: "i1"},
+		{OrderId: orders[0].Id, Name: "i2"},
+		{OrderId: orders[2].Id, Name: "i3"},
+	})
+	assert.NoError(t, err)
+
+	var groups = []PreloadGroup{{Name: "g1"}, {Name: "g2"}}
+	for i := range groups {
+		_, err = testEngine.Insert(&groups[i])
+		assert.NoError(t, err)
+	}
+	_, err = testEngine.Insert([]PreloadUserGroup{
+		{users[0].Id, groups[0].Id},
+		{users[0].Id, groups[1].Id},
+		{users[1].Id, groups[1].Id},
+	})
+	assert.NoError(t, err)
+
+	var results []PreloadUser
+	err = testEngine.Preload("Profile", "Orders.Items", "Groups").Asc("id").Find(&results)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, len(results))
+
+	assert.NotNil(t, results[0].Profile)
+	assert.EqualValues(t, "bio a", results[0].Profile.Bio)
+	assert.Nil(t, results[1].Profile)
+
+	assert.EqualValues(t, 2, len(results[0].Orders))
+	assert.EqualValues(t, 2, len(results[0].Orders[0].Items)+len(results[0].Orders[1].Items))
+	assert.EqualValues(t, 1, len(results[1].Orders))
+	assert.EqualValues(t, "i3", results[1].Orders[0].Items[0].Name)
+	assert.EqualValues(t, 0, len(results[2].Orders))
+
+	assert.EqualValues(t, 2, len(results[0].Groups))
+	assert.EqualValues(t, 1, len(results[1].Groups))
+	assert.EqualValues(t, "g2", results[1].Groups[0].Name)
+	assert.EqualValues(t, 0, len(results[2].Groups))
+
+	// belongs_to and Get
+	var order PreloadOrder
+	has, err := testEngine.ID(orders[2].Id).Preload("PreloadUser").Get(&order)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.NotNil(t, order.PreloadUser)
+	assert.EqualValues(t, "b", order.PreloadUser.Name)
+
+	// map of pointers
+	var userMap = make(map[int64]*PreloadUser)
+	assert.NoError(t, testEngine.Preload("Orders").Find(&userMap))
+	assert.EqualValues(t, 2, len(userMap[users[0].Id].Orders))
+
+	err = testEngine.Preload("Name").Find(&results)
+	assert.Error(t, err)
+}
+
+type PreloadBinUser struct {
+	Code   []byte `xorm:"pk varbinary(16)"`
+	Name   string
+	Groups []PreloadBinGroup `xorm:"many2many(preload_bin_user_group,user_code,group_code)"`
+}
+
+type PreloadBinGroup struct {
+	Code []byte `xorm:"pk varbinary(16)"`
+	Name string
+}
+
+type PreloadBinUserGroup struct {
+	UserCode  []byte `xorm:"pk varbinary(16)"`
+	GroupCode []byte `xorm:"pk varbinary(16)"`
+}
+
+func TestPreloadManyToManyBinaryKeys(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(PreloadBinUser), new(PreloadBinGroup), new(PreloadBinUserGroup))
+
+	_, err := testEngine.Insert(&PreloadBinUser{Code: []byte{1, 2}, Name: "a"})
+	assert.NoError(t, err)
+	_, err = testEngine.Insert(&PreloadBinGroup{Code: []byte{3, 4}, Name: "g"})
+	assert.NoError(t, err)
+	_, err = testEngine.Insert(&PreloadBinUserGroup{UserCode: []byte{1, 2}, GroupCode: []byte{3, 4}})
+	assert.NoError(t, err)
+
+	var users []PreloadBinUser
+	assert.NoError(t, testEngine.Preload("Groups").Find(&users))
+	if assert.EqualValues(t, 1, len(users)) && assert.EqualValues(t, 1, len(users[0].Groups)) {
+		assert.EqualValues(t, "g", users[0].Groups[0].Name)
+	}
+}
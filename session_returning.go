@@ -0,0 +1,136 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"strings"
+
+	"xorm.io/core"
+)
+
+// Returning asks Insert, Update and Delete to load the columns of the affected
+// records back into the beans, all the columns will be loaded if no column is
+// specified. It's supported by Postgres and SQLite 3.35.0 or later via RETURNING and
+// MSSQL via OUTPUT, the number of the returned records will be the affected rows.
+// An ErrSQLiteVersion is returned on the older SQLite such as the one bundled with
+// go-sqlite3 v1.10.0 which is 3.25.2.
+func (session *Session) Returning(columns ...string) *Session {
+	session.statement.Returning(columns...)
+	return session
+}
+
+// Returning loads the specified columns of the affected records
+func (statement *Statement) Returning(columns ...string) *Statement {
+	statement.returning = true
+	statement.returningCols = append(statement.returningCols, col2NewCols(columns...)...)
+	return statement
+}
+
+func (statement *Statement) returningColumnNames() []string {
+	if len(statement.returningCols) > 0 || statement.RefTable == nil {
+		return statement.returningCols
+	}
+
+	var colNames = make([]string, 0, len(statement.RefTable.ColumnsSeq()))
+	for _, col := range statement.RefTable.Columns() {
+		if col.MapType == core.ONLYTODB {
+			continue
+		}
+		colNames = append(colNames, col.Name)
+	}
+	return colNames
+}
+
+// genReturning generates the OUTPUT clause for MSSQL which should be placed
+// before VALUES or WHERE and the RETURNING clause for the other databases
+// which should be appended to the statement. prefix is INSERTED or DELETED.
+func (statement *Statement) genReturning(prefix string) (output string, returning string, err error) {
+	colNames := statement.returningColumnNames()
+	if len(colNames) == 0 {
+		return "", "", errors.New("no columns to be returned")
+	}
+
+	quote := statement.Engine.Quote
+	switch statement.Engine.dialect.DBType() {
+	case core.SQLITE:
+		if dialect, ok := statement.Engine.dialect.(*sqlite3); ok {
+			if err := dialect.requireVersion("RETURNING", "3.35.0"); err != nil {
+				return "", "", err
+			}
+		}
+		return "", " RETURNING " + quoteJoin(colNames, quote, ", "), nil
+	case core.POSTGRES:
+		return "", " RETURNING " + quoteJoin(colNames, quote, ", "), nil
+	case core.MSSQL:
+		return " OUTPUT " + quoteJoin(colNames, func(col string) string {
+			return prefix + "." + quote(col)
+		}, ", "), "", nil
+	}
+	return "", "", ErrNotImplemented
+}
+
+// queryReturning executes the statement which returns records and reads all of them
+func (session *Session) queryReturning(sqlStr string, args ...interface{}) ([]string, [][]interface{}, error) {
+	rows, err := session.queryRows(sqlStr, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	fields, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var results [][]interface{}
+	for rows.Next() {
+		scanResults := make([]interface{}, len(fields))
+		for i := 0; i < len(fields); i++ {
+			var cell interface{}
+			scanResults[i] = &cell
+		}
+		if err := rows.Scan(scanResults...); err != nil {
+			return nil, nil, err
+		}
+		results = append(results, scanResults)
+	}
+	return fields, results, rows.Err()
+}
+
+// fillReturning sets the returned records to the beans one by one
+func (session *Session) fillReturning(table *core.Table, fields []string, results [][]interface{}, beans ...interface{}) error {
+	if table == nil {
+		return nil
+	}
+
+	// the beans are not loaded by a query, so the after load processors and
+	// cascade loading should not be triggered
+	afterProcessors := session.afterProcessors
+	useCascade := session.statement.UseCascade
+	session.statement.UseCascade = false
+	defer func() {
+		session.afterProcessors = afterProcessors
+		session.statement.UseCascade = useCascade
+	}()
+
+	for i := 0; i < len(beans) && i < len(results); i++ {
+		dataStruct := rValue(beans[i])
+		if _, err := session.slice2Bean(results[i], fields, beans[i], &dataStruct, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returningContains returns true if the column will be returned
+func (statement *Statement) returningContains(colName string) bool {
+	for _, col := range statement.returningColumnNames() {
+		if strings.EqualFold(col, colName) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+func TestGenReturning(t *testing.T) {
+	tests := []struct {
+		dbType    core.DbType
+		dialect   core.Dialect
+		output    string
+		returning string
+		err       error
+	}{
+		{core.POSTGRES, &postgres{}, "", ` RETURNING "id", "name"`, nil},
+		{core.SQLITE, &sqlite3{version: "3.35.0"}, "", " RETURNING `id`, `name`", nil},
+		{core.SQLITE, &sqlite3{version: "3.25.2"}, "", "", ErrSQLiteVersion{Feature: "RETURNING", Required: "3.35.0", Version: "3.25.2"}},
+		{core.MSSQL, &mssql{}, ` OUTPUT INSERTED."id", INSERTED."name"`, "", nil},
+		{core.MYSQL, &mysql{}, "", "", ErrNotImplemented},
+	}
+
+	for _, test := range tests {
+		assert.NoError(t, test.dialect.Init(nil, &core.Uri{DbType: test.dbType}, "", ""))
+		statement := Statement{Engine: &Engine{dialect: test.dialect}}
+		statement.Returning("id", "name")
+		output, returning, err := statement.genReturning("INSERTED")
+		assert.EqualValues(t, test.err, err)
+		assert.EqualValues(t, test.output, output)
+		assert.EqualValues(t, test.returning, returning)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.EqualValues(t, -1, compareVersions("3.25.2", "3.35.0"))
+	assert.EqualValues(t, 1, compareVersions("3.100.0", "3.35.0"))
+	assert.EqualValues(t, 0, compareVersions("3.35", "3.35.0"))
+}
+
+type ReturningStruct struct {
+	Id      int64
+	Name    string
+	Counter int `xorm:"default 5"`
+	Version int `xorm:"version"`
+}
+
+func TestReturning(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(ReturningStruct))
+
+	if dialect, ok := testEngine.Dialect().(*sqlite3); ok {
+		if err := dialect.requireVersion("RETURNING", "3.35.0"); err != nil {
+			_, err = testEngine.Returning().Insert(&ReturningStruct{Name: "a"})
+			assert.IsType(t, ErrSQLiteVersion{}, err)
+			return
+		}
+	} else if testEngine.Dialect().DBType() != core.POSTGRES && testEngine.Dialect().DBType() != core.MSSQL {
+		return
+	}
+
+	var s = ReturningStruct{Name: "a"}
+	cnt, err := testEngine.Omit("counter").Returning().Insert(&s)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.True(t, s.Id > 0)
+	assert.EqualValues(t, 5, s.Counter)
+	assert.EqualValues(t, 1, s.Version)
+
+	var s2 = ReturningStruct{Name: "b"}
+	cnt, err = testEngine.ID(s.Id).Cols("name").Returning("counter", "version").Update(&s2)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, 5, s2.Counter)
+	assert.EqualValues(t, 2, s2.Version)
+
+	var s3 ReturningStruct
+	cnt, err = testEngine.ID(s.Id).Returning().Delete(&s3)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, "b", s3.Name)
+	assert.EqualValues(t, 2, s3.Version)
+}
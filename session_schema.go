@@ -7,7 +7,6 @@ package xorm
 import (
 	"database/sql"
 	"fmt"
-	"strings"
 
 	"xorm.io/core"
 )
@@ -36,6 +35,10 @@ func (session *Session) createTable(bean interface{}) error {
 		return err
 	}
 
+	schema := session.engine.tableSchemaOf(session.statement.RefTable, session.statement.TableName())
+	if err := session.engine.checkTableSchema(schema); err != nil {
+		return err
+	}
 	sqlStr := session.statement.genCreateTableSQL()
 	_, err := session.exec(sqlStr)
 	return err
@@ -221,210 +224,5 @@ func (session *Session) addUnique(tableName, uqeName string) error {
 
 // Sync2 synchronize structs to database tables
 func (session *Session) Sync2(beans ...interface{}) error {
-	engine := session.engine
-
-	if session.isAutoClose {
-		session.isAutoClose = false
-		defer session.Close()
-	}
-
-	tables, err := engine.dialect.GetTables()
-	if err != nil {
-		return err
-	}
-
-	session.autoResetStatement = false
-	defer func() {
-		session.autoResetStatement = true
-		session.resetStatement()
-	}()
-
-	for _, bean := range beans {
-		v := rValue(bean)
-		table, err := engine.mapType(v)
-		if err != nil {
-			return err
-		}
-		var tbName string
-		if len(session.statement.AltTableName) > 0 {
-			tbName = session.statement.AltTableName
-		} else {
-			tbName = engine.TableName(bean)
-		}
-		tbNameWithSchema := engine.tbNameWithSchema(tbName)
-
-		var oriTable *core.Table
-		for _, tb := range tables {
-			if strings.EqualFold(engine.tbNameWithSchema(tb.Name), engine.tbNameWithSchema(tbName)) {
-				oriTable = tb
-				break
-			}
-		}
-
-		// this is a new table
-		if oriTable == nil {
-			err = session.StoreEngine(session.statement.StoreEngine).createTable(bean)
-			if err != nil {
-				return err
-			}
-
-			err = session.createUniques(bean)
-			if err != nil {
-				return err
-			}
-
-			err = session.createIndexes(bean)
-			if err != nil {
-				return err
-			}
-			continue
-		}
-
-		// this will modify an old table
-		if err = engine.loadTableInfo(oriTable); err != nil {
-			return err
-		}
-
-		// check columns
-		for _, col := range table.Columns() {
-			var oriCol *core.Column
-			for _, col2 := range oriTable.Columns() {
-				if strings.EqualFold(col.Name, col2.Name) {
-					oriCol = col2
-					break
-				}
-			}
-
-			// column is not exist on table
-			if oriCol == nil {
-				session.statement.RefTable = table
-				session.statement.tableName = tbNameWithSchema
-				if err = session.addColumn(col.Name); err != nil {
-					return err
-				}
-				continue
-			}
-
-			err = nil
-			expectedType := engine.dialect.SqlType(col)
-			curType := engine.dialect.SqlType(oriCol)
-			if expectedType != curType {
-				if expectedType == core.Text &&
-					strings.HasPrefix(curType, core.Varchar) {
-					// currently only support mysql & postgres
-					if engine.dialect.DBType() == core.MYSQL ||
-						engine.dialect.DBType() == core.POSTGRES {
-						engine.logger.Infof("Table %s column %s change type from %s to %s\n",
-							tbNameWithSchema, col.Name, curType, expectedType)
-						_, err = session.exec(engine.dialect.ModifyColumnSql(tbNameWithSchema, col))
-					} else {
-						engine.logger.Warnf("Table %s column %s db type is %s, struct type is %s\n",
-							tbNameWithSchema, col.Name, curType, expectedType)
-					}
-				} else if strings.HasPrefix(curType, core.Varchar) && strings.HasPrefix(expectedType, core.Varchar) {
-					if engine.dialect.DBType() == core.MYSQL {
-						if oriCol.Length < col.Length {
-							engine.logger.Infof("Table %s column %s change type from varchar(%d) to varchar(%d)\n",
-								tbNameWithSchema, col.Name, oriCol.Length, col.Length)
-							_, err = session.exec(engine.dialect.ModifyColumnSql(tbNameWithSchema, col))
-						}
-					}
-				} else {
-					if !(strings.HasPrefix(curType, expectedType) && curType[len(expectedType)] == '(') {
-						engine.logger.Warnf("Table %s column %s db type is %s, struct type is %s",
-							tbNameWithSchema, col.Name, curType, expectedType)
-					}
-				}
-			} else if expectedType == core.Varchar {
-				if engine.dialect.DBType() == core.MYSQL {
-					if oriCol.Length < col.Length {
-						engine.logger.Infof("Table %s column %s change type from varchar(%d) to varchar(%d)\n",
-							tbNameWithSchema, col.Name, oriCol.Length, col.Length)
-						_, err = session.exec(engine.dialect.ModifyColumnSql(tbNameWithSchema, col))
-					}
-				}
-			}
-
-			if col.Default != oriCol.Default {
-				if (col.SQLType.Name == core.Bool || col.SQLType.Name == core.Boolean) &&
-					((strings.EqualFold(col.Default, "true") && oriCol.Default == "1") ||
-						(strings.EqualFold(col.Default, "false") && oriCol.Default == "0")) {
-				} else {
-					engine.logger.Warnf("Table %s Column %s db default is %s, struct default is %s",
-						tbName, col.Name, oriCol.Default, col.Default)
-				}
-			}
-			if col.Nullable != oriCol.Nullable {
-				engine.logger.Warnf("Table %s Column %s db nullable is %v, struct nullable is %v",
-					tbName, col.Name, oriCol.Nullable, col.Nullable)
-			}
-
-			if err != nil {
-				return err
-			}
-		}
-
-		var foundIndexNames = make(map[string]bool)
-		var addedNames = make(map[string]*core.Index)
-
-		for name, index := range table.Indexes {
-			var oriIndex *core.Index
-			for name2, index2 := range oriTable.Indexes {
-				if index.Equal(index2) {
-					oriIndex = index2
-					foundIndexNames[name2] = true
-					break
-				}
-			}
-
-			if oriIndex != nil {
-				if oriIndex.Type != index.Type {
-					sql := engine.dialect.DropIndexSql(tbNameWithSchema, oriIndex)
-					_, err = session.exec(sql)
-					if err != nil {
-						return err
-					}
-					oriIndex = nil
-				}
-			}
-
-			if oriIndex == nil {
-				addedNames[name] = index
-			}
-		}
-
-		for name2, index2 := range oriTable.Indexes {
-			if _, ok := foundIndexNames[name2]; !ok {
-				sql := engine.dialect.DropIndexSql(tbNameWithSchema, index2)
-				_, err = session.exec(sql)
-				if err != nil {
-					return err
-				}
-			}
-		}
-
-		for name, index := range addedNames {
-			if index.Type == core.UniqueType {
-				session.statement.RefTable = table
-				session.statement.tableName = tbNameWithSchema
-				err = session.addUnique(tbNameWithSchema, name)
-			} else if index.Type == core.IndexType {
-				session.statement.RefTable = table
-				session.statement.tableName = tbNameWithSchema
-				err = session.addIndex(tbNameWithSchema, name)
-			}
-			if err != nil {
-				return err
-			}
-		}
-
-		// check all the columns which removed from struct fields but left on database tables.
-		for _, colName := range oriTable.ColumnsSeq() {
-			if table.GetColumn(colName) == nil {
-				engine.logger.Warnf("Table %s has column %s but struct has not related field", engine.TableName(oriTable.Name, true), colName)
-			}
-		}
-	}
-
-	return nil
+	return session.SyncWithOptions(SyncOptions{}, beans...)
 }
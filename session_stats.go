@@ -17,6 +17,13 @@ func (session *Session) Count(bean ...interface{}) (int64, error) {
 		defer session.Close()
 	}
 
+	if len(bean) > 0 {
+		if err := session.statement.setRefBean(bean[0]); err != nil {
+			return 0, err
+		}
+	}
+	session.applyDefaultScopes()
+
 	var sqlStr string
 	var args []interface{}
 	var err error
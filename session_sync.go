@@ -0,0 +1,618 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"xorm.io/core"
+)
+
+// SyncChangeType represents the kind of a SyncChange
+type SyncChangeType int
+
+// enumerate all the kinds of SyncChange
+const (
+	SyncCreateTable SyncChangeType = iota + 1
+	SyncAddColumn
+	SyncDropColumn
+	SyncAlterColumn
+	SyncAddIndex
+	SyncDropIndex
+	SyncRebuildTable
+	SyncRenameColumn
+	SyncAddForeignKey
+	SyncAddCheck
+)
+
+func (tp SyncChangeType) String() string {
+	switch tp {
+	case SyncCreateTable:
+		return "create table"
+	case SyncAddColumn:
+		return "add column"
+	case SyncDropColumn:
+		return "drop column"
+	case SyncAlterColumn:
+		return "alter column"
+	case SyncAddIndex:
+		return "add index"
+	case SyncDropIndex:
+		return "drop index"
+	case SyncRebuildTable:
+		return "rebuild table"
+	case SyncRenameColumn:
+		return "rename column"
+	case SyncAddForeignKey:
+		return "add foreign key"
+	case SyncAddCheck:
+		return "add check"
+	}
+	return "unknown"
+}
+
+// SyncChange is a difference between a struct and its table in the database
+type SyncChange struct {
+	Type  SyncChangeType
+	Table string
+	// Column is the column of the struct, or the column of the database for
+	// SyncDropColumn
+	Column *core.Column
+	// OldColumn is the column of the database for SyncAlterColumn and SyncRenameColumn
+	OldColumn *core.Column
+	// Index is the index of the struct, or the index of the database for SyncDropIndex
+	Index *core.Index
+	// ForeignKey is the foreign key of the struct for SyncAddForeignKey
+	ForeignKey *ForeignKey
+	// Check is the CHECK constraint of the struct for SyncAddCheck
+	Check *Check
+	// Attrs and OldAttrs are the attributes of Column and OldColumn which
+	// core.Column has not, they are nil if the columns have none
+	Attrs    *ColumnSchema
+	OldAttrs *ColumnSchema
+
+	TypeChanged      bool
+	NullableChanged  bool
+	DefaultChanged   bool
+	CommentChanged   bool
+	CollationChanged bool
+	GeneratedChanged bool
+	// Narrowing means the new type of the column may not hold all the values of
+	// the old type, the type is not changed then.
+	Narrowing bool
+
+	// SQLs are the statements which apply the change
+	SQLs []string
+	// Warning describes the part of the change which cannot be applied on the
+	// database, Sync2 logs it as a warning.
+	Warning string
+
+	// target is the column after the change which cannot be altered in place
+	target *core.Column
+}
+
+func (change *SyncChange) String() string {
+	switch change.Type {
+	case SyncCreateTable, SyncRebuildTable:
+		return fmt.Sprintf("%s %s", change.Type, change.Table)
+	case SyncAddColumn, SyncDropColumn:
+		return fmt.Sprintf("%s %s.%s", change.Type, change.Table, change.Column.Name)
+	case SyncAddIndex, SyncDropIndex:
+		return fmt.Sprintf("%s %s on %s(%s)", change.Type, change.Index.Name, change.Table,
+			strings.Join(change.Index.Cols, ","))
+	case SyncRenameColumn:
+		return fmt.Sprintf("%s %s.%s to %s", change.Type, change.Table, change.OldColumn.Name, change.Column.Name)
+	case SyncAddForeignKey:
+		return fmt.Sprintf("%s %s on %s(%s) references %s(%s)", change.Type, change.ForeignKey.Name, change.Table,
+			strings.Join(change.ForeignKey.Cols, ","), change.ForeignKey.RefTable, strings.Join(change.ForeignKey.RefCols, ","))
+	case SyncAddCheck:
+		return fmt.Sprintf("%s %s on %s (%s)", change.Type, change.Check.Name, change.Table, change.Check.Expr)
+	}
+
+	var diffs []string
+	if change.TypeChanged {
+		diffs = append(diffs, fmt.Sprintf("type %s -> %s", columnTypeString(change.OldColumn), columnTypeString(change.Column)))
+	}
+	if change.NullableChanged {
+		diffs = append(diffs, fmt.Sprintf("nullable %v -> %v", change.OldColumn.Nullable, change.Column.Nullable))
+	}
+	if change.DefaultChanged {
+		diffs = append(diffs, fmt.Sprintf("default %q -> %q", change.OldColumn.Default, change.Column.Default))
+	}
+	if change.CommentChanged {
+		diffs = append(diffs, fmt.Sprintf("comment %q -> %q", change.OldColumn.Comment, change.Column.Comment))
+	}
+	if change.CollationChanged {
+		diffs = append(diffs, fmt.Sprintf("collation %q -> %q", change.OldAttrs.collation(), change.Attrs.collation()))
+	}
+	if change.GeneratedChanged {
+		diffs = append(diffs, fmt.Sprintf("generated %q -> %q", change.OldAttrs.generated(), change.Attrs.generated()))
+	}
+	return fmt.Sprintf("%s %s.%s: %s", change.Type, change.Table, change.Column.Name, strings.Join(diffs, ", "))
+}
+
+func columnTypeString(col *core.Column) string {
+	if col.Length2 > 0 {
+		return fmt.Sprintf("%s(%d,%d)", col.SQLType.Name, col.Length, col.Length2)
+	} else if col.Length > 0 {
+		return fmt.Sprintf("%s(%d)", col.SQLType.Name, col.Length)
+	}
+	return col.SQLType.Name
+}
+
+// SyncPlan is the changes which Sync2 would apply
+type SyncPlan struct {
+	Changes []*SyncChange
+}
+
+// SQLs returns the statements of all the changes in order
+func (plan *SyncPlan) SQLs() []string {
+	var sqls []string
+	for _, change := range plan.Changes {
+		sqls = append(sqls, change.SQLs...)
+	}
+	return sqls
+}
+
+// Unapplied returns the changes which Sync2 cannot apply completely
+func (plan *SyncPlan) Unapplied() []*SyncChange {
+	var changes []*SyncChange
+	for _, change := range plan.Changes {
+		if change.Warning != "" {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// SyncOptions represents the options of SyncWithOptions
+type SyncOptions struct {
+	// Strict makes SyncWithOptions return an ErrColumnNarrowing without changing
+	// the database if the type of a column would be narrowed, otherwise the type
+	// is kept and a warning is logged.
+	Strict bool
+	// DropColumns makes SyncWithOptions drop the columns which are not in the
+	// structs, otherwise they are kept and a warning is logged. The indexes which
+	// are not in the structs are always dropped.
+	DropColumns bool
+}
+
+// PlanSync compares the structs with the tables in the database and returns the
+// changes which Sync2 would apply without changing the database.
+func (session *Session) PlanSync(beans ...interface{}) (*SyncPlan, error) {
+	return session.PlanSyncWithOptions(SyncOptions{}, beans...)
+}
+
+// PlanSyncWithOptions is PlanSync for SyncWithOptions
+func (session *Session) PlanSyncWithOptions(opts SyncOptions, beans ...interface{}) (*SyncPlan, error) {
+	if session.isAutoClose {
+		session.isAutoClose = false
+		defer session.Close()
+	}
+
+	session.autoResetStatement = false
+	defer func() {
+		session.autoResetStatement = true
+		session.resetStatement()
+	}()
+
+	return session.planSync(beans, opts)
+}
+
+// SyncWithOptions is Sync2 with options
+func (session *Session) SyncWithOptions(opts SyncOptions, beans ...interface{}) error {
+	if session.isAutoClose {
+		session.isAutoClose = false
+		defer session.Close()
+	}
+
+	session.autoResetStatement = false
+	defer func() {
+		session.autoResetStatement = true
+		session.resetStatement()
+	}()
+
+	plan, err := session.planSync(beans, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Strict {
+		for _, change := range plan.Changes {
+			if change.Narrowing {
+				return ErrColumnNarrowing{
+					TableName:  change.Table,
+					ColumnName: change.Column.Name,
+					From:       columnTypeString(change.OldColumn),
+					To:         columnTypeString(change.Column),
+				}
+			}
+		}
+	}
+	return session.applySyncPlan(plan)
+}
+
+func (session *Session) planSync(beans []interface{}, opts SyncOptions) (*SyncPlan, error) {
+	engine := session.engine
+
+	tables, err := engine.dialect.GetTables()
+	if err != nil {
+		return nil, err
+	}
+
+	var plan SyncPlan
+	// the tables referenced by the foreign keys should be created at first
+	for _, bean := range engine.sortBeansByForeignKeys(beans) {
+		v := rValue(bean)
+		table, err := engine.mapType(v)
+		if err != nil {
+			return nil, err
+		}
+		var tbName string
+		if len(session.statement.AltTableName) > 0 {
+			tbName = session.statement.AltTableName
+		} else {
+			tbName = engine.TableName(bean)
+		}
+		tbNameWithSchema := engine.tbNameWithSchema(tbName)
+
+		var oriTable *core.Table
+		for _, tb := range tables {
+			if strings.EqualFold(engine.tbNameWithSchema(tb.Name), engine.tbNameWithSchema(tbName)) {
+				oriTable = tb
+				break
+			}
+		}
+
+		// this is a new table
+		if oriTable == nil {
+			var createName = session.statement.AltTableName
+			if createName == "" {
+				createName = engine.TableName(bean, true)
+			}
+			if err := engine.checkTableSchema(engine.tableSchemaOf(table, createName)); err != nil {
+				return nil, err
+			}
+			plan.Changes = append(plan.Changes, session.planCreateTable(table, tbName, createName))
+			continue
+		}
+
+		// this will modify an old table
+		if err = engine.loadTableInfo(oriTable); err != nil {
+			return nil, err
+		}
+		schema := engine.tableSchemaOf(table, tbName)
+		dbSchema, err := engine.dbTableSchemaFor(oriTable.Name, schema)
+		if err != nil {
+			return nil, err
+		}
+
+		// check columns
+		var columnChanges = len(plan.Changes)
+		var renamed = make(map[string]string)
+		for _, col := range table.Columns() {
+			var oriCol *core.Column
+			for _, col2 := range oriTable.Columns() {
+				if strings.EqualFold(col.Name, col2.Name) {
+					oriCol = col2
+					break
+				}
+			}
+
+			// the column is renamed from a column of the table
+			if oriCol == nil {
+				if oriCol = engine.renamedFromColumn(v.Type(), col, table, oriTable, renamed); oriCol != nil {
+					renamed[strings.ToLower(oriCol.Name)] = col.Name
+					plan.Changes = append(plan.Changes, &SyncChange{
+						Type:      SyncRenameColumn,
+						Table:     tbName,
+						Column:    col,
+						OldColumn: oriCol,
+						SQLs:      []string{engine.renameColumnSQL(tbNameWithSchema, oriCol, dbSchema.Column(oriCol.Name), col.Name)},
+					})
+				}
+			}
+
+			// column is not exist on table
+			if oriCol == nil {
+				session.statement.RefTable = table
+				session.statement.tableName = tbNameWithSchema
+				sql, _ := session.statement.genAddColumnStr(col)
+				attrs := schema.Column(col.Name)
+				if err := engine.checkColumnSchema(attrs); err != nil {
+					return nil, err
+				}
+				if dialect, ok := engine.dialect.(schemaDialect); ok && attrs != nil {
+					sql = dialect.AddColumnSql(tbNameWithSchema, col, attrs)
+				}
+				var sqls []string
+				if sql != "" {
+					sqls = append(sqls, sql)
+				}
+				if sql != "" && col.Comment != "" {
+					if sql := engine.columnCommentSQL(tbNameWithSchema, col); sql != "" {
+						sqls = append(sqls, sql)
+					}
+				}
+				plan.Changes = append(plan.Changes, &SyncChange{
+					Type:   SyncAddColumn,
+					Table:  tbName,
+					Column: col,
+					Attrs:  attrs,
+					SQLs:   sqls,
+				})
+				continue
+			}
+
+			if change := engine.planAlterColumn(tbName, tbNameWithSchema, col, oriCol, schema.Column(col.Name), dbSchema.Column(oriCol.Name)); change != nil {
+				plan.Changes = append(plan.Changes, change)
+			}
+		}
+
+		// the columns which removed from struct fields but left on database tables are
+		// dropped only if DropColumns
+		var dropChanges []*SyncChange
+		for _, colName := range oriTable.ColumnsSeq() {
+			if table.GetColumn(colName) != nil || renamed[strings.ToLower(colName)] != "" {
+				continue
+			}
+			change := &SyncChange{
+				Type:   SyncDropColumn,
+				Table:  tbName,
+				Column: oriTable.GetColumn(colName),
+			}
+			if opts.DropColumns {
+				change.SQLs = engine.dropColumnSQLs(tbNameWithSchema, change.Column)
+			} else {
+				change.Warning = "struct has not related field"
+			}
+			dropChanges = append(dropChanges, change)
+		}
+
+		// the foreign keys and the CHECK constraints are added and the indexes which are
+		// created for the foreign keys by MySQL are not dropped
+		for name, index := range oriTable.Indexes {
+			for _, fk := range dbSchema.ForeignKeys {
+				if strings.EqualFold(index.Name, fk.Name) {
+					delete(oriTable.Indexes, name)
+				}
+			}
+		}
+		fkChanges := engine.planForeignKeys(tbName, tbNameWithSchema, table, dbSchema.ForeignKeys, renamed)
+		fkChanges = append(fkChanges, engine.planChecks(tbName, tbNameWithSchema, schema.Checks, dbSchema.Checks)...)
+
+		// the columns are altered by rebuilding the table on SQLite
+		var tableChanges []*SyncChange
+		tableChanges = append(tableChanges, plan.Changes[columnChanges:]...)
+		tableChanges = append(tableChanges, dropChanges...)
+		tableChanges = append(tableChanges, fkChanges...)
+		rebuild, err := session.planRebuildTable(tbName, tbNameWithSchema, oriTable, dbSchema, tableChanges)
+		if err != nil {
+			return nil, err
+		}
+		if rebuild != nil {
+			// the columns are renamed after rebuilding so that the indexes and triggers
+			// of the old table are still valid while rebuilding
+			var renames []*SyncChange
+			var changes = append([]*SyncChange{}, plan.Changes[:columnChanges]...)
+			for _, change := range plan.Changes[columnChanges:] {
+				if change.Type == SyncRenameColumn {
+					renames = append(renames, change)
+				} else {
+					changes = append(changes, change)
+				}
+			}
+			plan.Changes = append(append(changes, rebuild), renames...)
+		}
+
+		// the indexes of the database are compared by the new names of the columns
+		for _, index := range oriTable.Indexes {
+			for i, colName := range index.Cols {
+				if newName := renamed[strings.ToLower(colName)]; newName != "" {
+					index.Cols[i] = newName
+				}
+			}
+		}
+		indexChanges := engine.planIndexes(tbName, tbNameWithSchema, table, oriTable)
+		if rebuild != nil {
+			// the indexes on the dropped columns are not created by rebuilding
+			for _, change := range indexChanges {
+				if change.Type == SyncDropIndex && opts.DropColumns && indexOnColumns(change.Index, dropChanges) {
+					change.SQLs = nil
+				}
+			}
+		}
+		plan.Changes = append(plan.Changes, indexChanges...)
+		plan.Changes = append(plan.Changes, fkChanges...)
+		plan.Changes = append(plan.Changes, dropChanges...)
+	}
+	return &plan, nil
+}
+
+// renamedFromColumn returns the column of the database which the column of the
+// struct is renamed from by the renamed_from tag, the column should not be a column
+// of the struct or renamed already.
+func (engine *Engine) renamedFromColumn(t reflect.Type, col *core.Column, table, oriTable *core.Table, renamed map[string]string) *core.Column {
+	names, ok := engine.fieldTagParams(t, col.FieldName, "renamed_from")
+	if !ok {
+		return nil
+	}
+	for _, name := range names {
+		if table.GetColumn(name) != nil || renamed[strings.ToLower(name)] != "" {
+			continue
+		}
+		for _, oriCol := range oriTable.Columns() {
+			if strings.EqualFold(oriCol.Name, name) {
+				return oriCol
+			}
+		}
+	}
+	return nil
+}
+
+// renameColumnSQL returns the statement to rename the column of the database
+func (engine *Engine) renameColumnSQL(tableName string, oriCol *core.Column, oriAttrs *ColumnSchema, newName string) string {
+	switch engine.dialect.DBType() {
+	case core.MYSQL:
+		// CHANGE COLUMN redefines the column as the database, the definition is
+		// altered by the change of the column then
+		return fmt.Sprintf("ALTER TABLE %s CHANGE COLUMN %s %s %s", engine.Quote(tableName),
+			engine.Quote(oriCol.Name), engine.Quote(newName), engine.mysqlColumnDefinition(oriCol, oriAttrs))
+	case core.MSSQL:
+		return fmt.Sprintf("EXEC sp_rename '%s.%s', '%s', 'COLUMN'", tableName, oriCol.Name, newName)
+	}
+	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", engine.Quote(tableName),
+		engine.Quote(oriCol.Name), engine.Quote(newName))
+}
+
+// dropColumnSQLs returns the statements to drop the column of the database, the
+// columns of SQLite are dropped by rebuilding the table
+func (engine *Engine) dropColumnSQLs(tableName string, col *core.Column) []string {
+	var sqls []string
+	switch engine.dialect.DBType() {
+	case core.SQLITE:
+		return nil
+	case core.MSSQL:
+		if !col.DefaultIsEmpty {
+			sqls = append(sqls, engine.mssqlDropDefaultSQL(tableName, col.Name))
+		}
+	}
+	return append(sqls, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", engine.Quote(tableName), engine.Quote(col.Name)))
+}
+
+// indexOnColumns returns true if the index is on one of the columns of the changes
+func indexOnColumns(index *core.Index, changes []*SyncChange) bool {
+	for _, colName := range index.Cols {
+		for _, change := range changes {
+			if strings.EqualFold(change.Column.Name, colName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// planCreateTable returns the change to create the table with its indexes
+func (session *Session) planCreateTable(table *core.Table, tbName, createName string) *SyncChange {
+	engine := session.engine
+	sqls := []string{engine.createTableSQL(table, createName, session.statement.StoreEngine,
+		session.statement.Charset, engine.tableSchemaOf(table, createName))}
+	for _, col := range table.Columns() {
+		if col.Comment != "" {
+			if sql := engine.columnCommentSQL(createName, col); sql != "" {
+				sqls = append(sqls, sql)
+			}
+		}
+	}
+	for _, tp := range []int{core.UniqueType, core.IndexType} {
+		for _, name := range sortedIndexNames(table.Indexes) {
+			if index := table.Indexes[name]; index.Type == tp {
+				sqls = append(sqls, engine.dialect.CreateIndexSql(createName, index))
+			}
+		}
+	}
+	return &SyncChange{
+		Type:  SyncCreateTable,
+		Table: tbName,
+		SQLs:  sqls,
+	}
+}
+
+// planIndexes returns the changes to drop the indexes which are not in the struct or
+// whose types are changed and then to add the indexes which are not in the database
+func (engine *Engine) planIndexes(tbName, tbNameWithSchema string, table, oriTable *core.Table) []*SyncChange {
+	var changes []*SyncChange
+	var foundIndexNames = make(map[string]bool)
+	var addedNames []string
+
+	for _, name := range sortedIndexNames(table.Indexes) {
+		index := table.Indexes[name]
+		var oriIndex *core.Index
+		for name2, index2 := range oriTable.Indexes {
+			if index.Equal(index2) {
+				oriIndex = index2
+				foundIndexNames[name2] = true
+				break
+			}
+		}
+
+		if oriIndex != nil && oriIndex.Type != index.Type {
+			changes = append(changes, &SyncChange{
+				Type:  SyncDropIndex,
+				Table: tbName,
+				Index: oriIndex,
+				SQLs:  []string{engine.dialect.DropIndexSql(tbNameWithSchema, oriIndex)},
+			})
+			oriIndex = nil
+		}
+
+		if oriIndex == nil {
+			addedNames = append(addedNames, name)
+		}
+	}
+
+	for _, name2 := range sortedIndexNames(oriTable.Indexes) {
+		if !foundIndexNames[name2] {
+			index2 := oriTable.Indexes[name2]
+			changes = append(changes, &SyncChange{
+				Type:  SyncDropIndex,
+				Table: tbName,
+				Index: index2,
+				SQLs:  []string{engine.dialect.DropIndexSql(tbNameWithSchema, index2)},
+			})
+		}
+	}
+
+	for _, name := range addedNames {
+		index := table.Indexes[name]
+		if index.Type != core.UniqueType && index.Type != core.IndexType {
+			continue
+		}
+		changes = append(changes, &SyncChange{
+			Type:  SyncAddIndex,
+			Table: tbName,
+			Index: index,
+			SQLs:  []string{engine.dialect.CreateIndexSql(tbNameWithSchema, index)},
+		})
+	}
+	return changes
+}
+
+func sortedIndexNames(indexes map[string]*core.Index) []string {
+	var names = make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applySyncPlan executes the statements of the changes and logs the changes which
+// cannot be applied
+func (session *Session) applySyncPlan(plan *SyncPlan) error {
+	engine := session.engine
+	for _, change := range plan.Changes {
+		if change.Warning != "" {
+			engine.logger.Warnf("Table %s %s: %s", change.Table, change, change.Warning)
+		}
+		if len(change.SQLs) > 0 && change.Type == SyncAlterColumn {
+			engine.logger.Infof("Table %s %s", change.Table, change)
+		}
+		if change.Type == SyncRebuildTable {
+			if err := session.rebuildTable(change); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, sql := range change.SQLs {
+			if _, err := session.exec(sql); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
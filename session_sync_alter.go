@@ -0,0 +1,575 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"xorm.io/core"
+)
+
+// the results of comparing the type of a column with the type of the database
+const (
+	typeSame = iota
+	typeWiden
+	typeNarrow
+	typeOther
+)
+
+var (
+	integerTypeRanks = map[string]int{
+		core.TinyInt:   1,
+		core.SmallInt:  2,
+		core.MediumInt: 3,
+		core.Int:       4,
+		core.Integer:   4,
+		core.Serial:    4,
+		core.BigInt:    5,
+		core.BigSerial: 5,
+	}
+	// the char types are ranked by their lengths
+	stringTypeRanks = map[string]int{
+		core.Char:     1,
+		core.Varchar:  1,
+		core.NChar:    1,
+		core.NVarchar: 1,
+		"VARCHAR2":    1,
+		"NVARCHAR2":   1,
+		core.TinyText: 2,
+		core.Text:     3,
+		core.NText:    3,
+		core.Clob:     3,
+		"NCLOB":       3,
+		"MEDIUMTEXT":  4,
+		core.LongText: 5,
+	}
+	floatTypeRanks = map[string]int{
+		core.Real:          1,
+		core.Float:         1,
+		core.Double:        2,
+		"DOUBLE PRECISION": 2,
+	}
+	decimalTypes = map[string]bool{
+		core.Decimal: true,
+		core.Numeric: true,
+		"NUMBER":     true,
+	}
+)
+
+// parseSQLType splits the type returned by Dialect.SqlType to the name and the lengths,
+// the length of VARCHAR(MAX) is -1
+func parseSQLType(tp string) (name string, len1, len2 int) {
+	name = strings.ToUpper(strings.TrimSpace(tp))
+	idx := strings.IndexByte(name, '(')
+	if idx < 0 {
+		return name, 0, 0
+	}
+	lens := strings.Split(strings.TrimSuffix(name[idx+1:], ")"), ",")
+	name = strings.TrimSpace(name[:idx])
+	if strings.TrimSpace(lens[0]) == "MAX" {
+		return name, -1, 0
+	}
+	len1, _ = strconv.Atoi(strings.TrimSpace(lens[0]))
+	if len(lens) > 1 {
+		len2, _ = strconv.Atoi(strings.TrimSpace(lens[1]))
+	}
+	return name, len1, len2
+}
+
+// compareRank compares two ranks of a type family
+func compareRank(expected, cur int) int {
+	if expected > cur {
+		return typeWiden
+	} else if expected < cur {
+		return typeNarrow
+	}
+	return typeSame
+}
+
+// compareSQLTypes tells whether the expected type is the same as, wider than or narrower
+// than the current type, the types are the results of Dialect.SqlType
+func compareSQLTypes(expectedType, curType string) int {
+	if expectedType == curType {
+		return typeSame
+	}
+	// e.g. INT and INT(11) on MySQL
+	if strings.HasPrefix(curType, expectedType) && curType[len(expectedType)] == '(' {
+		return typeSame
+	}
+
+	expected, expLen1, expLen2 := parseSQLType(expectedType)
+	cur, curLen1, curLen2 := parseSQLType(curType)
+
+	if expRank, ok := integerTypeRanks[expected]; ok {
+		if curRank, ok := integerTypeRanks[cur]; ok {
+			// the lengths of the integers are display widths
+			return compareRank(expRank, curRank)
+		}
+		return typeOther
+	}
+
+	if expRank, ok := stringTypeRanks[expected]; ok {
+		curRank, ok := stringTypeRanks[cur]
+		if !ok {
+			return typeOther
+		}
+		// VARCHAR(MAX) of MSSQL is a text type
+		if expLen1 < 0 {
+			expRank = stringTypeRanks[core.Text]
+		}
+		if curLen1 < 0 {
+			curRank = stringTypeRanks[core.Text]
+		}
+		if expRank != 1 || curRank != 1 {
+			if expRank == curRank && expected != cur {
+				return typeOther
+			}
+			return compareRank(expRank, curRank)
+		}
+		if (expected == core.Char) != (cur == core.Char) {
+			return typeOther
+		}
+		return compareRank(expLen1, curLen1)
+	}
+
+	if expRank, ok := floatTypeRanks[expected]; ok {
+		if curRank, ok := floatTypeRanks[cur]; ok {
+			return compareRank(expRank, curRank)
+		}
+		return typeOther
+	}
+
+	if decimalTypes[expected] && decimalTypes[cur] {
+		if expLen1 == curLen1 && expLen2 == curLen2 {
+			return typeSame
+		}
+		// both the scale and the digits before the decimal point should not decrease
+		if expLen2 >= curLen2 && expLen1-expLen2 >= curLen1-curLen2 {
+			return typeWiden
+		}
+		return typeNarrow
+	}
+	return typeOther
+}
+
+// dialectReadsComments tells whether the dialect reads the comments of the columns
+func dialectReadsComments(dbType core.DbType) bool {
+	switch dbType {
+	case core.MYSQL, core.POSTGRES, core.MSSQL, core.ORACLE:
+		return true
+	}
+	return false
+}
+
+// planAlterColumn compares the column of the struct with the column of the database
+// and returns nil if they are the same. The attributes are nil if the columns have
+// none, the generated columns are not altered and the collation is compared only if
+// the struct declares it.
+func (engine *Engine) planAlterColumn(tbName, tbNameWithSchema string, col, oriCol *core.Column, attrs, oriAttrs *ColumnSchema) *SyncChange {
+	var change = SyncChange{
+		Type:      SyncAlterColumn,
+		Table:     tbName,
+		Column:    col,
+		OldColumn: oriCol,
+		Attrs:     attrs,
+		OldAttrs:  oriAttrs,
+	}
+	dbType := engine.dialect.DBType()
+	if attrs == nil {
+		attrs = &ColumnSchema{}
+	}
+	if oriAttrs == nil {
+		oriAttrs = &ColumnSchema{}
+	}
+
+	// the expressions are not compared since the databases rewrite them
+	if attrs.Generated != "" || oriAttrs.Generated != "" {
+		if (attrs.Generated != "") == (oriAttrs.Generated != "") {
+			return nil
+		}
+		change.GeneratedChanged = true
+		change.Warning = "cannot change a column to or from a generated column"
+		return &change
+	}
+
+	var collation = oriAttrs.Collation
+	if attrs.Collation != "" && !strings.EqualFold(attrs.Collation, oriAttrs.Collation) {
+		change.CollationChanged = true
+		collation = attrs.Collation
+	}
+
+	// target is the column after the change, the parts which cannot be changed are
+	// kept as the database
+	var target = *col
+	var warnings []string
+
+	expectedType := engine.dialect.SqlType(col)
+	curType := engine.dialect.SqlType(oriCol)
+	switch compareSQLTypes(expectedType, curType) {
+	case typeSame:
+		target.SQLType, target.Length, target.Length2 = oriCol.SQLType, oriCol.Length, oriCol.Length2
+	case typeWiden:
+		change.TypeChanged = true
+		// Oracle cannot modify VARCHAR2 to CLOB
+		if dbType == core.ORACLE && !strings.EqualFold(strings.SplitN(expectedType, "(", 2)[0], strings.SplitN(curType, "(", 2)[0]) {
+			target.SQLType, target.Length, target.Length2 = oriCol.SQLType, oriCol.Length, oriCol.Length2
+			warnings = append(warnings, fmt.Sprintf("cannot change type from %s to %s", curType, expectedType))
+		}
+	case typeNarrow:
+		change.TypeChanged = true
+		change.Narrowing = true
+		target.SQLType, target.Length, target.Length2 = oriCol.SQLType, oriCol.Length, oriCol.Length2
+		warnings = append(warnings, fmt.Sprintf("db type is %s, struct type is %s which is narrower", curType, expectedType))
+	default:
+		change.TypeChanged = true
+		// the values are kept when the table of SQLite is rebuilt since its columns
+		// can store any type
+		if dbType != core.SQLITE {
+			target.SQLType, target.Length, target.Length2 = oriCol.SQLType, oriCol.Length, oriCol.Length2
+			warnings = append(warnings, fmt.Sprintf("db type is %s, struct type is %s", curType, expectedType))
+		}
+	}
+
+	if col.Default != oriCol.Default {
+		var isBool = col.SQLType.Name == core.Bool || col.SQLType.Name == core.Boolean
+		if normalizeDefault(col.Default, isBool) == normalizeDefault(oriCol.Default, isBool) {
+			// the databases rewrite the defaults, e.g. 'a'::text of Postgres and ((1)) of MSSQL
+			target.Default, target.DefaultIsEmpty = oriCol.Default, oriCol.DefaultIsEmpty
+		} else if oriCol.IsAutoIncrement || col.IsAutoIncrement {
+			// the default of the auto increment column is the sequence on some databases
+			target.Default, target.DefaultIsEmpty = oriCol.Default, oriCol.DefaultIsEmpty
+		} else {
+			change.DefaultChanged = true
+		}
+	}
+	if col.Nullable != oriCol.Nullable && !col.IsPrimaryKey && !oriCol.IsPrimaryKey {
+		change.NullableChanged = true
+	} else {
+		target.Nullable = oriCol.Nullable
+	}
+	if col.Comment != oriCol.Comment && dialectReadsComments(dbType) {
+		change.CommentChanged = true
+	} else {
+		target.Comment = oriCol.Comment
+	}
+
+	if !change.TypeChanged && !change.DefaultChanged && !change.NullableChanged && !change.CommentChanged &&
+		!change.CollationChanged {
+		return nil
+	}
+
+	var typeChanged = change.TypeChanged && len(warnings) == 0
+	// the NULLs are set to the default before the column is changed to NOT NULL, it's
+	// not changed if there is no default since the existing NULLs would fail it
+	var nullableChanged = change.NullableChanged
+	if nullableChanged && !target.Nullable && target.Default == "" {
+		switch dbType {
+		case core.MYSQL, core.POSTGRES, core.MSSQL, core.ORACLE:
+			nullableChanged = false
+			target.Nullable = oriCol.Nullable
+			warnings = append(warnings, "cannot change to NOT NULL without a default for the existing NULLs")
+		}
+	}
+	switch dbType {
+	case core.MYSQL:
+		if typeChanged || change.DefaultChanged || nullableChanged || change.CommentChanged || change.CollationChanged {
+			if nullableChanged && !target.Nullable {
+				change.SQLs = append(change.SQLs, engine.fillNullsSQL(tbNameWithSchema, &target))
+			}
+			change.SQLs = append(change.SQLs, engine.mysqlModifyColumnSQL(tbNameWithSchema, &target, collation))
+		}
+	case core.POSTGRES:
+		change.SQLs = engine.postgresAlterColumnSQLs(tbNameWithSchema, &target, collation, typeChanged, nullableChanged, &change)
+	case core.MSSQL:
+		change.SQLs = engine.mssqlAlterColumnSQLs(tbNameWithSchema, &target, oriCol, collation, typeChanged, nullableChanged, &change)
+	case core.ORACLE:
+		change.SQLs = engine.oracleAlterColumnSQLs(tbNameWithSchema, &target, collation, typeChanged, nullableChanged, &change)
+	case core.SQLITE:
+		if typeChanged || change.DefaultChanged || change.NullableChanged || change.CollationChanged {
+			change.target = &target
+		}
+	default:
+		if typeChanged || change.DefaultChanged || change.NullableChanged {
+			warnings = append(warnings, fmt.Sprintf("cannot alter column on %s", dbType))
+		}
+		if change.CollationChanged {
+			warnings = append(warnings, fmt.Sprintf("cannot change collation on %s", dbType))
+		}
+	}
+	change.Warning = strings.Join(warnings, ", ")
+	return &change
+}
+
+// normalizeDefault removes the parentheses and the casts of Postgres around the default
+// and unifies the current time functions and the booleans so that the default of the
+// struct could be compared with the one read from the database
+func normalizeDefault(def string, isBool bool) string {
+	def = strings.TrimSpace(def)
+	for {
+		trimmed := trimDefaultCast(trimDefaultParens(def))
+		if trimmed == def {
+			break
+		}
+		def = trimmed
+	}
+
+	if isBool {
+		switch strings.ToLower(strings.Trim(def, "'")) {
+		case "1", "true":
+			return "true"
+		case "0", "false":
+			return "false"
+		}
+	}
+	switch strings.ToLower(def) {
+	case "current_timestamp", "current_timestamp()", "now()", "getdate()", "systimestamp", "sysdate":
+		return "CURRENT_TIMESTAMP"
+	}
+	return def
+}
+
+// trimDefaultParens removes the parentheses which enclose the whole default
+func trimDefaultParens(def string) string {
+	if len(def) < 2 || def[0] != '(' || def[len(def)-1] != ')' {
+		return def
+	}
+	var depth int
+	var inQuote bool
+	for i := 0; i < len(def); i++ {
+		switch {
+		case def[i] == '\'':
+			inQuote = !inQuote
+		case inQuote:
+		case def[i] == '(':
+			depth++
+		case def[i] == ')':
+			depth--
+			if depth == 0 && i < len(def)-1 {
+				// e.g. (a) + (b)
+				return def
+			}
+		}
+	}
+	return strings.TrimSpace(def[1 : len(def)-1])
+}
+
+// trimDefaultCast removes the cast of Postgres at the end of the default, e.g. ::text
+// and ::character varying(20)
+func trimDefaultCast(def string) string {
+	var inQuote bool
+	var idx = -1
+	for i := 0; i < len(def)-1; i++ {
+		if def[i] == '\'' {
+			inQuote = !inQuote
+		} else if !inQuote && def[i] == ':' && def[i+1] == ':' {
+			idx = i
+			i++
+		}
+	}
+	if idx < 0 {
+		return def
+	}
+	for _, c := range def[idx+2:] {
+		if !(c == ' ' || c == '_' || c == '(' || c == ')' || c == ',' || c == '[' || c == ']' ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return def
+		}
+	}
+	return strings.TrimSpace(def[:idx])
+}
+
+// fillNullsSQL sets the NULLs of the column to its default before it's changed to NOT NULL
+func (engine *Engine) fillNullsSQL(tableName string, col *core.Column) string {
+	return fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NULL", engine.Quote(tableName),
+		engine.Quote(col.Name), col.Default, engine.Quote(col.Name))
+}
+
+// quoteComment returns the comment as a string literal
+func quoteComment(comment string) string {
+	return "'" + strings.Replace(comment, "'", "''", -1) + "'"
+}
+
+// mysqlModifyColumnSQL redefines the whole column since MODIFY COLUMN resets the
+// attributes which are not given
+func (engine *Engine) mysqlModifyColumnSQL(tableName string, col *core.Column, collation string) string {
+	return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", engine.Quote(tableName),
+		engine.Quote(col.Name), engine.mysqlColumnDefinition(col, &ColumnSchema{Collation: collation}))
+}
+
+// mysqlColumnDefinition returns the definition of the column after its name, the
+// attributes may be nil
+func (engine *Engine) mysqlColumnDefinition(col *core.Column, attrs *ColumnSchema) string {
+	sql := engine.dialect.SqlType(col)
+	if attrs != nil && attrs.Collation != "" {
+		sql += " COLLATE " + attrs.Collation
+	}
+	if attrs != nil && attrs.Generated != "" {
+		sql += " GENERATED ALWAYS AS (" + attrs.Generated + ")"
+		if attrs.Stored {
+			sql += " STORED"
+		} else {
+			sql += " VIRTUAL"
+		}
+	}
+	if col.Nullable {
+		sql += " NULL"
+	} else {
+		sql += " NOT NULL"
+	}
+	if col.Default != "" && (attrs == nil || attrs.Generated == "") {
+		sql += " DEFAULT " + col.Default
+	}
+	if col.IsAutoIncrement {
+		sql += " " + engine.dialect.AutoIncrStr()
+	}
+	if col.Comment != "" {
+		sql += " COMMENT " + quoteComment(col.Comment)
+	}
+	return sql
+}
+
+// postgresAlterColumnSQLs changes the type with the collation of the column since
+// the collation is reset to the default of the type otherwise
+func (engine *Engine) postgresAlterColumnSQLs(tableName string, col *core.Column, collation string, typeChanged, nullableChanged bool, change *SyncChange) []string {
+	var sqls []string
+	alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", engine.Quote(tableName), engine.Quote(col.Name))
+	if typeChanged {
+		tp := engine.dialect.SqlType(col)
+		// SERIAL and BIGSERIAL are not real types
+		switch tp {
+		case core.Serial:
+			tp = core.Integer
+		case core.BigSerial:
+			tp = core.BigInt
+		}
+		if collation != "" {
+			tp += ` COLLATE "` + collation + `"`
+		}
+		sqls = append(sqls, alter+"TYPE "+tp)
+	} else if change.CollationChanged {
+		sqls = append(sqls, alter+"TYPE "+engine.dialect.SqlType(col)+` COLLATE "`+collation+`"`)
+	}
+	if nullableChanged {
+		if col.Nullable {
+			sqls = append(sqls, alter+"DROP NOT NULL")
+		} else {
+			sqls = append(sqls, engine.fillNullsSQL(tableName, col), alter+"SET NOT NULL")
+		}
+	}
+	if change.DefaultChanged {
+		if col.Default != "" {
+			sqls = append(sqls, alter+"SET DEFAULT "+col.Default)
+		} else {
+			sqls = append(sqls, alter+"DROP DEFAULT")
+		}
+	}
+	if change.CommentChanged {
+		sqls = append(sqls, engine.columnCommentSQL(tableName, col))
+	}
+	return sqls
+}
+
+// mssqlAlterColumnSQLs drops the default constraint before changing the type or the
+// default since they cannot be changed while the constraint exists, the collation is
+// given since ALTER COLUMN resets it to the default of the database
+func (engine *Engine) mssqlAlterColumnSQLs(tableName string, col, oriCol *core.Column, collation string, typeChanged, nullableChanged bool, change *SyncChange) []string {
+	var sqls []string
+	var dropDefault = !oriCol.DefaultIsEmpty && (typeChanged || change.DefaultChanged)
+	if dropDefault {
+		sqls = append(sqls, engine.mssqlDropDefaultSQL(tableName, col.Name))
+	}
+	if nullableChanged && !col.Nullable {
+		sqls = append(sqls, engine.fillNullsSQL(tableName, col))
+	}
+	if typeChanged || nullableChanged || change.CollationChanged {
+		sql := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s", engine.Quote(tableName), engine.Quote(col.Name), engine.dialect.SqlType(col))
+		if collation != "" {
+			sql += " COLLATE " + collation
+		}
+		if col.Nullable {
+			sql += " NULL"
+		} else {
+			sql += " NOT NULL"
+		}
+		sqls = append(sqls, sql)
+	}
+	if (dropDefault || change.DefaultChanged) && col.Default != "" {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ADD DEFAULT %s FOR %s", engine.Quote(tableName), col.Default, engine.Quote(col.Name)))
+	}
+	if change.CommentChanged {
+		sqls = append(sqls, engine.columnCommentSQL(tableName, col))
+	}
+	return sqls
+}
+
+// mssqlDropDefaultSQL drops the default constraint of the column whose name is
+// generated by the database
+func (engine *Engine) mssqlDropDefaultSQL(tableName, colName string) string {
+	return fmt.Sprintf("DECLARE @name sysname; "+
+		"SELECT @name = d.name FROM sys.default_constraints d "+
+		"JOIN sys.columns c ON d.parent_object_id = c.object_id AND d.parent_column_id = c.column_id "+
+		"WHERE d.parent_object_id = OBJECT_ID('%s') AND c.name = '%s'; "+
+		"IF @name IS NOT NULL EXEC('ALTER TABLE %s DROP CONSTRAINT [' + @name + ']')",
+		tableName, colName, engine.Quote(tableName))
+}
+
+func (engine *Engine) oracleAlterColumnSQLs(tableName string, col *core.Column, collation string, typeChanged, nullableChanged bool, change *SyncChange) []string {
+	var sqls []string
+	modify := fmt.Sprintf("ALTER TABLE %s MODIFY (%s ", engine.Quote(tableName), engine.Quote(col.Name))
+	if typeChanged {
+		sqls = append(sqls, modify+engine.dialect.SqlType(col)+")")
+	}
+	if change.CollationChanged {
+		sqls = append(sqls, modify+"COLLATE "+collation+")")
+	}
+	if change.DefaultChanged {
+		if col.Default != "" {
+			sqls = append(sqls, modify+"DEFAULT "+col.Default+")")
+		} else {
+			sqls = append(sqls, modify+"DEFAULT NULL)")
+		}
+	}
+	// Oracle fails if the column is already NULL or NOT NULL
+	if nullableChanged {
+		if col.Nullable {
+			sqls = append(sqls, modify+"NULL)")
+		} else {
+			sqls = append(sqls, engine.fillNullsSQL(tableName, col), modify+"NOT NULL)")
+		}
+	}
+	if change.CommentChanged {
+		sqls = append(sqls, engine.columnCommentSQL(tableName, col))
+	}
+	return sqls
+}
+
+// columnCommentSQL returns the statement to set the comment of the column on the
+// dialects whose comments are not a part of the column definition
+func (engine *Engine) columnCommentSQL(tableName string, col *core.Column) string {
+	switch engine.dialect.DBType() {
+	case core.POSTGRES, core.ORACLE:
+		comment := "NULL"
+		if col.Comment != "" {
+			comment = quoteComment(col.Comment)
+		}
+		return fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", engine.Quote(tableName), engine.Quote(col.Name), comment)
+	case core.MSSQL:
+		var exists = fmt.Sprintf("EXISTS (SELECT 1 FROM sys.extended_properties WHERE major_id = OBJECT_ID('%s') "+
+			"AND minor_id = COLUMNPROPERTY(OBJECT_ID('%s'), '%s', 'ColumnId') AND name = 'MS_Description')",
+			tableName, tableName, col.Name)
+		var args = fmt.Sprintf("@level0type = N'SCHEMA', @level0name = @schema, @level1type = N'TABLE', @level1name = N'%s', "+
+			"@level2type = N'COLUMN', @level2name = N'%s'", tableName, col.Name)
+		var sql = "DECLARE @schema sysname = SCHEMA_NAME(); "
+		if col.Comment == "" {
+			return sql + fmt.Sprintf("IF %s EXEC sp_dropextendedproperty @name = N'MS_Description', %s", exists, args)
+		}
+		return sql + fmt.Sprintf("IF %s EXEC sp_updateextendedproperty @name = N'MS_Description', @value = N%s, %s "+
+			"ELSE EXEC sp_addextendedproperty @name = N'MS_Description', @value = N%s, %s",
+			exists, quoteComment(col.Comment), args, quoteComment(col.Comment), args)
+	}
+	return ""
+}
@@ -0,0 +1,205 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+func TestCompareSQLTypes(t *testing.T) {
+	var tests = []struct {
+		expected, cur string
+		result        int
+	}{
+		{"INT", "INT(11)", typeSame},
+		{"BIGINT(20)", "INT(11)", typeWiden},
+		{"SMALLINT", "INTEGER", typeNarrow},
+		{"VARCHAR(255)", "VARCHAR(100)", typeWiden},
+		{"VARCHAR(50)", "VARCHAR(100)", typeNarrow},
+		{"TEXT", "VARCHAR(255)", typeWiden},
+		{"VARCHAR(255)", "TEXT", typeNarrow},
+		{"VARCHAR(MAX)", "VARCHAR(255)", typeWiden},
+		{"CHAR(10)", "VARCHAR(10)", typeOther},
+		{"DOUBLE", "FLOAT", typeWiden},
+		{"DECIMAL(12,2)", "DECIMAL(10,2)", typeWiden},
+		{"DECIMAL(10,4)", "DECIMAL(10,2)", typeNarrow},
+		{"DATETIME", "INT", typeOther},
+	}
+	for _, test := range tests {
+		assert.EqualValues(t, test.result, compareSQLTypes(test.expected, test.cur), test.expected+" "+test.cur)
+	}
+}
+
+func TestPlanAlterColumn(t *testing.T) {
+	newColumn := func(tp string, length int, nullable bool, def string) *core.Column {
+		col := core.NewColumn("name", "Name", core.SQLType{Name: tp}, length, 0, nullable)
+		col.Default = def
+		return col
+	}
+
+	mysql, err := NewEngine("mysql", "root:@/xorm_test")
+	assert.NoError(t, err)
+	change := mysql.planAlterColumn("user", "user", newColumn(core.Varchar, 255, false, "''"), newColumn(core.Varchar, 100, true, ""), nil, nil)
+	if assert.NotNil(t, change) {
+		assert.True(t, change.TypeChanged)
+		assert.True(t, change.NullableChanged)
+		assert.True(t, change.DefaultChanged)
+		assert.EqualValues(t, []string{
+			"UPDATE `user` SET `name` = '' WHERE `name` IS NULL",
+			"ALTER TABLE `user` MODIFY COLUMN `name` VARCHAR(255) NOT NULL DEFAULT ''",
+		}, change.SQLs)
+	}
+
+	change = mysql.planAlterColumn("user", "user", newColumn(core.Varchar, 50, true, ""), newColumn(core.Varchar, 100, true, ""), nil, nil)
+	if assert.NotNil(t, change) {
+		assert.True(t, change.Narrowing)
+		assert.EqualValues(t, 0, len(change.SQLs))
+		assert.NotEmpty(t, change.Warning)
+	}
+	assert.Nil(t, mysql.planAlterColumn("user", "user", newColumn(core.Varchar, 100, true, ""), newColumn(core.Varchar, 100, true, ""), nil, nil))
+
+	postgres, err := NewEngine("postgres", "dbname=xorm_test sslmode=disable")
+	assert.NoError(t, err)
+	change = postgres.planAlterColumn("user", "user", newColumn(core.Text, 0, false, ""), newColumn(core.Varchar, 100, true, "'a'"), nil, nil)
+	if assert.NotNil(t, change) {
+		// the existing NULLs would fail NOT NULL without a default
+		assert.True(t, change.NullableChanged)
+		assert.EqualValues(t, []string{
+			`ALTER TABLE "user" ALTER COLUMN "name" TYPE TEXT`,
+			`ALTER TABLE "user" ALTER COLUMN "name" DROP DEFAULT`,
+		}, change.SQLs)
+		assert.NotEmpty(t, change.Warning)
+	}
+
+	change = postgres.planAlterColumn("user", "user", newColumn(core.Text, 0, false, "'a'"), newColumn(core.Text, 0, true, "'a'"), nil, nil)
+	if assert.NotNil(t, change) {
+		assert.EqualValues(t, []string{
+			`UPDATE "user" SET "name" = 'a' WHERE "name" IS NULL`,
+			`ALTER TABLE "user" ALTER COLUMN "name" SET NOT NULL`,
+		}, change.SQLs)
+		assert.Empty(t, change.Warning)
+	}
+
+	mssql, err := NewEngine("mssql", "server=localhost;database=xorm_test")
+	assert.NoError(t, err)
+	change = mssql.planAlterColumn("user", "user", newColumn(core.Int, 0, false, "0"), newColumn(core.Int, 0, true, "0"), nil, nil)
+	if assert.NotNil(t, change) {
+		assert.EqualValues(t, []string{
+			`UPDATE "user" SET "name" = 0 WHERE "name" IS NULL`,
+			`ALTER TABLE "user" ALTER COLUMN "name" INT NOT NULL`,
+		}, change.SQLs)
+		assert.Empty(t, change.Warning)
+	}
+
+	sqlite, err := NewEngine("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	change = sqlite.planAlterColumn("user", "user", newColumn(core.Varchar, 255, false, ""), newColumn(core.Varchar, 100, true, ""), nil, nil)
+	if assert.NotNil(t, change) {
+		// the table is rebuilt instead
+		assert.EqualValues(t, 0, len(change.SQLs))
+		assert.Empty(t, change.Warning)
+		assert.NotNil(t, change.target)
+	}
+}
+
+func TestNormalizeDefault(t *testing.T) {
+	var tests = []struct {
+		def    string
+		isBool bool
+		result string
+	}{
+		{"'a'::text", false, "'a'"},
+		{"'a''b'::character varying(20)", false, "'a''b'"},
+		{"(0)::numeric", false, "0"},
+		{"('a')", false, "'a'"},
+		{"((1))", true, "true"},
+		{"'0'", true, "false"},
+		{"(getdate())", false, "CURRENT_TIMESTAMP"},
+		{"now()", false, "CURRENT_TIMESTAMP"},
+		{"(a) + (b)", false, "(a) + (b)"},
+		{"'a::b'", false, "'a::b'"},
+	}
+	for _, test := range tests {
+		assert.EqualValues(t, test.result, normalizeDefault(test.def, test.isBool), test.def)
+	}
+}
+
+func TestPlanAlterColumnDefaults(t *testing.T) {
+	newColumn := func(tp string, def string) *core.Column {
+		col := core.NewColumn("name", "Name", core.SQLType{Name: tp}, 0, 0, true)
+		col.Default = def
+		return col
+	}
+
+	// the defaults of the struct and the ones read from the databases
+	var tests = []struct {
+		driver, dsn string
+		text        string
+		time        string
+		bool        string
+	}{
+		{"mysql", "root:@/xorm_test", "'a'", "CURRENT_TIMESTAMP", "1"},
+		{"postgres", "dbname=xorm_test sslmode=disable", "'a'::text", "now()", "true"},
+		{"mssql", "server=localhost;database=xorm_test", "('a')", "(getdate())", "((1))"},
+		{"sqlite3", ":memory:", "'a'", "CURRENT_TIMESTAMP", "1"},
+	}
+	for _, test := range tests {
+		engine, err := NewEngine(test.driver, test.dsn)
+		assert.NoError(t, err)
+		assert.Nil(t, engine.planAlterColumn("user", "user", newColumn(core.Text, "'a'"), newColumn(core.Text, test.text), nil, nil), test.driver)
+		assert.Nil(t, engine.planAlterColumn("user", "user", newColumn(core.DateTime, "CURRENT_TIMESTAMP"), newColumn(core.DateTime, test.time), nil, nil), test.driver)
+		assert.Nil(t, engine.planAlterColumn("user", "user", newColumn(core.Bool, "true"), newColumn(core.Bool, test.bool), nil, nil), test.driver)
+		assert.NotNil(t, engine.planAlterColumn("user", "user", newColumn(core.Text, "'b'"), newColumn(core.Text, test.text), nil, nil), test.driver)
+	}
+}
+
+type SyncDefault struct {
+	Id      int64
+	Name    string    `xorm:"varchar(20) default 'a'"`
+	Created time.Time `xorm:"default CURRENT_TIMESTAMP"`
+	Active  bool      `xorm:"default true"`
+}
+
+func TestSyncDefaults(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.DropTables(new(SyncDefault)))
+	assert.NoError(t, testEngine.Sync2(new(SyncDefault)))
+
+	plan, err := testEngine.(*Engine).PlanSync(new(SyncDefault))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(plan.Changes))
+}
+
+func TestSyncStrict(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.DropTables("sync_plan"))
+	assert.NoError(t, testEngine.Sync2(new(SyncPlanV2)))
+
+	type SyncPlanNarrow struct {
+		Id    int64
+		Name  string `xorm:"varchar(50)"`
+		Title string `xorm:"varchar(20) unique"`
+		Email string `xorm:"varchar(100)"`
+	}
+	engine := testEngine.(*Engine)
+	plan, err := engine.Table("sync_plan").PlanSync(new(SyncPlanNarrow))
+	assert.NoError(t, err)
+	if engine.dialect.DBType() == core.SQLITE {
+		// the types of sqlite have no lengths
+		assert.EqualValues(t, 0, len(plan.Changes))
+		return
+	}
+	if assert.EqualValues(t, 1, len(plan.Changes)) {
+		assert.True(t, plan.Changes[0].Narrowing)
+	}
+	err = engine.Table("sync_plan").SyncWithOptions(SyncOptions{Strict: true}, new(SyncPlanNarrow))
+	_, ok := err.(ErrColumnNarrowing)
+	assert.True(t, ok)
+	assert.NoError(t, engine.Table("sync_plan").Sync2(new(SyncPlanNarrow)))
+}
@@ -0,0 +1,311 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"xorm.io/core"
+)
+
+// rebuildTablePrefix is the prefix of the new table when rebuilding a table of SQLite
+const rebuildTablePrefix = "xorm_rebuild_"
+
+// planRebuildTable returns the change to rebuild the table of SQLite if some of the
+// changes cannot be applied by ALTER TABLE, the table is rebuilt as the procedure of
+// https://www.sqlite.org/lang_altertable.html#otheralter:
+//
+//	CREATE TABLE the new table
+//	INSERT INTO the new table SELECT FROM the old table
+//	DROP TABLE the old table
+//	ALTER TABLE the new table RENAME TO the old table
+//	CREATE the indexes and triggers of the old table
+//
+// The columns, the foreign keys and the CHECK constraints to add are a part of the
+// new table then and their changes have no statements, the columns to drop and their
+// indexes and constraints are left out. The columns to rename keep their old names in
+// the new table and are renamed after rebuilding. The generated columns are not
+// copied. It returns nil if the table needs not to be rebuilt.
+func (session *Session) planRebuildTable(tbName, tbNameWithSchema string, oriTable *core.Table, dbSchema *TableSchema, changes []*SyncChange) (*SyncChange, error) {
+	engine := session.engine
+	if engine.dialect.DBType() != core.SQLITE {
+		return nil, nil
+	}
+
+	var targets = make(map[string]*core.Column)
+	var dropped = make(map[string]bool)
+	var collations = make(map[string]string)
+	var oldNames = make(map[string]string)
+	var addedColumns, addedFKs, addedChecks []*SyncChange
+	for _, change := range changes {
+		switch change.Type {
+		case SyncAlterColumn:
+			if change.target != nil {
+				targets[strings.ToLower(change.OldColumn.Name)] = change.target
+			}
+			if change.CollationChanged {
+				collations[strings.ToLower(change.OldColumn.Name)] = change.Attrs.Collation
+			}
+		case SyncAddColumn:
+			// the columns which cannot be added by ALTER TABLE have no statements
+			if len(change.SQLs) == 0 {
+				addedColumns = append(addedColumns, change)
+			}
+		case SyncDropColumn:
+			if change.Warning == "" {
+				dropped[strings.ToLower(change.Column.Name)] = true
+			}
+		case SyncRenameColumn:
+			oldNames[strings.ToLower(change.Column.Name)] = change.OldColumn.Name
+		case SyncAddForeignKey:
+			addedFKs = append(addedFKs, change)
+		case SyncAddCheck:
+			addedChecks = append(addedChecks, change)
+		}
+	}
+	if len(targets) == 0 && len(dropped) == 0 && len(addedColumns) == 0 && len(addedFKs) == 0 && len(addedChecks) == 0 {
+		return nil, nil
+	}
+
+	var newName = rebuildTablePrefix + tbNameWithSchema
+	var newTable = core.NewEmptyTable()
+	newTable.Name = newName
+	var schema = TableSchema{Columns: make(map[string]*ColumnSchema)}
+	var cols, selects []string
+	for _, oriCol := range oriTable.Columns() {
+		if dropped[strings.ToLower(oriCol.Name)] {
+			continue
+		}
+		var col = oriCol
+		if target, ok := targets[strings.ToLower(oriCol.Name)]; ok {
+			renamedBack := *target
+			renamedBack.Name = oriCol.Name
+			col = &renamedBack
+		}
+		newTable.AddColumn(col)
+
+		var attrs ColumnSchema
+		if dbAttrs := dbSchema.Column(oriCol.Name); dbAttrs != nil {
+			attrs = *dbAttrs
+		}
+		if collation, ok := collations[strings.ToLower(oriCol.Name)]; ok {
+			attrs.Collation = collation
+		}
+		if attrs != (ColumnSchema{}) {
+			schema.Columns[oriCol.Name] = &attrs
+		}
+		if attrs.Generated != "" {
+			continue
+		}
+
+		cols = append(cols, engine.Quote(col.Name))
+		// the NULLs are replaced by the default if the column becomes NOT NULL
+		if oriCol.Nullable && !col.Nullable && col.Default != "" {
+			selects = append(selects, fmt.Sprintf("COALESCE(%s, %s)", engine.Quote(oriCol.Name), col.Default))
+		} else {
+			selects = append(selects, engine.Quote(oriCol.Name))
+		}
+	}
+	for _, change := range changes {
+		if change.Type == SyncAddColumn {
+			newTable.AddColumn(change.Column)
+			if change.Attrs != nil {
+				schema.Columns[change.Column.Name] = change.Attrs
+			}
+			change.SQLs = nil
+		}
+	}
+
+	for _, fk := range dbSchema.ForeignKeys {
+		var onDropped bool
+		for _, col := range fk.Cols {
+			onDropped = onDropped || dropped[strings.ToLower(col)]
+		}
+		if !onDropped {
+			schema.ForeignKeys = append(schema.ForeignKeys, fk)
+		}
+	}
+	for _, change := range addedFKs {
+		var fk = *change.ForeignKey
+		fk.Cols = make([]string, len(change.ForeignKey.Cols))
+		for i, col := range change.ForeignKey.Cols {
+			if oldName, ok := oldNames[strings.ToLower(col)]; ok {
+				col = oldName
+			}
+			fk.Cols[i] = col
+		}
+		schema.ForeignKeys = append(schema.ForeignKeys, &fk)
+		change.SQLs = nil
+	}
+
+	// the CHECK constraints declared on the dropped columns are left out by their names
+	for _, check := range dbSchema.Checks {
+		var onDropped bool
+		for colName := range dropped {
+			onDropped = onDropped || strings.EqualFold(check.Name, checkName(tbName, colName))
+		}
+		if !onDropped {
+			schema.Checks = append(schema.Checks, check)
+		}
+	}
+	for _, change := range addedChecks {
+		schema.Checks = append(schema.Checks, change.Check)
+		change.SQLs = nil
+	}
+
+	var sqls = []string{
+		engine.createTableSQL(newTable, newName, "", "", &schema),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", engine.Quote(newName),
+			strings.Join(cols, ", "), strings.Join(selects, ", "), engine.Quote(tbNameWithSchema)),
+		fmt.Sprintf("DROP TABLE %s", engine.Quote(tbNameWithSchema)),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", engine.Quote(newName), engine.Quote(tbNameWithSchema)),
+	}
+
+	// the indexes and triggers are dropped with the old table, the automatic indexes
+	// of the constraints have no sql
+	rows, err := session.queryRows("SELECT type, name, sql FROM sqlite_master WHERE tbl_name = ? AND type IN ('index', 'trigger') AND sql IS NOT NULL ORDER BY type, name", tbNameWithSchema)
+	if err != nil {
+		return nil, err
+	}
+	var objects [][3]string
+	for rows.Next() {
+		var object [3]string
+		if err := rows.Scan(&object[0], &object[1], &object[2]); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, object := range objects {
+		// the indexes on the dropped columns are not created again
+		if object[0] == "index" && len(dropped) > 0 {
+			onDropped, err := session.sqliteIndexOnColumns(object[1], dropped)
+			if err != nil {
+				return nil, err
+			}
+			if onDropped {
+				continue
+			}
+		}
+		sqls = append(sqls, object[2])
+	}
+
+	return &SyncChange{
+		Type:  SyncRebuildTable,
+		Table: tbName,
+		SQLs:  sqls,
+	}, nil
+}
+
+// sqliteIndexOnColumns returns true if the index is on one of the columns
+func (session *Session) sqliteIndexOnColumns(indexName string, columns map[string]bool) (bool, error) {
+	rows, err := session.queryRows(fmt.Sprintf("PRAGMA index_info(%s)", session.engine.Quote(indexName)))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seqno, cid int
+		var name sql.NullString
+		if err := rows.Scan(&seqno, &cid, &name); err != nil {
+			return false, err
+		}
+		if columns[strings.ToLower(name.String)] {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// rebuildTable executes the statements of rebuilding the table in a transaction with
+// foreign keys disabled and checks the foreign keys before committing. The foreign
+// keys cannot be disabled in a transaction, so the table is not rebuilt if the session
+// is in a transaction with foreign keys on, since dropping the old table deletes the
+// rows of the child tables with ON DELETE CASCADE.
+func (session *Session) rebuildTable(change *SyncChange) (err error) {
+	engine := session.engine
+	ctx := session.ctx
+
+	var exec = func(tx *sql.Tx, query string) error {
+		engine.logSQL(query)
+		_, err := tx.ExecContext(ctx, query)
+		return err
+	}
+	var rebuild = func(tx *sql.Tx) error {
+		for _, query := range change.SQLs {
+			if err := exec(tx, query); err != nil {
+				return err
+			}
+		}
+		query := fmt.Sprintf("PRAGMA foreign_key_check(%s)", engine.Quote(change.Table))
+		engine.logSQL(query)
+		rows, err := tx.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		if rows.Next() {
+			return fmt.Errorf("rebuild table %s: foreign key constraints are violated", change.Table)
+		}
+		return rows.Err()
+	}
+
+	if !session.isAutoCommit {
+		var foreignKeys bool
+		if err := session.tx.Tx.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+			return err
+		}
+		if foreignKeys {
+			return fmt.Errorf("rebuild table %s: cannot rebuild the table in a transaction with foreign keys on, please run Sync2 outside the transaction", change.Table)
+		}
+		return rebuild(session.tx.Tx)
+	}
+
+	// PRAGMA foreign_keys applies to the connection
+	conn, err := session.DB().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var foreignKeys bool
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return err
+	}
+	if foreignKeys {
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			return err
+		}
+		defer func() {
+			// the session's context may be canceled already
+			if _, onErr := conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON"); onErr != nil {
+				// the connection without foreign keys should not be put back to the pool
+				conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+				if err == nil {
+					err = onErr
+				}
+			}
+		}()
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := rebuild(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
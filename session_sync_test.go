@@ -0,0 +1,265 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+type SyncPlanV1 struct {
+	Id    int64
+	Name  string `xorm:"varchar(50) index"`
+	Title string `xorm:"varchar(50)"`
+}
+
+func (SyncPlanV1) TableName() string {
+	return "sync_plan"
+}
+
+type SyncPlanV2 struct {
+	Id    int64
+	Name  string `xorm:"varchar(50)"`
+	Title string `xorm:"varchar(50) unique"`
+	Email string `xorm:"varchar(100)"`
+}
+
+func (SyncPlanV2) TableName() string {
+	return "sync_plan"
+}
+
+func TestPlanSync(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.DropTables("sync_plan"))
+
+	engine := testEngine.(*Engine)
+	plan, err := engine.PlanSync(new(SyncPlanV1))
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 1, len(plan.Changes)) {
+		assert.EqualValues(t, SyncCreateTable, plan.Changes[0].Type)
+		assert.EqualValues(t, 2, len(plan.SQLs()))
+	}
+	exist, err := engine.IsTableExist("sync_plan")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	assert.NoError(t, engine.Sync2(new(SyncPlanV1)))
+	plan, err = engine.PlanSync(new(SyncPlanV1))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(plan.Changes))
+
+	plan, err = engine.PlanSync(new(SyncPlanV2))
+	assert.NoError(t, err)
+	var types []SyncChangeType
+	for _, change := range plan.Changes {
+		types = append(types, change.Type)
+	}
+	assert.EqualValues(t, []SyncChangeType{SyncAddColumn, SyncDropIndex, SyncAddIndex}, types)
+	assert.EqualValues(t, "email", plan.Changes[0].Column.Name)
+	assert.EqualValues(t, []string{"name"}, plan.Changes[1].Index.Cols)
+	assert.EqualValues(t, []string{"title"}, plan.Changes[2].Index.Cols)
+	assert.EqualValues(t, 0, len(plan.Unapplied()))
+
+	assert.NoError(t, engine.Sync2(new(SyncPlanV2)))
+	plan, err = engine.PlanSync(new(SyncPlanV2))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(plan.Changes))
+
+	plan, err = engine.PlanSync(new(SyncPlanV1))
+	assert.NoError(t, err)
+	if assert.True(t, len(plan.Changes) > 0) {
+		last := plan.Changes[len(plan.Changes)-1]
+		assert.EqualValues(t, SyncDropColumn, last.Type)
+		assert.EqualValues(t, "email", last.Column.Name)
+		assert.EqualValues(t, 0, len(last.SQLs))
+	}
+}
+
+type SyncRebuild struct {
+	Id    int64
+	Name  string `xorm:"varchar(50) not null default 'none' index"`
+	Score int    `xorm:"default 1"`
+}
+
+func TestSyncRebuildSQLite(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	if engine.dialect.DBType() != core.SQLITE {
+		t.Skip("only sqlite rebuilds the tables")
+	}
+	assert.NoError(t, engine.DropTables("sync_rebuild"))
+
+	_, err := engine.Exec("CREATE TABLE `sync_rebuild` (`id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL, `name` TEXT NULL, `score` INTEGER NULL)")
+	assert.NoError(t, err)
+	_, err = engine.Exec("CREATE INDEX `IDX_sync_rebuild_name` ON `sync_rebuild` (`name`)")
+	assert.NoError(t, err)
+	_, err = engine.Exec("INSERT INTO `sync_rebuild` (`name`, `score`) VALUES ('a', 2), (NULL, 3)")
+	assert.NoError(t, err)
+
+	plan, err := engine.PlanSync(new(SyncRebuild))
+	assert.NoError(t, err)
+	var rebuild *SyncChange
+	for _, change := range plan.Changes {
+		if change.Type == SyncRebuildTable {
+			rebuild = change
+		}
+	}
+	if assert.NotNil(t, rebuild) {
+		assert.EqualValues(t, 5, len(rebuild.SQLs))
+	}
+	assert.EqualValues(t, 0, len(plan.Unapplied()))
+
+	assert.NoError(t, engine.Sync2(new(SyncRebuild)))
+
+	var records []SyncRebuild
+	assert.NoError(t, engine.Asc("id").Find(&records))
+	if assert.EqualValues(t, 2, len(records)) {
+		assert.EqualValues(t, "a", records[0].Name)
+		assert.EqualValues(t, 2, records[0].Score)
+		assert.EqualValues(t, "none", records[1].Name)
+	}
+
+	plan, err = engine.PlanSync(new(SyncRebuild))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(plan.Changes))
+
+	_, err = engine.Insert(&SyncRebuild{Name: "b", Score: 4})
+	assert.NoError(t, err)
+	tables, err := engine.DBMetas()
+	assert.NoError(t, err)
+	for _, table := range tables {
+		if table.Name == "sync_rebuild" {
+			assert.EqualValues(t, 1, len(table.Indexes))
+			assert.False(t, table.GetColumn("name").Nullable)
+		}
+	}
+}
+
+type SyncFkParent struct {
+	Id   int64
+	Name string `xorm:"varchar(50) not null default 'none'"`
+}
+
+func TestSyncRebuildSQLiteInTx(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xorm_sync")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	engine, err := NewEngine("sqlite3", filepath.Join(dir, "fk.db")+"?_foreign_keys=1")
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	_, err = engine.Exec("CREATE TABLE `sync_fk_parent` (`id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL, `name` TEXT NULL)")
+	assert.NoError(t, err)
+	_, err = engine.Exec("CREATE TABLE `sync_fk_child` (`id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL, " +
+		"`parent_id` INTEGER REFERENCES `sync_fk_parent` (`id`) ON DELETE CASCADE)")
+	assert.NoError(t, err)
+	_, err = engine.Exec("INSERT INTO `sync_fk_parent` (`name`) VALUES ('a')")
+	assert.NoError(t, err)
+	_, err = engine.Exec("INSERT INTO `sync_fk_child` (`parent_id`) VALUES (1)")
+	assert.NoError(t, err)
+
+	var countChildren = func() int64 {
+		cnt, err := engine.Table("sync_fk_child").Count()
+		assert.NoError(t, err)
+		return cnt
+	}
+
+	// dropping the parent table in the transaction would delete the child rows
+	session := engine.NewSession()
+	defer session.Close()
+	assert.NoError(t, session.Begin())
+	err = session.Sync2(new(SyncFkParent))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "outside the transaction")
+	}
+	assert.NoError(t, session.Rollback())
+	assert.EqualValues(t, 1, countChildren())
+
+	assert.NoError(t, engine.Sync2(new(SyncFkParent)))
+	assert.EqualValues(t, 1, countChildren())
+	plan, err := engine.PlanSync(new(SyncFkParent))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(plan.Changes))
+}
+
+type SyncRenameV1 struct {
+	Id      int64
+	Name    string `xorm:"varchar(50) index"`
+	Obsoled string `xorm:"varchar(20) index"`
+}
+
+func (SyncRenameV1) TableName() string {
+	return "sync_rename"
+}
+
+type SyncRenameV2 struct {
+	Id       int64
+	Nickname string `xorm:"varchar(50) index renamed_from(name)"`
+}
+
+func (SyncRenameV2) TableName() string {
+	return "sync_rename"
+}
+
+func TestSyncRenameAndDropColumns(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	assert.NoError(t, engine.DropTables("sync_rename"))
+	assertSync(t, new(SyncRenameV1))
+
+	_, err := engine.Insert(&SyncRenameV1{Name: "a", Obsoled: "b"})
+	assert.NoError(t, err)
+
+	plan, err := engine.PlanSync(new(SyncRenameV2))
+	assert.NoError(t, err)
+	var types = make(map[SyncChangeType]int)
+	for _, change := range plan.Changes {
+		types[change.Type]++
+	}
+	assert.EqualValues(t, 1, types[SyncRenameColumn])
+	assert.EqualValues(t, 0, types[SyncAddColumn])
+	if assert.EqualValues(t, 1, len(plan.Unapplied())) {
+		assert.EqualValues(t, "obsoled", plan.Unapplied()[0].Column.Name)
+	}
+
+	assert.NoError(t, engine.Sync2(new(SyncRenameV2)))
+
+	opts := SyncOptions{DropColumns: true}
+	plan, err = engine.PlanSyncWithOptions(opts, new(SyncRenameV2))
+	assert.NoError(t, err)
+	types = make(map[SyncChangeType]int)
+	for _, change := range plan.Changes {
+		types[change.Type]++
+	}
+	assert.EqualValues(t, 1, types[SyncDropColumn])
+	assert.EqualValues(t, 0, types[SyncRenameColumn])
+	assert.EqualValues(t, 0, len(plan.Unapplied()))
+	assert.NoError(t, engine.SyncWithOptions(opts, new(SyncRenameV2)))
+
+	var records []SyncRenameV2
+	assert.NoError(t, engine.Find(&records))
+	if assert.EqualValues(t, 1, len(records)) {
+		assert.EqualValues(t, "a", records[0].Nickname)
+	}
+
+	tables, err := engine.DBMetas()
+	assert.NoError(t, err)
+	for _, table := range tables {
+		if table.Name == "sync_rename" {
+			assert.EqualValues(t, []string{"id", "nickname"}, table.ColumnsSeq())
+			assert.EqualValues(t, 1, len(table.Indexes))
+		}
+	}
+
+	plan, err = engine.PlanSyncWithOptions(opts, new(SyncRenameV2))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(plan.Changes))
+}
@@ -4,10 +4,58 @@
 
 package xorm
 
-// Begin a transaction
+import (
+	"database/sql"
+	"fmt"
+
+	"xorm.io/core"
+)
+
+// savepoint records a nested transaction and the after processors which
+// should be restored when rollback to it
+type savepoint struct {
+	name             string
+	afterInsertBeans map[interface{}]*[]func(interface{})
+	afterUpdateBeans map[interface{}]*[]func(interface{})
+	afterDeleteBeans map[interface{}]*[]func(interface{})
+
+	commitCallbacks   int
+	rollbackCallbacks int
+}
+
+// Begin a transaction, if the session is in a transaction already, a savepoint
+// will be created as a nested transaction which could be committed or rollback
+// separately.
 func (session *Session) Begin() error {
+	return session.BeginTx(nil)
+}
+
+// BeginTx begins a transaction with the options such as isolation level and read-only,
+// the options will be ignored if it's a nested transaction.
+func (session *Session) BeginTx(opts *sql.TxOptions) error {
+	if parent := session.txParent(); parent != nil {
+		defer session.syncTx(parent)
+		return parent.BeginTx(opts)
+	}
+	if !session.isAutoCommit && !session.isCommitedOrRollbacked {
+		sp := &savepoint{
+			name:             fmt.Sprintf("xorm_savepoint_%d", len(session.savepoints)+1),
+			afterInsertBeans: copyAfterBeans(session.afterInsertBeans),
+			afterUpdateBeans: copyAfterBeans(session.afterUpdateBeans),
+			afterDeleteBeans: copyAfterBeans(session.afterDeleteBeans),
+
+			commitCallbacks:   len(session.commitCallbacks),
+			rollbackCallbacks: len(session.rollbackCallbacks),
+		}
+		if err := session.execSavepoint(session.savepointSQL("SAVEPOINT", sp.name)); err != nil {
+			return err
+		}
+		session.savepoints = append(session.savepoints, sp)
+		return nil
+	}
+
 	if session.isAutoCommit {
-		tx, err := session.DB().BeginTx(session.ctx, nil)
+		tx, err := session.DB().BeginTx(session.ctx, opts)
 		if err != nil {
 			return err
 		}
@@ -19,25 +67,63 @@ func (session *Session) Begin() error {
 	return nil
 }
 
-// Rollback When using transaction, you can rollback if any error
+// Rollback When using transaction, you can rollback if any error. If it's in a nested
+// transaction, only the operations after the last savepoint will be rollback.
 func (session *Session) Rollback() error {
+	if parent := session.txParent(); parent != nil {
+		defer session.syncTx(parent)
+		return parent.Rollback()
+	}
 	if !session.isAutoCommit && !session.isCommitedOrRollbacked {
+		if sp := session.popSavepoint(); sp != nil {
+			session.afterInsertBeans = sp.afterInsertBeans
+			session.afterUpdateBeans = sp.afterUpdateBeans
+			session.afterDeleteBeans = sp.afterDeleteBeans
+			if err := session.execSavepoint(session.savepointSQL("ROLLBACK", sp.name)); err != nil {
+				return err
+			}
+
+			// the callbacks registered after the savepoint are rollback too
+			rollbackCallbacks := append([]func(){}, session.rollbackCallbacks[sp.rollbackCallbacks:]...)
+			session.commitCallbacks = session.commitCallbacks[:sp.commitCallbacks]
+			session.rollbackCallbacks = session.rollbackCallbacks[:sp.rollbackCallbacks]
+			callTxCallbacks(rollbackCallbacks)
+			return nil
+		}
+
 		session.saveLastSQL(session.engine.dialect.RollBackStr())
 		session.isCommitedOrRollbacked = true
 		session.isAutoCommit = true
-		return session.tx.Rollback()
+		err := session.tx.Rollback()
+		session.endTxCallbacks(false)
+		return err
 	}
 	return nil
 }
 
-// Commit When using transaction, Commit will commit all operations.
+// Commit When using transaction, Commit will commit all operations. If it's in a nested
+// transaction, the last savepoint will be released and the after processors will be
+// handled when the outermost transaction committed.
 func (session *Session) Commit() error {
+	if parent := session.txParent(); parent != nil {
+		defer session.syncTx(parent)
+		return parent.Commit()
+	}
 	if !session.isAutoCommit && !session.isCommitedOrRollbacked {
+		if sp := session.popSavepoint(); sp != nil {
+			if sqlStr := session.savepointSQL("RELEASE", sp.name); sqlStr != "" {
+				return session.execSavepoint(sqlStr)
+			}
+			return nil
+		}
+
 		session.saveLastSQL("COMMIT")
 		session.isCommitedOrRollbacked = true
 		session.isAutoCommit = true
 		var err error
-		if err = session.tx.Commit(); err == nil {
+		if err = session.tx.Commit(); err != nil {
+			session.endTxCallbacks(false)
+		} else {
 			// handle processors after tx committed
 			closureCallFunc := func(closuresPtr *[]func(interface{}), bean interface{}) {
 				if closuresPtr != nil {
@@ -76,8 +162,130 @@ func (session *Session) Commit() error {
 			cleanUpFunc(&session.afterInsertBeans)
 			cleanUpFunc(&session.afterUpdateBeans)
 			cleanUpFunc(&session.afterDeleteBeans)
+
+			session.endTxCallbacks(true)
 		}
 		return err
 	}
 	return nil
 }
+
+// OnCommit registers a function which will be called after the transaction committed,
+// the functions are called in the order they are registered. It will be called at once
+// if the session is not in a transaction.
+func (session *Session) OnCommit(f func()) *Session {
+	if parent := session.txParent(); parent != nil {
+		parent.OnCommit(f)
+		return session
+	}
+	if session.isAutoCommit {
+		f()
+		return session
+	}
+	session.commitCallbacks = append(session.commitCallbacks, f)
+	return session
+}
+
+// OnRollback registers a function which will be called after the transaction rollback
+// or failed to commit, the functions are called in the order they are registered. If it's
+// registered in a nested transaction, it will be called when rollback to the savepoint.
+// It will be ignored if the session is not in a transaction.
+func (session *Session) OnRollback(f func()) *Session {
+	if parent := session.txParent(); parent != nil {
+		parent.OnRollback(f)
+		return session
+	}
+	if !session.isAutoCommit {
+		session.rollbackCallbacks = append(session.rollbackCallbacks, f)
+	}
+	return session
+}
+
+// txParent returns the session which the hook session is created from if it's in the
+// transaction shared with the hook session
+func (session *Session) txParent() *Session {
+	if session.parent == nil || session.parent.isAutoCommit || session.parent.tx != session.tx {
+		return nil
+	}
+	return session.parent
+}
+
+// syncTx copies the state of the transaction from the parent after it's changed
+func (session *Session) syncTx(parent *Session) {
+	session.isAutoCommit = parent.isAutoCommit
+	session.isCommitedOrRollbacked = parent.isCommitedOrRollbacked
+	session.savepoints = parent.savepoints
+}
+
+// endTxCallbacks calls the commit or rollback callbacks and clears all of them
+func (session *Session) endTxCallbacks(committed bool) {
+	callbacks := session.rollbackCallbacks
+	if committed {
+		callbacks = session.commitCallbacks
+	}
+	session.commitCallbacks = nil
+	session.rollbackCallbacks = nil
+	callTxCallbacks(callbacks)
+}
+
+func callTxCallbacks(callbacks []func()) {
+	for _, callback := range callbacks {
+		callback()
+	}
+}
+
+func (session *Session) popSavepoint() *savepoint {
+	if len(session.savepoints) == 0 {
+		return nil
+	}
+	sp := session.savepoints[len(session.savepoints)-1]
+	session.savepoints = session.savepoints[:len(session.savepoints)-1]
+	return sp
+}
+
+// savepointSQL returns the SQL to create, rollback to or release a savepoint,
+// empty string means the action is not supported by the database and could be ignored.
+func (session *Session) savepointSQL(action, name string) string {
+	dbType := session.engine.dialect.DBType()
+	switch action {
+	case "SAVEPOINT":
+		if dbType == core.MSSQL {
+			return "SAVE TRANSACTION " + name
+		}
+		return "SAVEPOINT " + name
+	case "ROLLBACK":
+		if dbType == core.MSSQL {
+			return "ROLLBACK TRANSACTION " + name
+		}
+		return "ROLLBACK TO SAVEPOINT " + name
+	}
+
+	// MSSQL and Oracle release the savepoints when the transaction ends
+	if dbType == core.MSSQL || dbType == core.ORACLE {
+		return ""
+	}
+	return "RELEASE SAVEPOINT " + name
+}
+
+func (session *Session) execSavepoint(sqlStr string) error {
+	session.saveLastSQL(sqlStr)
+	if session.engine.showSQL {
+		session.engine.logger.Infof("[SQL] %v", sqlStr)
+	}
+	_, err := session.tx.ExecContext(session.ctx, sqlStr)
+	return err
+}
+
+func copyAfterBeans(beans map[interface{}]*[]func(interface{})) map[interface{}]*[]func(interface{}) {
+	var res = make(map[interface{}]*[]func(interface{}), len(beans))
+	for bean, closuresPtr := range beans {
+		if closuresPtr == nil {
+			res[bean] = nil
+			continue
+		}
+		closures := make([]func(interface{}), len(*closuresPtr))
+		copy(closures, *closuresPtr)
+		res[bean] = &closures
+	}
+	return res
+}
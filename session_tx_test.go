@@ -191,3 +191,100 @@ func TestMultipleTransaction(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, 0, len(ms))
 }
+
+func TestNestedTransaction(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type NestedTx struct {
+		Id   int64
+		Name string
+	}
+
+	assertSync(t, new(NestedTx))
+
+	var afterInserted []string
+	session := testEngine.NewSession()
+	defer session.Close()
+
+	assert.NoError(t, session.Begin())
+	_, err := session.After(func(bean interface{}) {
+		afterInserted = append(afterInserted, bean.(*NestedTx).Name)
+	}).Insert(&NestedTx{Name: "outer"})
+	assert.NoError(t, err)
+
+	// rollback the nested transaction
+	assert.NoError(t, session.Begin())
+	_, err = session.After(func(bean interface{}) {
+		afterInserted = append(afterInserted, bean.(*NestedTx).Name)
+	}).Insert(&NestedTx{Name: "rollback"})
+	assert.NoError(t, err)
+	assert.NoError(t, session.Rollback())
+
+	// commit the nested transaction
+	assert.NoError(t, session.Begin())
+	_, err = session.Insert(&NestedTx{Name: "commit"})
+	assert.NoError(t, err)
+	assert.NoError(t, session.Commit())
+
+	cnt, err := session.Count(new(NestedTx))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	assert.NoError(t, session.Commit())
+	assert.EqualValues(t, []string{"outer"}, afterInserted)
+
+	var txs []NestedTx
+	assert.NoError(t, testEngine.Asc("id").Find(&txs))
+	assert.EqualValues(t, 2, len(txs))
+	assert.EqualValues(t, "outer", txs[0].Name)
+	assert.EqualValues(t, "commit", txs[1].Name)
+
+	// close rollbacks the whole transaction even if there are savepoints
+	session2 := testEngine.NewSession()
+	assert.NoError(t, session2.Begin())
+	_, err = session2.Insert(&NestedTx{Name: "closed"})
+	assert.NoError(t, err)
+	assert.NoError(t, session2.Begin())
+	session2.Close()
+
+	cnt, err = testEngine.Count(new(NestedTx))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+}
+
+func TestTxCallbacks(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	var called []string
+	callback := func(name string) func() {
+		return func() {
+			called = append(called, name)
+		}
+	}
+
+	session := testEngine.NewSession()
+	defer session.Close()
+
+	// auto commit
+	session.OnCommit(callback("auto commit")).OnRollback(callback("auto rollback"))
+	assert.EqualValues(t, []string{"auto commit"}, called)
+
+	called = nil
+	assert.NoError(t, session.Begin())
+	session.OnCommit(callback("commit1")).OnRollback(callback("rollback1"))
+
+	assert.NoError(t, session.Begin())
+	session.OnCommit(callback("nested commit")).OnRollback(callback("nested rollback"))
+	assert.NoError(t, session.Rollback())
+	assert.EqualValues(t, []string{"nested rollback"}, called)
+
+	session.OnCommit(callback("commit2"))
+	assert.NoError(t, session.Commit())
+	assert.EqualValues(t, []string{"nested rollback", "commit1", "commit2"}, called)
+
+	called = nil
+	assert.NoError(t, session.Begin())
+	session.OnCommit(callback("commit")).OnRollback(callback("rollback"))
+	assert.NoError(t, session.Rollback())
+	assert.EqualValues(t, []string{"rollback"}, called)
+}
@@ -5,6 +5,7 @@
 package xorm
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"reflect"
@@ -144,6 +145,10 @@ func (session *Session) cacheUpdate(table *core.Table, tableName, sqlStr string,
 //         You should call UseBool if you have bool to use.
 //        2.float32 & float64 may be not inexact as conditions
 func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int64, error) {
+	if session.statement.associations {
+		return session.updateWithAssociations(bean, condiBean)
+	}
+
 	if session.isAutoClose {
 		defer session.Close()
 	}
@@ -166,6 +171,9 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	if processor, ok := interface{}(bean).(BeforeUpdateProcessor); ok {
 		processor.BeforeUpdate()
 	}
+	if err := session.beforeUpdateHook(bean); err != nil {
+		return 0, err
+	}
 	// --
 
 	var err error
@@ -201,6 +209,7 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	} else {
 		return 0, ErrParamsType
 	}
+	session.applyDefaultScopes()
 
 	table := session.statement.RefTable
 
@@ -377,19 +386,53 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 		return 0, errors.New("No content found to be updated")
 	}
 
-	sqlStr = fmt.Sprintf("UPDATE %v%v SET %v %v",
+	var isReturning = session.statement.returning
+	var output, returning string
+	if isReturning {
+		output, returning, err = session.statement.genReturning("INSERTED")
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	sqlStr = fmt.Sprintf("UPDATE %v%v SET %v%v %v%v",
 		top,
 		session.engine.Quote(tableName),
 		strings.Join(colNames, ", "),
-		condSQL)
-
-	res, err := session.exec(sqlStr, append(args, condArgs...)...)
-	if err != nil {
-		return 0, err
-	} else if doIncVer {
-		if verValue != nil && verValue.IsValid() && verValue.CanSet() {
+		output,
+		condSQL,
+		returning)
+
+	var res sql.Result
+	var affected int64
+	if isReturning {
+		fields, results, err := session.queryReturning(sqlStr, append(args, condArgs...)...)
+		if err != nil {
+			return 0, err
+		}
+		if doIncVer && verValue != nil && verValue.IsValid() && verValue.CanSet() {
 			session.incrVersionFieldValue(verValue)
 		}
+		if isStruct {
+			if err := session.fillReturning(table, fields, results, bean); err != nil {
+				return 0, err
+			}
+		}
+		affected = int64(len(results))
+	} else {
+		res, err = session.exec(sqlStr, append(args, condArgs...)...)
+		if err != nil {
+			return 0, err
+		} else if doIncVer {
+			if verValue != nil && verValue.IsValid() && verValue.CanSet() {
+				session.incrVersionFieldValue(verValue)
+			}
+		}
+	}
+
+	if err := session.afterUpdateHook(bean); err != nil {
+		cleanupProcessorsClosures(&session.afterClosures)
+		return 0, err
 	}
 
 	if cacher := session.engine.getCacher(tableName); cacher != nil && session.statement.UseCache {
@@ -429,6 +472,9 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	cleanupProcessorsClosures(&session.afterClosures) // cleanup after used
 	// --
 
+	if isReturning {
+		return affected, nil
+	}
 	return res.RowsAffected()
 }
 
@@ -0,0 +1,271 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+
+	"xorm.io/core"
+)
+
+// upsertDialect is implemented by the dialects which could generate an
+// insert or update statement. The returned SQL inserts rows records of colNames,
+// the records which conflict on conflictCols will update updateCols instead.
+type upsertDialect interface {
+	UpsertSql(tableName string, colNames, conflictCols, updateCols []string, rows int) string
+}
+
+// upsertDialectOf returns the dialect of the engine if it supports upsert, SQLite
+// supports it since 3.24.0
+func (engine *Engine) upsertDialectOf() (upsertDialect, error) {
+	dialect, ok := engine.dialect.(upsertDialect)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	if db, ok := engine.dialect.(*sqlite3); ok {
+		if err := db.requireVersion("upsert", "3.24.0"); err != nil {
+			return nil, err
+		}
+	}
+	return dialect, nil
+}
+
+// OnConflict specifies the columns which detect the conflicting records for Upsert,
+// the columns should be a primary key or an unique index. MySQL always uses all of the
+// unique indexes and ignores the columns.
+func (session *Session) OnConflict(columns ...string) *Session {
+	session.statement.conflictColumns = col2NewCols(columns...)
+	return session
+}
+
+// Upsert inserts a struct or a slice of structs, if the record conflicts with
+// an existing record, the existing record's updateCols will be updated instead.
+// If no conflict columns specified via OnConflict, the primary key or the first
+// unique index will be used; if no updateCols specified, all the inserted columns
+// except the conflict columns, created and version columns will be updated.
+//
+// The records are upserted by chunks like InsertMulti, all the chunks are upserted
+// in one transaction. Please notice the affected rows are counted by the database,
+// i.e. MySQL counts 2 for one updated record. Autoincrement fields will not be
+// filled back. ErrSQLiteVersion is returned on SQLite older than 3.24.0.
+func (session *Session) Upsert(beans interface{}, updateCols ...string) (int64, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	session.autoResetStatement = false
+	defer func() {
+		session.autoResetStatement = true
+		session.resetStatement()
+	}()
+
+	if session.statement.lastError != nil {
+		return 0, session.statement.lastError
+	}
+
+	dialect, err := session.engine.upsertDialectOf()
+	if err != nil {
+		return 0, err
+	}
+
+	sliceValue := reflect.Indirect(reflect.ValueOf(beans))
+	var elems []interface{}
+	if sliceValue.Kind() == reflect.Slice {
+		if sliceValue.Len() == 0 {
+			return 0, nil
+		}
+		elems = make([]interface{}, 0, sliceValue.Len())
+		for i := 0; i < sliceValue.Len(); i++ {
+			elems = append(elems, reflect.Indirect(sliceValue.Index(i)).Addr().Interface())
+		}
+	} else if sliceValue.Kind() == reflect.Struct {
+		elems = []interface{}{beans}
+	} else {
+		return 0, ErrParamsType
+	}
+
+	if err := session.statement.setRefBean(elems[0]); err != nil {
+		return 0, err
+	}
+	tableName := session.statement.TableName()
+	if len(tableName) <= 0 {
+		return 0, ErrTableNotFound
+	}
+	table := session.statement.RefTable
+
+	var colNames []string
+	var args []interface{}
+	var userAfterClosures = session.afterClosures
+	var elemAfterClosures = make([][]func(interface{}), len(elems))
+	for i, elem := range elems {
+		// handle BeforeInsertProcessor
+		for _, closure := range session.beforeClosures {
+			closure(elem)
+		}
+		if processor, ok := interface{}(elem).(BeforeInsertProcessor); ok {
+			processor.BeforeInsert()
+		}
+		if err := session.beforeInsertHook(elem); err != nil {
+			return 0, err
+		}
+
+		session.afterClosures = make([]func(interface{}), len(userAfterClosures))
+		copy(session.afterClosures, userAfterClosures)
+		elemColNames, elemArgs, err := session.genInsertColumns(elem)
+		if err != nil {
+			return 0, err
+		}
+		elemAfterClosures[i] = session.afterClosures
+
+		if i == 0 {
+			colNames = elemColNames
+		} else if !stringsEqual(colNames, elemColNames) {
+			return 0, errors.New("upsert records should have the same columns")
+		}
+		args = append(args, elemArgs...)
+	}
+	cleanupProcessorsClosures(&session.beforeClosures)
+	session.afterClosures = make([]func(interface{}), 0)
+
+	if len(colNames) == 0 {
+		return 0, errors.New("no content found to be upserted")
+	}
+
+	conflictCols := session.statement.conflictColumns
+	if len(conflictCols) == 0 {
+		conflictCols = defaultConflictColumns(table, colNames)
+		if len(conflictCols) == 0 {
+			return 0, ErrConflictColumnsNotFound
+		}
+	}
+
+	if len(updateCols) == 0 {
+		updateCols = defaultUpsertColumns(table, colNames, conflictCols)
+	}
+
+	size := len(elems)
+	chunkSize := session.insertMultiChunkSize(table)
+	var isOwnTx = session.isAutoCommit && size > chunkSize
+	if isOwnTx {
+		if err := session.Begin(); err != nil {
+			return 0, err
+		}
+	}
+
+	var affected int64
+	for start := 0; start < size; start += chunkSize {
+		end := start + chunkSize
+		if end > size {
+			end = size
+		}
+
+		sqlStr := dialect.UpsertSql(session.engine.Quote(tableName), colNames, conflictCols, updateCols, end-start)
+		res, err := session.exec(sqlStr, args[start*len(colNames):end*len(colNames)]...)
+		if err == nil {
+			var cnt int64
+			cnt, err = res.RowsAffected()
+			affected += cnt
+		}
+		if err != nil {
+			if isOwnTx {
+				if rollbackErr := session.Rollback(); rollbackErr != nil {
+					session.engine.logger.Error(rollbackErr)
+				}
+			}
+			return affected, err
+		}
+	}
+
+	session.cacheInsert(tableName)
+	if cacher := session.engine.getCacher(tableName); cacher != nil && session.statement.UseCache {
+		cacher.ClearBeans(tableName)
+	}
+
+	for i, elem := range elems {
+		// handle AfterInsertProcessor
+		if session.isAutoCommit {
+			for _, closure := range elemAfterClosures[i] {
+				closure(elem)
+			}
+			if processor, ok := interface{}(elem).(AfterInsertProcessor); ok {
+				processor.AfterInsert()
+			}
+		} else if len(elemAfterClosures[i]) > 0 {
+			if value, has := session.afterInsertBeans[elem]; has && value != nil {
+				*value = append(*value, elemAfterClosures[i]...)
+			} else {
+				afterClosures := elemAfterClosures[i]
+				session.afterInsertBeans[elem] = &afterClosures
+			}
+		} else if _, ok := interface{}(elem).(AfterInsertProcessor); ok {
+			session.afterInsertBeans[elem] = nil
+		}
+	}
+
+	if isOwnTx {
+		if err := session.Commit(); err != nil {
+			return affected, err
+		}
+	}
+	for _, elem := range elems {
+		if err := session.afterInsertHook(elem); err != nil {
+			return affected, err
+		}
+	}
+	return affected, nil
+}
+
+// defaultConflictColumns returns the primary keys if all of them are inserted,
+// otherwise the columns of the first unique index which are all inserted
+func defaultConflictColumns(table *core.Table, colNames []string) []string {
+	var inserted = make(map[string]bool, len(colNames))
+	for _, colName := range colNames {
+		inserted[colName] = true
+	}
+	containsAll := func(cols []string) bool {
+		for _, col := range cols {
+			if !inserted[col] {
+				return false
+			}
+		}
+		return len(cols) > 0
+	}
+
+	if containsAll(table.PrimaryKeys) {
+		return table.PrimaryKeys
+	}
+
+	var names = make([]string, 0, len(table.Indexes))
+	for name, index := range table.Indexes {
+		if index.Type == core.UniqueType {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if cols := table.Indexes[name].Cols; containsAll(cols) {
+			return cols
+		}
+	}
+	return nil
+}
+
+// defaultUpsertColumns returns the inserted columns except the conflict columns,
+// created and version columns
+func defaultUpsertColumns(table *core.Table, colNames, conflictCols []string) []string {
+	var updateCols = make([]string, 0, len(colNames))
+	for _, colName := range colNames {
+		if stringsContains(conflictCols, colName) {
+			continue
+		}
+		if col := table.GetColumn(colName); col != nil && (col.IsCreated || col.IsVersion || col.IsAutoIncrement) {
+			continue
+		}
+		updateCols = append(updateCols, colName)
+	}
+	return updateCols
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpsert(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type UpsertStruct struct {
+		Id      int64
+		Name    string `xorm:"unique"`
+		Counter int
+		Created time.Time `xorm:"created"`
+	}
+
+	assertSync(t, new(UpsertStruct))
+
+	cnt, err := testEngine.Upsert(&UpsertStruct{Name: "a", Counter: 1})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	_, err = testEngine.Upsert(&UpsertStruct{Name: "a", Counter: 2})
+	assert.NoError(t, err)
+
+	var s UpsertStruct
+	has, err := testEngine.Where("name = ?", "a").Get(&s)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 2, s.Counter)
+
+	cnt, err = testEngine.Count(new(UpsertStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	_, err = testEngine.Upsert([]UpsertStruct{
+		{Name: "a", Counter: 3},
+		{Name: "b", Counter: 4},
+	})
+	assert.NoError(t, err)
+
+	var ss []UpsertStruct
+	assert.NoError(t, testEngine.Asc("name").Find(&ss))
+	assert.EqualValues(t, 2, len(ss))
+	assert.EqualValues(t, 3, ss[0].Counter)
+	assert.EqualValues(t, 4, ss[1].Counter)
+
+	// update specified columns only
+	_, err = testEngine.OnConflict("name").Upsert(&UpsertStruct{Id: ss[1].Id, Name: "b", Counter: 5}, "name")
+	assert.NoError(t, err)
+	var s2 UpsertStruct
+	has, err = testEngine.ID(ss[1].Id).Get(&s2)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 4, s2.Counter)
+
+	type UpsertNoConflict struct {
+		Id   int64
+		Name string
+	}
+
+	assertSync(t, new(UpsertNoConflict))
+
+	_, err = testEngine.Upsert(&UpsertNoConflict{Name: "a"})
+	assert.EqualValues(t, ErrConflictColumnsNotFound, err)
+}
+
+func TestUpsertSql(t *testing.T) {
+	var colNames = []string{"id", "name", "counter"}
+	var conflictCols = []string{"id"}
+	var updateCols = []string{"name", "counter"}
+
+	tests := []struct {
+		dialect  upsertDialect
+		expected string
+	}{
+		{&mysql{}, "INSERT INTO t (`id`, `name`, `counter`) VALUES (?, ?, ?),(?, ?, ?) " +
+			"ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `counter` = VALUES(`counter`)"},
+		{&postgres{}, `INSERT INTO t ("id", "name", "counter") VALUES (?, ?, ?),(?, ?, ?) ` +
+			`ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name", "counter" = excluded."counter"`},
+		{&sqlite3{}, "INSERT INTO t (`id`, `name`, `counter`) VALUES (?, ?, ?),(?, ?, ?) " +
+			"ON CONFLICT (`id`) DO UPDATE SET `name` = excluded.`name`, `counter` = excluded.`counter`"},
+		{&mssql{}, "MERGE INTO t WITH (HOLDLOCK) AS target USING (VALUES (?, ?, ?),(?, ?, ?)) " +
+			"AS source (\"id\", \"name\", \"counter\") ON target.\"id\" = source.\"id\" " +
+			"WHEN MATCHED THEN UPDATE SET \"name\" = source.\"name\", \"counter\" = source.\"counter\" " +
+			"WHEN NOT MATCHED THEN INSERT (\"id\", \"name\", \"counter\") VALUES (source.\"id\", source.\"name\", source.\"counter\");"},
+		{&oracle{}, `MERGE INTO t target USING (SELECT ? [id], ? [name], ? [counter] FROM DUAL UNION ALL ` +
+			`SELECT ? [id], ? [name], ? [counter] FROM DUAL) source ON (target.[id] = source.[id]) ` +
+			`WHEN MATCHED THEN UPDATE SET target.[name] = source.[name], target.[counter] = source.[counter] ` +
+			`WHEN NOT MATCHED THEN INSERT ([id], [name], [counter]) VALUES (source.[id], source.[name], source.[counter])`},
+	}
+
+	for _, test := range tests {
+		assert.EqualValues(t, test.expected, test.dialect.UpsertSql("t", colNames, conflictCols, updateCols, 2))
+	}
+
+	assert.EqualValues(t, "INSERT INTO t (`id`) VALUES (?) ON DUPLICATE KEY UPDATE `id` = `id`",
+		(&mysql{}).UpsertSql("t", []string{"id"}, conflictCols, nil, 1))
+	assert.EqualValues(t, `INSERT INTO t ("id") VALUES (?) ON CONFLICT ("id") DO NOTHING`,
+		(&postgres{}).UpsertSql("t", []string{"id"}, conflictCols, nil, 1))
+}
+
+func TestUpsertChunks(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type UpsertChunk struct {
+		Id      int64
+		Name    string `xorm:"unique"`
+		Counter int
+	}
+
+	assertSync(t, new(UpsertChunk))
+
+	// more parameters than one statement of SQLite and MSSQL could contain
+	var records = make([]UpsertChunk, 1200)
+	for i := range records {
+		records[i] = UpsertChunk{Name: fmt.Sprintf("name%d", i), Counter: 1}
+	}
+	_, err := testEngine.Upsert(records)
+	assert.NoError(t, err)
+
+	for i := range records {
+		records[i].Counter = 2
+	}
+	session := testEngine.NewSession()
+	defer session.Close()
+	_, err = session.InsertChunkSize(100).Upsert(records)
+	assert.NoError(t, err)
+
+	cnt, err := testEngine.Count(new(UpsertChunk))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1200, cnt)
+	cnt, err = testEngine.Where("counter = ?", 2).Count(new(UpsertChunk))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1200, cnt)
+}
+
+func TestUpsertDialectOf(t *testing.T) {
+	engine := &Engine{dialect: &sqlite3{version: "3.23.1"}}
+	_, err := engine.upsertDialectOf()
+	assert.EqualValues(t, ErrSQLiteVersion{Feature: "upsert", Required: "3.24.0", Version: "3.23.1"}, err)
+
+	engine = &Engine{dialect: &sqlite3{version: "3.24.0"}}
+	_, err = engine.upsertDialectOf()
+	assert.NoError(t, err)
+}
@@ -55,6 +55,14 @@ type Statement struct {
 	incrColumns     exprParams
 	decrColumns     exprParams
 	exprColumns     exprParams
+	conflictColumns []string
+	returning       bool
+	returningCols   []string
+	preloads        []string
+	associations    bool
+	noDefaultScopes bool
+	scopesApplied   bool
+	page            *Page
 	cond            builder.Cond
 	bufferSize      int
 	context         ContextCache
@@ -97,6 +105,14 @@ func (statement *Statement) Init() {
 	statement.incrColumns = exprParams{}
 	statement.decrColumns = exprParams{}
 	statement.exprColumns = exprParams{}
+	statement.conflictColumns = nil
+	statement.returning = false
+	statement.returningCols = nil
+	statement.preloads = nil
+	statement.associations = false
+	statement.noDefaultScopes = false
+	statement.scopesApplied = false
+	statement.page = nil
 	statement.cond = builder.NewCond()
 	statement.bufferSize = 0
 	statement.context = nil
@@ -832,8 +848,8 @@ func (statement *Statement) genColumnStr() string {
 }
 
 func (statement *Statement) genCreateTableSQL() string {
-	return statement.Engine.dialect.CreateTableSql(statement.RefTable, statement.TableName(),
-		statement.StoreEngine, statement.Charset)
+	return statement.Engine.createTableSQL(statement.RefTable, statement.TableName(),
+		statement.StoreEngine, statement.Charset, statement.Engine.tableSchemaOf(statement.RefTable, statement.TableName()))
 }
 
 func (statement *Statement) genIndexSQL() []string {
@@ -28,6 +28,7 @@ type tagContext struct {
 	hasCacheTag     bool
 	hasNoCacheTag   bool
 	ignoreNext      bool
+	isRelation      bool
 }
 
 // tagHandler describes tag handler for XORM
@@ -55,9 +56,37 @@ var (
 		"CACHE":    CacheTagHandler,
 		"NOCACHE":  NoCacheTagHandler,
 		"COMMENT":  CommentTagHandler,
+
+		"RENAMED_FROM": RenamedFromTagHandler,
+		"FK":           FKTagHandler,
+		"ONDELETE":     ReferentialActionTagHandler,
+		"ONUPDATE":     ReferentialActionTagHandler,
+		"CHECK":        CheckTagHandler,
+		"GENERATED":    GeneratedTagHandler,
+		"STORED":       GeneratedStorageTagHandler,
+		"VIRTUAL":      GeneratedStorageTagHandler,
+		"COLLATE":      CollateTagHandler,
+
+		"HAS_ONE":    RelationTagHandler,
+		"HAS_MANY":   RelationTagHandler,
+		"BELONGS_TO": RelationTagHandler,
+		"MANY2MANY":  RelationTagHandler,
 	}
 )
 
+// isColumnNameTag tells whether the tag is the column name, e.g. xorm:"check", since
+// fk, ondelete, onupdate, check, generated and collate are tags only with parameters,
+// and stored and virtual only after generated, so that such column names still work
+func isColumnNameTag(ctx *tagContext, hasParams bool) bool {
+	switch ctx.tagName {
+	case "FK", "ONDELETE", "ONUPDATE", "CHECK", "GENERATED", "COLLATE":
+		return !hasParams
+	case "STORED", "VIRTUAL":
+		return !strings.HasPrefix(ctx.preTag, "GENERATED(")
+	}
+	return false
+}
+
 func init() {
 	for k := range core.SqlTypes {
 		defaultTagHandlers[k] = SQLTypeTagHandler
@@ -202,6 +231,69 @@ func CommentTagHandler(ctx *tagContext) error {
 	return nil
 }
 
+// RenamedFromTagHandler describes renamed_from tag handler, the old names are read
+// from the field tag by Sync2 when the column is not found in the database
+func RenamedFromTagHandler(ctx *tagContext) error {
+	if len(ctx.params) == 0 || strings.TrimSpace(ctx.params[0]) == "" {
+		return fmt.Errorf("field %s: renamed_from should have the old column name", ctx.col.FieldName)
+	}
+	return nil
+}
+
+// FKTagHandler describes fk tag handler, the param is the referenced table and column
+// as fk(user.id), the foreign keys are read from the field tags when creating or
+// syncing the table
+func FKTagHandler(ctx *tagContext) error {
+	if len(ctx.params) != 1 || strings.LastIndex(ctx.params[0], ".") <= 0 {
+		return fmt.Errorf("field %s: fk should reference a column as fk(table.column)", ctx.col.FieldName)
+	}
+	return nil
+}
+
+// ReferentialActionTagHandler describes ondelete and onupdate tag handler
+func ReferentialActionTagHandler(ctx *tagContext) error {
+	if len(ctx.params) != 1 || !referentialActions[normalizeReferentialAction(ctx.params[0])] {
+		return fmt.Errorf("field %s: unknown action of %s", ctx.col.FieldName, strings.ToLower(ctx.tagName))
+	}
+	return nil
+}
+
+// CheckTagHandler describes check tag handler, the param is the expression of the
+// CHECK constraint which should be quoted if it has spaces, e.g. check('price > 0')
+func CheckTagHandler(ctx *tagContext) error {
+	if len(ctx.params) == 0 || strings.Trim(strings.Join(ctx.params, ","), "' ") == "" {
+		return fmt.Errorf("field %s: check should have the expression", ctx.col.FieldName)
+	}
+	return nil
+}
+
+// GeneratedTagHandler describes generated tag handler, the column is generated by
+// the expression and only read from the database
+func GeneratedTagHandler(ctx *tagContext) error {
+	if len(ctx.params) == 0 || strings.Trim(strings.Join(ctx.params, ","), "' ") == "" {
+		return fmt.Errorf("field %s: generated should have the expression", ctx.col.FieldName)
+	}
+	ctx.col.MapType = core.ONLYFROMDB
+	return nil
+}
+
+// GeneratedStorageTagHandler describes stored and virtual tag handler which should
+// follow generated
+func GeneratedStorageTagHandler(ctx *tagContext) error {
+	if !strings.HasPrefix(ctx.preTag, "GENERATED(") {
+		return fmt.Errorf("field %s: %s should follow generated", ctx.col.FieldName, strings.ToLower(ctx.tagName))
+	}
+	return nil
+}
+
+// CollateTagHandler describes collate tag handler
+func CollateTagHandler(ctx *tagContext) error {
+	if len(ctx.params) != 1 || strings.TrimSpace(ctx.params[0]) == "" {
+		return fmt.Errorf("field %s: collate should have the collation name", ctx.col.FieldName)
+	}
+	return nil
+}
+
 // SQLTypeTagHandler describes SQL Type tag handler
 func SQLTypeTagHandler(ctx *tagContext) error {
 	ctx.col.SQLType = core.SQLType{Name: ctx.tagName}
@@ -242,6 +334,16 @@ func SQLTypeTagHandler(ctx *tagContext) error {
 	return nil
 }
 
+// RelationTagHandler describes has_one, has_many, belongs_to and many2many tag handler,
+// the field will not be mapped as a column
+func RelationTagHandler(ctx *tagContext) error {
+	if _, err := relationElemType(relationTypes[ctx.tagName], ctx.fieldValue.Type()); err != nil {
+		return fmt.Errorf("field %s: %v", ctx.col.FieldName, err)
+	}
+	ctx.isRelation = true
+	return nil
+}
+
 // ExtendsTagHandler describes extends tag handler
 func ExtendsTagHandler(ctx *tagContext) error {
 	var fieldValue = ctx.fieldValue
@@ -309,3 +411,64 @@ func NoCacheTagHandler(ctx *tagContext) error {
 	}
 	return nil
 }
+
+// fieldTags returns the tags of the struct's field, fieldName is the FieldName of a
+// column which is dotted for the fields of extends
+func (engine *Engine) fieldTags(t reflect.Type, fieldName string) []string {
+	var field reflect.StructField
+	for _, name := range strings.Split(fieldName, ".") {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		f, ok := t.FieldByName(name)
+		if !ok {
+			return nil
+		}
+		field, t = f, f.Type
+	}
+	return splitTag(field.Tag.Get(engine.TagIdentifier))
+}
+
+// tagParam returns the content in the parentheses of the tag, the content quoted by
+// single quotes is unquoted as an SQL string literal
+func tagParam(tags []string, tagName string) (string, bool) {
+	for _, key := range tags {
+		pStart := strings.Index(key, "(")
+		if pStart <= 0 || !strings.HasSuffix(key, ")") || !strings.EqualFold(key[:pStart], tagName) {
+			continue
+		}
+		param := strings.TrimSpace(key[pStart+1 : len(key)-1])
+		if len(param) >= 2 && strings.HasPrefix(param, "'") && strings.HasSuffix(param, "'") {
+			param = strings.Replace(param[1:len(param)-1], "''", "'", -1)
+		}
+		return param, true
+	}
+	return "", false
+}
+
+// hasTagAfter returns true if the tag without params follows the tag with params
+// whose name is prevName, e.g. stored after generated(expr)
+func hasTagAfter(tags []string, prevName, tagName string) bool {
+	for i := 1; i < len(tags); i++ {
+		if strings.EqualFold(tags[i], tagName) && strings.HasPrefix(strings.ToUpper(tags[i-1]), strings.ToUpper(prevName)+"(") {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldTagParams returns the params of the tag of the struct's field
+func (engine *Engine) fieldTagParams(t reflect.Type, fieldName, tagName string) ([]string, bool) {
+	param, ok := tagParam(engine.fieldTags(t, fieldName), tagName)
+	if !ok {
+		return nil, false
+	}
+	var params []string
+	for _, p := range strings.Split(param, ",") {
+		params = append(params, strings.Trim(strings.TrimSpace(p), "'\""))
+	}
+	return params, true
+}
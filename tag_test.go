@@ -6,6 +6,7 @@ package xorm
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -71,6 +72,38 @@ func TestStrangeName(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHasTagAfter(t *testing.T) {
+	assert.True(t, hasTagAfter(splitTag("generated('a + b') stored"), "generated", "stored"))
+	assert.False(t, hasTagAfter(splitTag("generated('a + b') notnull stored"), "generated", "stored"))
+	assert.False(t, hasTagAfter(splitTag("stored"), "generated", "stored"))
+}
+
+func TestTagAsColumnName(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type TagAsColumnName struct {
+		Id       int64
+		Check    string `xorm:"check"`
+		Stored   bool   `xorm:"stored"`
+		Virtual  bool   `xorm:"notnull virtual"`
+		Collate  string `xorm:"varchar(20) collate"`
+		Fk       int64  `xorm:"fk"`
+		OnDelete string `xorm:"'ondelete'"`
+	}
+
+	table, err := testEngine.(*Engine).autoMapType(reflect.ValueOf(TagAsColumnName{}))
+	assert.NoError(t, err)
+	for _, name := range []string{"check", "stored", "virtual", "collate", "fk", "ondelete"} {
+		assert.NotNil(t, table.GetColumn(name), name)
+	}
+	schema := testEngine.(*Engine).tableSchemaOf(table, table.Name)
+	assert.True(t, schema.isEmpty())
+
+	assertSync(t, new(TagAsColumnName))
+	_, err = testEngine.Insert(&TagAsColumnName{Check: "a", Stored: true, Collate: "b"})
+	assert.NoError(t, err)
+}
+
 func TestCreatedUpdated(t *testing.T) {
 	assert.NoError(t, prepareEngine())
 
@@ -4,16 +4,80 @@
 
 package xorm
 
-// Transaction Execute sql wrapped in a transaction(abbr as tx), tx will automatic commit if no errors occurred
-func (engine *Engine) Transaction(f func(*Session) (interface{}, error)) (interface{}, error) {
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"xorm.io/core"
+)
+
+// TxOptions represents the options of Engine.Transaction
+type TxOptions struct {
+	// Isolation and ReadOnly will be passed to the database when the transaction begins
+	sql.TxOptions
+
+	// MaxRetries is the max times to re-run the whole transaction when it fails
+	// because of a deadlock or a serialization failure, 0 means no retry. The errors
+	// are recognized on a best effort basis, see isRetryableTxError.
+	MaxRetries int
+	// Backoff is the waiting duration before the first retry, it will be doubled
+	// for every following retry but not larger than MaxBackoff if it's not 0.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Transaction Execute sql wrapped in a transaction(abbr as tx), tx will automatic commit if no errors occurred.
+// A panic in f will be recovered and returned as an error after the tx rollback. If opts is given, the tx will
+// begin with the options and will be retried on deadlocks or serialization failures.
+func (engine *Engine) Transaction(f func(*Session) (interface{}, error), opts ...*TxOptions) (interface{}, error) {
+	var opt = &TxOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		opt = opts[0]
+	}
+
+	var backoff = opt.Backoff
+	for i := 0; ; i++ {
+		result, err := engine.transaction(f, &opt.TxOptions)
+		if err == nil || i >= opt.MaxRetries || !engine.isRetryableTxError(err) {
+			return result, err
+		}
+
+		engine.logger.Warnf("transaction will be retried after %v because of: %v", backoff, err)
+		if backoff > 0 {
+			select {
+			case <-engine.defaultContext.Done():
+				return nil, engine.defaultContext.Err()
+			case <-time.After(backoff):
+			}
+		}
+		backoff *= 2
+		if opt.MaxBackoff > 0 && backoff > opt.MaxBackoff {
+			backoff = opt.MaxBackoff
+		}
+	}
+}
+
+func (engine *Engine) transaction(f func(*Session) (interface{}, error), opts *sql.TxOptions) (result interface{}, err error) {
 	session := engine.NewSession()
 	defer session.Close()
 
-	if err := session.Begin(); err != nil {
+	if err := session.BeginTx(opts); err != nil {
 		return nil, err
 	}
 
-	result, err := f(session)
+	defer func() {
+		if r := recover(); r != nil {
+			if rollbackErr := session.Rollback(); rollbackErr != nil {
+				engine.logger.Errorf("rollback failed: %v", rollbackErr)
+			}
+			result, err = nil, fmt.Errorf("transaction panic: %v", r)
+		}
+	}()
+
+	result, err = f(session)
 	if err != nil {
 		return nil, err
 	}
@@ -24,3 +88,91 @@ func (engine *Engine) Transaction(f func(*Session) (interface{}, error)) (interf
 
 	return result, nil
 }
+
+// sqlStateError is implemented by the errors of pgx v4
+type sqlStateError interface {
+	SQLState() string
+}
+
+// pgErrorCode returns the Code field of pgx.PgError of pgx v3 which has no method for
+// it, pgx is not imported since the driver is optional
+func pgErrorCode(err error) string {
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || v.Type().Name() != "PgError" {
+		return ""
+	}
+	if code := v.FieldByName("Code"); code.IsValid() && code.Kind() == reflect.String {
+		return code.String()
+	}
+	return ""
+}
+
+// mysqlErrorNumber returns the Number field of mysql.MySQLError of go-sql-driver/mysql
+// which has no method for it, or 0 for the other errors. The driver is not imported
+// for the same reason as pgx.
+func mysqlErrorNumber(err error) uint64 {
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || v.Type().Name() != "MySQLError" {
+		return 0
+	}
+	if number := v.FieldByName("Number"); number.IsValid() {
+		switch number.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return number.Uint()
+		}
+	}
+	return 0
+}
+
+// fieldError is implemented by the errors of lib/pq, 'C' is the SQLSTATE code
+type fieldError interface {
+	Get(k byte) string
+}
+
+// sqlErrorNumberError is implemented by the errors of go-mssqldb
+type sqlErrorNumberError interface {
+	SQLErrorNumber() int32
+}
+
+// isRetryableTxError returns true if the error is caused by a deadlock or a
+// serialization failure, then the transaction could be re-run. Since the drivers
+// are not imported, it is best effort: the errors of go-sql-driver/mysql, lib/pq,
+// pgx and go-mssqldb are recognized, and the other MySQL drivers only when their
+// messages start with the error number like go-sql-driver/mysql does.
+func (engine *Engine) isRetryableTxError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch engine.dialect.DBType() {
+	case core.MYSQL:
+		// 1213 is a deadlock and 1205 is a lock wait timeout
+		if number := mysqlErrorNumber(err); number != 0 {
+			return number == 1213 || number == 1205
+		}
+		// i.e. Error 1213: Deadlock found when trying to get lock
+		msg := err.Error()
+		return strings.HasPrefix(msg, "Error 1213:") || strings.HasPrefix(msg, "Error 1205:")
+	case core.POSTGRES:
+		var code string
+		if e, ok := err.(sqlStateError); ok {
+			code = e.SQLState()
+		} else if e, ok := err.(fieldError); ok {
+			code = e.Get('C')
+		} else {
+			code = pgErrorCode(err)
+		}
+		return code == "40001" || code == "40P01"
+	case core.MSSQL:
+		if e, ok := err.(sqlErrorNumberError); ok {
+			return e.SQLErrorNumber() == 1205
+		}
+	}
+	return false
+}
@@ -5,11 +5,14 @@
 package xorm
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
 )
 
 func TestAutoTransaction(t *testing.T) {
@@ -50,3 +53,129 @@ func TestAutoTransaction(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, false, has)
 }
+
+func TestTransactionPanic(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type TestTxPanic struct {
+		Id  int64
+		Msg string
+	}
+
+	assertSync(t, new(TestTxPanic))
+
+	engine := testEngine.(*Engine)
+	_, err := engine.Transaction(func(session *Session) (interface{}, error) {
+		_, err := session.Insert(&TestTxPanic{Msg: "hi"})
+		assert.NoError(t, err)
+		panic("oops")
+	})
+	assert.Error(t, err)
+
+	has, err := engine.Exist(&TestTxPanic{Msg: "hi"})
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	// options
+	result, err := engine.Transaction(func(session *Session) (interface{}, error) {
+		return session.Count(new(TestTxPanic))
+	}, &TxOptions{TxOptions: sql.TxOptions{Isolation: sql.LevelDefault}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, result)
+}
+
+type sqlStateErr string
+
+func (e sqlStateErr) Error() string    { return "serialization failure" }
+func (e sqlStateErr) SQLState() string { return string(e) }
+
+// PgError is the same as pgx.PgError of pgx v3 whose code is a field
+type PgError struct {
+	Severity string
+	Code     string
+	Message  string
+}
+
+func (e PgError) Error() string { return e.Severity + ": " + e.Message }
+
+// MySQLError is the same as mysql.MySQLError of go-sql-driver/mysql whose number is a field
+type MySQLError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *MySQLError) Error() string { return fmt.Sprintf("Error %d: %s", e.Number, e.Message) }
+
+type sqlErrorNumberErr int32
+
+func (e sqlErrorNumberErr) Error() string         { return "deadlock" }
+func (e sqlErrorNumberErr) SQLErrorNumber() int32 { return int32(e) }
+
+func TestTransactionRetry(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	var retryableErr error
+	switch testEngine.Dialect().DBType() {
+	case core.MYSQL:
+		retryableErr = errors.New("Error 1213: Deadlock found when trying to get lock")
+	case core.POSTGRES:
+		retryableErr = sqlStateErr("40001")
+	case core.MSSQL:
+		retryableErr = sqlErrorNumberErr(1205)
+	default:
+		return
+	}
+
+	engine := testEngine.(*Engine)
+	var times int
+	result, err := engine.Transaction(func(session *Session) (interface{}, error) {
+		times++
+		if times < 3 {
+			return nil, retryableErr
+		}
+		return times, nil
+	}, &TxOptions{MaxRetries: 3, Backoff: time.Millisecond})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, result)
+
+	times = 0
+	_, err = engine.Transaction(func(session *Session) (interface{}, error) {
+		times++
+		return nil, retryableErr
+	}, &TxOptions{MaxRetries: 1})
+	assert.EqualValues(t, retryableErr, err)
+	assert.EqualValues(t, 2, times)
+}
+
+func TestIsRetryableTxError(t *testing.T) {
+	tests := []struct {
+		dbType    core.DbType
+		dialect   core.Dialect
+		err       error
+		retryable bool
+	}{
+		{core.MYSQL, &mysql{}, errors.New("Error 1213: Deadlock found when trying to get lock"), true},
+		{core.MYSQL, &mysql{}, errors.New("Error 1205: Lock wait timeout exceeded"), true},
+		{core.MYSQL, &mysql{}, errors.New("Error 1062: Duplicate entry"), false},
+		{core.MYSQL, &mysql{}, errors.New("Error 12130: Unknown"), false},
+		{core.MYSQL, &mysql{}, &MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, true},
+		{core.MYSQL, &mysql{}, &MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, true},
+		{core.MYSQL, &mysql{}, &MySQLError{Number: 1062, Message: "Duplicate entry"}, false},
+		{core.MYSQL, &mysql{}, sqlStateErr("40001"), false},
+		{core.POSTGRES, &postgres{}, sqlStateErr("40001"), true},
+		{core.POSTGRES, &postgres{}, sqlStateErr("40P01"), true},
+		{core.POSTGRES, &postgres{}, sqlStateErr("23505"), false},
+		{core.POSTGRES, &postgres{}, PgError{Code: "40001"}, true},
+		{core.POSTGRES, &postgres{}, &PgError{Code: "40P01"}, true},
+		{core.POSTGRES, &postgres{}, PgError{Code: "23505"}, false},
+		{core.MSSQL, &mssql{}, sqlErrorNumberErr(1205), true},
+		{core.MSSQL, &mssql{}, sqlErrorNumberErr(2627), false},
+		{core.SQLITE, &sqlite3{}, errors.New("database is locked"), false},
+	}
+
+	for _, test := range tests {
+		assert.NoError(t, test.dialect.Init(nil, &core.Uri{DbType: test.dbType}, "", ""))
+		engine := &Engine{dialect: test.dialect}
+		assert.EqualValues(t, test.retryable, engine.isRetryableTxError(test.err))
+	}
+}